@@ -23,6 +23,7 @@ type AppwriteConfig struct {
 	CollectionIDHeartbeats     string
 	CollectionIDStatistics     string
 	CollectionIDPostMetrics    string
+	CollectionIDCoupons        string
 	CounterDocumentID          string
 }
 
@@ -49,5 +50,6 @@ func NewAppwriteConfig() *AppwriteConfig {
 		CollectionIDHeartbeats:     GetEnv("APPWRITE_COLLECTION_ID_HEARTBEATS", "6625546a002bd9eb7ffe"),
 		CollectionIDStatistics:     GetEnv("APPWRITE_COLLECTION_ID_STATISTICS", "689d116400217e4cd917"),
 		CollectionIDPostMetrics:    GetEnv("APPWRITE_COLLECTION_ID_POST_METRICS", "67e928fc0018acb88f5b"),
+		CollectionIDCoupons:        GetEnv("APPWRITE_COLLECTION_ID_COUPONS", ""),
 	}
 }
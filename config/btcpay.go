@@ -0,0 +1,17 @@
+package config
+
+type BTCPayConfig struct {
+	ApiKey        string
+	Endpoint      string
+	StoreID       string
+	WebhookSecret string
+}
+
+func NewBTCPayConfig() *BTCPayConfig {
+	return &BTCPayConfig{
+		ApiKey:        GetEnv("BTCPAY_API_KEY", ""),
+		Endpoint:      GetEnv("BTCPAY_ENDPOINT", ""),
+		StoreID:       GetEnv("BTCPAY_STORE_ID", ""),
+		WebhookSecret: GetEnv("BTCPAY_WEBHOOK_SECRET", ""),
+	}
+}
@@ -1,51 +1,177 @@
 package config
 
 import (
+	"fmt"
 	"math"
+	"slices"
+	"strings"
+	"time"
 )
 
+// Period is the length of time a subscription plan grants access for.
+type Period struct {
+	Years  int
+	Months int
+	Days   int
+}
+
+func (p Period) IsZero() bool {
+	return p.Years == 0 && p.Months == 0 && p.Days == 0
+}
+
+// AddTo returns t moved forward by the period.
+func (p Period) AddTo(t time.Time) time.Time {
+	return t.AddDate(p.Years, p.Months, p.Days)
+}
+
+// String returns a human-readable form such as "1 year" or "3 months".
+func (p Period) String() string {
+	var parts []string
+	for _, part := range []struct {
+		value int
+		unit  string
+	}{{p.Years, "year"}, {p.Months, "month"}, {p.Days, "day"}} {
+		switch {
+		case part.value == 1:
+			parts = append(parts, fmt.Sprintf("1 %s", part.unit))
+		case part.value > 1:
+			parts = append(parts, fmt.Sprintf("%d %ss", part.value, part.unit))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// BaseCurrency is the fiat currency of a plan's base price.
+const BaseCurrency = "usd"
+
+// Rounding is how a price converted from the base currency is rounded.
+type Rounding int
+
+const (
+	// RoundCents rounds to the nearest cent.
+	RoundCents Rounding = iota
+	// RoundWhole rounds up to a whole unit, 9.12 becomes 10.
+	RoundWhole
+	// RoundCharm rounds up to the next price ending in .99, 9.12 becomes 9.99.
+	RoundCharm
+)
+
+// Round applies the rounding rule to amount.
+func (r Rounding) Round(amount float64) float64 {
+	cents := math.Round(amount * 100)
+	switch r {
+	case RoundWhole:
+		return math.Ceil(cents / 100)
+	case RoundCharm:
+		return (math.Ceil((cents+1)/100)*100 - 1) / 100
+	default:
+		return cents / 100
+	}
+}
+
 type SubscriptionPlan interface {
 	GetID() string
 	GetName() string
 	GetPrice() float64
+	GetPriceIn(currency string) (float64, bool)
+	GetCurrencies() []string
+	GetRounding() Rounding
 	GetDuration() string
+	GetPeriod() Period
 }
 
 type subscriptionPlan struct {
 	ID       string
 	Name     string
 	Price    float64
-	Duration string
+	Prices   map[string]float64
+	Rounding Rounding
+	Period   Period
 	Discount float64
 }
 
-func (sp *subscriptionPlan) GetID() string       { return sp.ID }
-func (sp *subscriptionPlan) GetName() string     { return sp.Name }
-func (sp *subscriptionPlan) GetDuration() string { return sp.Duration }
+func (sp *subscriptionPlan) GetID() string         { return sp.ID }
+func (sp *subscriptionPlan) GetName() string       { return sp.Name }
+func (sp *subscriptionPlan) GetDuration() string   { return sp.Period.String() }
+func (sp *subscriptionPlan) GetPeriod() Period     { return sp.Period }
+func (sp *subscriptionPlan) GetRounding() Rounding { return sp.Rounding }
 func (sp *subscriptionPlan) GetPrice() float64 {
+	return sp.discounted(sp.Price)
+}
+
+// GetPriceIn returns the discounted price of the plan in the given fiat currency, and
+// false when the plan has no price list entry for it. The base price is returned for
+// BaseCurrency.
+func (sp *subscriptionPlan) GetPriceIn(currency string) (float64, bool) {
+	currency = strings.ToLower(currency)
+	if currency == BaseCurrency {
+		return sp.GetPrice(), true
+	}
+	price, ok := sp.Prices[currency]
+	if !ok {
+		return 0, false
+	}
+	return sp.discounted(price), true
+}
+
+// GetCurrencies returns the fiat currencies the plan has a price for, base currency first.
+func (sp *subscriptionPlan) GetCurrencies() []string {
+	currencies := make([]string, 0, len(sp.Prices)+1)
+	currencies = append(currencies, BaseCurrency)
+	for currency := range sp.Prices {
+		currencies = append(currencies, currency)
+	}
+	slices.Sort(currencies[1:])
+	return currencies
+}
+
+func (sp *subscriptionPlan) discounted(price float64) float64 {
 	if sp == nil || sp.Discount < 0 || sp.Discount >= 1 {
-		return sp.Price
+		return price
 	}
-	discountedPrice := sp.Price - (sp.Price * sp.Discount)
+	discountedPrice := price - (price * sp.Discount)
 	return math.Round(discountedPrice*100) / 100
 }
 
-func NewSubscriptionPlan(id, name string, price float64, duration string, discount float64) SubscriptionPlan {
-	return &subscriptionPlan{
+type PlanOption func(*subscriptionPlan)
+
+// WithPrice sets the list price of the plan in a fiat currency other than BaseCurrency.
+// The plan's discount applies to it like to the base price.
+func WithPrice(currency string, price float64) PlanOption {
+	return func(sp *subscriptionPlan) {
+		sp.Prices[strings.ToLower(currency)] = price
+	}
+}
+
+// WithRounding sets how prices converted from the base price are rounded for
+// currencies the plan has no list price for. Prices are rounded to cents by default.
+func WithRounding(rounding Rounding) PlanOption {
+	return func(sp *subscriptionPlan) {
+		sp.Rounding = rounding
+	}
+}
+
+func NewSubscriptionPlan(id, name string, price float64, period Period, discount float64, opts ...PlanOption) SubscriptionPlan {
+	plan := &subscriptionPlan{
 		ID:       id,
 		Name:     name,
 		Price:    price,
-		Duration: duration,
+		Prices:   map[string]float64{},
+		Period:   period,
 		Discount: discount,
 	}
+	for _, opt := range opts {
+		opt(plan)
+	}
+	return plan
 }
 
 func NewSubscriptionPlans() *[]SubscriptionPlan {
 	return &[]SubscriptionPlan{
-		NewSubscriptionPlan("1", "Monthly Plan", 10, "1 month", 0.0),
-		NewSubscriptionPlan("2", "Quarterly Plan", 30, "3 months", 0.0),
-		NewSubscriptionPlan("3", "Semi-annual Plan", 60, "6 months", 0.1),
-		NewSubscriptionPlan("4", "Yearly Plan", 120, "1 year", 0.2),
+		NewSubscriptionPlan("1", "Monthly Plan", 10, Period{Months: 1}, 0.0, WithPrice("eur", 9), WithPrice("gbp", 8), WithRounding(RoundCharm)),
+		NewSubscriptionPlan("2", "Quarterly Plan", 30, Period{Months: 3}, 0.0, WithPrice("eur", 27), WithPrice("gbp", 24), WithRounding(RoundCharm)),
+		NewSubscriptionPlan("3", "Semi-annual Plan", 60, Period{Months: 6}, 0.1, WithPrice("eur", 54), WithPrice("gbp", 48), WithRounding(RoundCharm)),
+		NewSubscriptionPlan("4", "Yearly Plan", 120, Period{Years: 1}, 0.2, WithPrice("eur", 108), WithPrice("gbp", 96), WithRounding(RoundCharm)),
 	}
 }
 
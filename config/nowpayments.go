@@ -4,6 +4,8 @@ type NowPaymentsConfig struct {
 	ApiKey    string
 	Endpoint  string
 	IPNSecret string
+	Email     string
+	Password  string
 }
 
 func NewNowPaymentsConfig() *NowPaymentsConfig {
@@ -11,5 +13,7 @@ func NewNowPaymentsConfig() *NowPaymentsConfig {
 		ApiKey:    GetEnv("NOW_PAYMENTS_API_KEY", ""),
 		Endpoint:  GetEnv("NOW_PAYMENTS_ENDPOINT", ""),
 		IPNSecret: GetEnv("NOW_PAYMENTS_IPN_SECRET", ""),
+		Email:     GetEnv("NOW_PAYMENTS_EMAIL", ""),
+		Password:  GetEnv("NOW_PAYMENTS_PASSWORD", ""),
 	}
 }
@@ -13,6 +13,7 @@ type Config struct {
 	Appwrite         *AppwriteConfig
 	Application      ApplicationConfig
 	NowPayments      *NowPaymentsConfig
+	BTCPay           *BTCPayConfig
 	CloudflareR2     *CloudflareR2Config
 	CloudflareImages *CloudflareImagesConfig
 }
@@ -32,6 +33,7 @@ func NewConfig(envFiles ...string) *Config {
 		ImageDefaults:    NewImageConfig(),
 		Appwrite:         NewAppwriteConfig(),
 		NowPayments:      NewNowPaymentsConfig(),
+		BTCPay:           NewBTCPayConfig(),
 		CloudflareImages: NewCloudflareImages(),
 		Application:      NewApplicationConfig(),
 	}
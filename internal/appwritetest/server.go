@@ -0,0 +1,618 @@
+// Package appwritetest provides an in-process fake of the Appwrite databases API for
+// tests.
+//
+// The fake keeps documents in memory and evaluates the queries the services send, so a
+// service can be tested end to end against it:
+//
+//	server := appwritetest.NewServer()
+//	defer server.Close()
+//	server.Put("db", "payments", "order-1", map[string]interface{}{"user_id": "user-1"})
+//	service := payment.NewPayment(server.Client(), userService,
+//		payment.WithDatabaseID("db"), payment.WithCollectionID("payments"))
+package appwritetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/utils"
+	"github.com/appwrite/sdk-for-go/client"
+	"github.com/appwrite/sdk-for-go/id"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const timeLayout = "2006-01-02T15:04:05.000+00:00"
+
+// WriteHook is called before a write to the document is applied, without holding the
+// server's lock, so that it can write the document itself to simulate a concurrent
+// request.
+type WriteHook func(databaseID, collectionID, documentID string)
+
+// Server is a fake Appwrite databases API backed by an httptest.Server.
+type Server struct {
+	server *httptest.Server
+
+	mu          sync.Mutex
+	sequence    int
+	lastUpdate  time.Time
+	collections map[string]map[string]map[string]interface{}
+	queries     map[string][][]string
+	writeHook   WriteHook
+}
+
+// NewServer starts a fake Appwrite databases API. It accepts any project and API key.
+func NewServer() *Server {
+	s := &Server{
+		collections: make(map[string]map[string]map[string]interface{}),
+		queries:     make(map[string][][]string),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/databases/{db}/collections/{col}/documents", s.handleList)
+	mux.HandleFunc("POST /v1/databases/{db}/collections/{col}/documents", s.handleCreate)
+	mux.HandleFunc("PATCH /v1/databases/{db}/collections/{col}/documents", s.handleBulkUpdate)
+	mux.HandleFunc("GET /v1/databases/{db}/collections/{col}/documents/{id}", s.handleGet)
+	mux.HandleFunc("PATCH /v1/databases/{db}/collections/{col}/documents/{id}", s.handleUpdate)
+	mux.HandleFunc("DELETE /v1/databases/{db}/collections/{col}/documents/{id}", s.handleDelete)
+	mux.HandleFunc("PATCH /v1/databases/{db}/collections/{col}/documents/{id}/{attribute}/increment", s.handleIncrement)
+	mux.HandleFunc("PATCH /v1/databases/{db}/collections/{col}/documents/{id}/{attribute}/decrement", s.handleIncrement)
+	s.server = httptest.NewServer(mux)
+	return s
+}
+
+// URL returns the API endpoint, including the /v1 prefix.
+func (s *Server) URL() string {
+	return s.server.URL + "/v1"
+}
+
+// Client returns an admin client of the fake.
+func (s *Server) Client() *client.Client {
+	return utils.NewAdminClient("api-key", utils.WithProject("project"), utils.WithEndpoint(s.URL()))
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Put stores a document, replacing any document with the same ID. A $createdAt in data
+// backdates the document.
+func (s *Server) Put(databaseID, collectionID, documentID string, data map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.create(databaseID, collectionID, documentID, data)
+}
+
+// Update changes attributes of a stored document and reports whether it exists.
+func (s *Server) Update(databaseID, collectionID, documentID string, data map[string]interface{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	document, ok := s.collection(databaseID, collectionID)[documentID]
+	if ok {
+		s.update(document, data)
+	}
+	return ok
+}
+
+// Document returns a copy of the stored document.
+func (s *Server) Document(databaseID, collectionID, documentID string) (map[string]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	document, ok := s.collection(databaseID, collectionID)[documentID]
+	if !ok {
+		return nil, false
+	}
+	return clone(document), true
+}
+
+// Documents returns copies of all documents of the collection in creation order.
+func (s *Server) Documents(databaseID, collectionID string) []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	documents := s.sorted(databaseID, collectionID)
+	for i := range documents {
+		documents[i] = clone(documents[i])
+	}
+	return documents
+}
+
+// Queries returns the queries of every list request made for the collection.
+func (s *Server) Queries(databaseID, collectionID string) [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]string(nil), s.queries[databaseID+"/"+collectionID]...)
+}
+
+// OnWrite sets the hook called before each single-document update or bulk update.
+func (s *Server) OnWrite(hook WriteHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writeHook = hook
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	db, col := r.PathValue("db"), r.PathValue("col")
+	queries := r.URL.Query()["queries[]"]
+	parsed, err := parseQueries(queries)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "general_query_invalid", err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries[db+"/"+col] = append(s.queries[db+"/"+col], queries)
+	total, documents, err := s.list(db, col, parsed)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "general_cursor_not_found", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"total": total, "documents": documents})
+}
+
+func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
+	db, col := r.PathValue("db"), r.PathValue("col")
+	var body struct {
+		DocumentID string                 `json:"documentId"`
+		Data       map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "document_invalid_structure", err.Error())
+		return
+	}
+	if body.DocumentID == "" || body.DocumentID == "unique()" {
+		body.DocumentID = id.Unique()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.collection(db, col)[body.DocumentID]; exists {
+		writeError(w, http.StatusConflict, "document_already_exists", "Document with the requested ID already exists.")
+		return
+	}
+	writeJSON(w, http.StatusCreated, s.create(db, col, body.DocumentID, body.Data))
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	document, ok := s.collection(r.PathValue("db"), r.PathValue("col"))[r.PathValue("id")]
+	if !ok {
+		writeNotFound(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, document)
+}
+
+func (s *Server) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	db, col, documentID := r.PathValue("db"), r.PathValue("col"), r.PathValue("id")
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "document_invalid_structure", err.Error())
+		return
+	}
+	s.runWriteHook(db, col, documentID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	document, ok := s.collection(db, col)[documentID]
+	if !ok {
+		writeNotFound(w)
+		return
+	}
+	s.update(document, body.Data)
+	writeJSON(w, http.StatusOK, document)
+}
+
+func (s *Server) handleBulkUpdate(w http.ResponseWriter, r *http.Request) {
+	db, col := r.PathValue("db"), r.PathValue("col")
+	var body struct {
+		Data    json.RawMessage `json:"data"`
+		Queries []string        `json:"queries"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "document_invalid_structure", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(body.Data, &data); err != nil {
+		writeError(w, http.StatusBadRequest, "document_invalid_structure", "data must be an object")
+		return
+	}
+	parsed, err := parseQueries(body.Queries)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "general_query_invalid", err.Error())
+		return
+	}
+	s.mu.Lock()
+	_, matched, err := s.list(db, col, append(parsed, query{Method: "limit", Values: []interface{}{float64(1 << 20)}}))
+	s.mu.Unlock()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "general_cursor_not_found", err.Error())
+		return
+	}
+	for _, document := range matched {
+		s.runWriteHook(db, col, document["$id"].(string))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Documents written by the hook are matched again, like a conditional update
+	// that runs after a concurrent write.
+	_, matched, _ = s.list(db, col, append(parsed, query{Method: "limit", Values: []interface{}{float64(1 << 20)}}))
+	updated := make([]map[string]interface{}, 0, len(matched))
+	for _, document := range matched {
+		stored := s.collection(db, col)[document["$id"].(string)]
+		s.update(stored, data)
+		updated = append(updated, stored)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"total": len(updated), "documents": updated})
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	documents := s.collection(r.PathValue("db"), r.PathValue("col"))
+	if _, ok := documents[r.PathValue("id")]; !ok {
+		writeNotFound(w)
+		return
+	}
+	delete(documents, r.PathValue("id"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleIncrement serves both increments and decrements. Like Appwrite, it refuses a
+// change that would cross the max or min with a 400.
+func (s *Server) handleIncrement(w http.ResponseWriter, r *http.Request) {
+	db, col, documentID, attribute := r.PathValue("db"), r.PathValue("col"), r.PathValue("id"), r.PathValue("attribute")
+	var body struct {
+		Value *float64 `json:"value"`
+		Max   *float64 `json:"max"`
+		Min   *float64 `json:"min"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "document_invalid_structure", err.Error())
+		return
+	}
+	delta := 1.0
+	if body.Value != nil {
+		delta = *body.Value
+	}
+	if strings.HasSuffix(r.URL.Path, "/decrement") {
+		delta = -delta
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	document, ok := s.collection(db, col)[documentID]
+	if !ok {
+		writeNotFound(w)
+		return
+	}
+	current, _ := document[attribute].(float64)
+	next := current + delta
+	if (body.Max != nil && next > *body.Max) || (body.Min != nil && next < *body.Min) {
+		writeError(w, http.StatusBadRequest, "attribute_limit_exceeded", fmt.Sprintf("Attribute %s would exceed its limit.", attribute))
+		return
+	}
+	s.update(document, map[string]interface{}{attribute: next})
+	writeJSON(w, http.StatusOK, document)
+}
+
+func (s *Server) runWriteHook(databaseID, collectionID, documentID string) {
+	s.mu.Lock()
+	hook := s.writeHook
+	s.mu.Unlock()
+	if hook != nil {
+		hook(databaseID, collectionID, documentID)
+	}
+}
+
+func (s *Server) collection(databaseID, collectionID string) map[string]map[string]interface{} {
+	key := databaseID + "/" + collectionID
+	documents, ok := s.collections[key]
+	if !ok {
+		documents = make(map[string]map[string]interface{})
+		s.collections[key] = documents
+	}
+	return documents
+}
+
+func (s *Server) create(databaseID, collectionID, documentID string, data map[string]interface{}) map[string]interface{} {
+	s.sequence++
+	now := s.tick()
+	document := clone(data)
+	document["$id"] = documentID
+	document["$sequence"] = s.sequence
+	document["$databaseId"] = databaseID
+	document["$collectionId"] = collectionID
+	if _, ok := document["$createdAt"]; !ok {
+		document["$createdAt"] = now
+	}
+	document["$updatedAt"] = now
+	document["$permissions"] = []interface{}{}
+	// Round-trip through JSON so that stored values have the types a decoded request
+	// body has.
+	encoded, _ := json.Marshal(document)
+	stored := map[string]interface{}{}
+	_ = json.Unmarshal(encoded, &stored)
+	s.collection(databaseID, collectionID)[documentID] = stored
+	return stored
+}
+
+func (s *Server) update(document, data map[string]interface{}) {
+	encoded, _ := json.Marshal(data)
+	var normalized map[string]interface{}
+	_ = json.Unmarshal(encoded, &normalized)
+	for key, value := range normalized {
+		if !strings.HasPrefix(key, "$") {
+			document[key] = value
+		}
+	}
+	document["$updatedAt"] = s.tick()
+}
+
+// tick returns the current time, strictly after the previous one, so that every write
+// changes $updatedAt.
+func (s *Server) tick() string {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	if !now.After(s.lastUpdate) {
+		now = s.lastUpdate.Add(time.Millisecond)
+	}
+	s.lastUpdate = now
+	return now.Format(timeLayout)
+}
+
+func (s *Server) sorted(databaseID, collectionID string) []map[string]interface{} {
+	documents := make([]map[string]interface{}, 0, len(s.collection(databaseID, collectionID)))
+	for _, document := range s.collection(databaseID, collectionID) {
+		documents = append(documents, document)
+	}
+	sort.Slice(documents, func(i, j int) bool {
+		return documents[i]["$sequence"].(float64) < documents[j]["$sequence"].(float64)
+	})
+	return documents
+}
+
+// list returns the number of documents matching the filters of the queries, and the
+// page of them selected by the queries.
+func (s *Server) list(databaseID, collectionID string, queries []query) (int, []map[string]interface{}, error) {
+	documents := s.sorted(databaseID, collectionID)
+	for i := len(queries) - 1; i >= 0; i-- {
+		q := queries[i]
+		if q.Method != "orderAsc" && q.Method != "orderDesc" {
+			continue
+		}
+		sort.SliceStable(documents, func(a, b int) bool {
+			c := compare(documents[a][q.Attribute], documents[b][q.Attribute])
+			if q.Method == "orderDesc" {
+				return c > 0
+			}
+			return c < 0
+		})
+	}
+	limit, offset, start := 25, 0, 0
+	var selected []string
+	for _, q := range queries {
+		switch q.Method {
+		case "limit":
+			limit = int(q.Values[0].(float64))
+		case "offset":
+			offset = int(q.Values[0].(float64))
+		case "cursorAfter":
+			// Like Appwrite, the cursor only has to exist, it need not match the filters.
+			cursor := q.Values[0].(string)
+			start = -1
+			for i, document := range documents {
+				if document["$id"] == cursor {
+					start = i + 1
+					break
+				}
+			}
+			if start < 0 {
+				return 0, nil, fmt.Errorf("cursor %s not found", cursor)
+			}
+		case "select":
+			// query.Select sends the attributes either as values or as one nested list.
+			for _, value := range q.Values {
+				if nested, ok := value.([]interface{}); ok {
+					for _, attribute := range nested {
+						selected = append(selected, fmt.Sprint(attribute))
+					}
+					continue
+				}
+				selected = append(selected, fmt.Sprint(value))
+			}
+		}
+	}
+	total := 0
+	var matched []map[string]interface{}
+	for i, document := range documents {
+		if !matchesAll(document, queries) {
+			continue
+		}
+		total++
+		if i >= start {
+			matched = append(matched, document)
+		}
+	}
+	if offset > len(matched) {
+		offset = len(matched)
+	}
+	matched = matched[offset:]
+	if limit < len(matched) {
+		matched = matched[:limit]
+	}
+	if selected != nil {
+		for i, document := range matched {
+			projected := map[string]interface{}{}
+			for key, value := range document {
+				if strings.HasPrefix(key, "$") {
+					projected[key] = value
+				}
+			}
+			for _, key := range selected {
+				if value, ok := document[key]; ok {
+					projected[key] = value
+				}
+			}
+			matched[i] = projected
+		}
+	}
+	return total, matched, nil
+}
+
+type query struct {
+	Method    string        `json:"method"`
+	Attribute string        `json:"attribute"`
+	Values    []interface{} `json:"values"`
+}
+
+func parseQueries(raw []string) ([]query, error) {
+	queries := make([]query, 0, len(raw))
+	for _, r := range raw {
+		var q query
+		if err := json.Unmarshal([]byte(r), &q); err != nil {
+			return nil, fmt.Errorf("invalid query %s: %w", r, err)
+		}
+		queries = append(queries, q)
+	}
+	return queries, nil
+}
+
+func matchesAll(document map[string]interface{}, queries []query) bool {
+	for _, q := range queries {
+		if !matches(document, q) {
+			return false
+		}
+	}
+	return true
+}
+
+func matches(document map[string]interface{}, q query) bool {
+	value := document[q.Attribute]
+	switch q.Method {
+	case "equal":
+		return anyValue(q.Values, func(v interface{}) bool { return compare(value, v) == 0 && value != nil })
+	case "notEqual":
+		return !anyValue(q.Values, func(v interface{}) bool { return compare(value, v) == 0 && value != nil })
+	case "lessThan":
+		return value != nil && compare(value, q.Values[0]) < 0
+	case "lessThanEqual":
+		return value != nil && compare(value, q.Values[0]) <= 0
+	case "greaterThan":
+		return value != nil && compare(value, q.Values[0]) > 0
+	case "greaterThanEqual":
+		return value != nil && compare(value, q.Values[0]) >= 0
+	case "isNull":
+		return value == nil
+	case "isNotNull":
+		return value != nil
+	case "contains":
+		return anyValue(q.Values, func(v interface{}) bool { return contains(value, v) })
+	case "search":
+		text, _ := value.(string)
+		term, _ := q.Values[0].(string)
+		return strings.Contains(strings.ToLower(text), strings.ToLower(term))
+	case "or", "and":
+		nested := make([]query, 0, len(q.Values))
+		for _, v := range q.Values {
+			encoded, _ := json.Marshal(v)
+			var n query
+			_ = json.Unmarshal(encoded, &n)
+			nested = append(nested, n)
+		}
+		if q.Method == "and" {
+			return matchesAll(document, nested)
+		}
+		for _, n := range nested {
+			if matches(document, n) {
+				return true
+			}
+		}
+		return false
+	default:
+		// limit, offset, cursors, order and select do not filter.
+		return true
+	}
+}
+
+func anyValue(values []interface{}, match func(interface{}) bool) bool {
+	for _, v := range values {
+		if match(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(value, element interface{}) bool {
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if compare(item, element) == 0 {
+				return true
+			}
+		}
+	case string:
+		s, _ := element.(string)
+		return strings.Contains(v, s)
+	}
+	return false
+}
+
+// compare orders numbers numerically, booleans false first, and everything else by its
+// string form, which is the order of Appwrite's ISO 8601 datetimes.
+func compare(a, b interface{}) int {
+	switch x := a.(type) {
+	case float64:
+		if y, ok := b.(float64); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0
+			case !x:
+				return -1
+			}
+			return 1
+		}
+	case nil:
+		if b == nil {
+			return 0
+		}
+		return -1
+	}
+	if b == nil {
+		return 1
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func clone(document map[string]interface{}) map[string]interface{} {
+	encoded, _ := json.Marshal(document)
+	copied := map[string]interface{}{}
+	_ = json.Unmarshal(encoded, &copied)
+	return copied
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeNotFound(w http.ResponseWriter) {
+	writeError(w, http.StatusNotFound, "document_not_found", "Document with the requested ID could not be found.")
+}
+
+func writeError(w http.ResponseWriter, status int, errorType, message string) {
+	writeJSON(w, status, map[string]interface{}{"message": message, "code": status, "type": errorType})
+}
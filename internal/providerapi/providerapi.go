@@ -0,0 +1,60 @@
+// Package providerapi turns the HTTP responses of the payment providers' APIs into errors.
+package providerapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"resty.dev/v3"
+)
+
+// APIError is returned when a provider's API answers with an unexpected HTTP status.
+type APIError struct {
+	Operation  string
+	StatusCode int
+	Code       string
+	Message    string
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("%s returned status %d (%s): %s", e.Operation, e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("%s returned status %d", e.Operation, e.StatusCode)
+}
+
+// Temporary reports whether the request may succeed when tried again later.
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// IsStatus reports whether err is an *APIError with the given HTTP status code.
+func IsStatus(err error, statusCode int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == statusCode
+}
+
+// ErrorBody is implemented by the error bodies passed to resty's SetError.
+type ErrorBody interface {
+	ErrorDetails() (code, message string)
+}
+
+// CheckResponse returns an *APIError for a status other than expectedStatus.
+func CheckResponse(operation string, resp *resty.Response, err error, expectedStatus int) error {
+	if err != nil {
+		return fmt.Errorf("%s request failed: %w", operation, err)
+	}
+	if resp.StatusCode() == expectedStatus {
+		return nil
+	}
+	apiErr := &APIError{
+		Operation:  operation,
+		StatusCode: resp.StatusCode(),
+		Body:       resp.String(),
+	}
+	if body, ok := resp.Error().(ErrorBody); ok && body != nil {
+		apiErr.Code, apiErr.Message = body.ErrorDetails()
+	}
+	return apiErr
+}
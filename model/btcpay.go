@@ -0,0 +1,136 @@
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// BTCPay Server invoice statuses, see the Greenfield API documentation.
+const (
+	BTCPayStatusNew        = "New"
+	BTCPayStatusProcessing = "Processing"
+	BTCPayStatusSettled    = "Settled"
+	BTCPayStatusExpired    = "Expired"
+	BTCPayStatusInvalid    = "Invalid"
+
+	BTCPayAdditionalStatusPaidPartial = "PaidPartial"
+)
+
+type BTCPayInvoiceRequest struct {
+	Amount   string                 `json:"amount"`
+	Currency string                 `json:"currency"`
+	Metadata BTCPayInvoiceMetadata  `json:"metadata"`
+	Checkout *BTCPayCheckoutOptions `json:"checkout,omitempty"`
+}
+
+type BTCPayInvoiceMetadata struct {
+	OrderID  string `json:"orderId,omitempty"`
+	PlanID   string `json:"planId,omitempty"`
+	ItemDesc string `json:"itemDesc,omitempty"`
+}
+
+type BTCPayCheckoutOptions struct {
+	RedirectURL    string   `json:"redirectURL,omitempty"`
+	PaymentMethods []string `json:"paymentMethods,omitempty"`
+}
+
+// NewBTCPayInvoiceRequest builds an invoice for a checkout.
+func NewBTCPayInvoiceRequest(request *CheckoutRequest) *BTCPayInvoiceRequest {
+	invoiceRequest := &BTCPayInvoiceRequest{
+		Amount:   strconv.FormatFloat(request.PriceAmount, 'f', 2, 64),
+		Currency: strings.ToUpper(request.PriceCurrency),
+		Metadata: BTCPayInvoiceMetadata{
+			OrderID:  request.OrderID,
+			PlanID:   request.PlanID,
+			ItemDesc: request.Description,
+		},
+	}
+	if request.RedirectURL != "" || request.PayCurrency != "" {
+		invoiceRequest.Checkout = &BTCPayCheckoutOptions{RedirectURL: request.RedirectURL}
+		if request.PayCurrency != "" {
+			invoiceRequest.Checkout.PaymentMethods = []string{strings.ToUpper(request.PayCurrency)}
+		}
+	}
+	return invoiceRequest
+}
+
+type BTCPayInvoice struct {
+	ID               string                `json:"id"`
+	StoreID          string                `json:"storeId"`
+	Amount           string                `json:"amount"`
+	Currency         string                `json:"currency"`
+	Status           string                `json:"status"`
+	AdditionalStatus string                `json:"additionalStatus"`
+	CheckoutLink     string                `json:"checkoutLink"`
+	CreatedTime      int64                 `json:"createdTime"`
+	ExpirationTime   int64                 `json:"expirationTime"`
+	Metadata         BTCPayInvoiceMetadata `json:"metadata"`
+}
+
+// PaymentStatus maps the invoice status onto the payment status used for NowPayments.
+func (i *BTCPayInvoice) PaymentStatus() PaymentStatusEnum {
+	switch i.Status {
+	case BTCPayStatusNew:
+		if i.AdditionalStatus == BTCPayAdditionalStatusPaidPartial {
+			return StatusPartiallyPaid
+		}
+		return StatusWaiting
+	case BTCPayStatusProcessing:
+		return StatusConfirming
+	case BTCPayStatusSettled:
+		return StatusFinished
+	case BTCPayStatusExpired:
+		return StatusExpired
+	case BTCPayStatusInvalid:
+		return StatusFailed
+	default:
+		return PaymentStatusEnum(strings.ToLower(i.Status))
+	}
+}
+
+func (i *BTCPayInvoice) ToPaymentUpdate() *PaymentUpdate {
+	priceAmount, _ := strconv.ParseFloat(i.Amount, 64)
+	return &PaymentUpdate{
+		Provider:         ProviderBTCPay,
+		OrderID:          i.Metadata.OrderID,
+		PaymentID:        i.ID,
+		InvoiceID:        i.ID,
+		Status:           i.PaymentStatus(),
+		OrderDescription: i.Metadata.ItemDesc,
+		PriceAmount:      priceAmount,
+		PriceCurrency:    strings.ToLower(i.Currency),
+	}
+}
+
+// BTCPayWebhookEvent is the envelope of every BTCPay Server webhook delivery.
+type BTCPayWebhookEvent struct {
+	DeliveryID         string `json:"deliveryId"`
+	WebhookID          string `json:"webhookId"`
+	OriginalDeliveryID string `json:"originalDeliveryId"`
+	IsRedelivery       bool   `json:"isRedelivery"`
+	Type               string `json:"type"`
+	Timestamp          int64  `json:"timestamp"`
+	StoreID            string `json:"storeId"`
+	InvoiceID          string `json:"invoiceId"`
+}
+
+// BTCPayError is the body BTCPay Server sends along with a non-2xx status.
+type BTCPayError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ErrorDetails returns the code and message of the error body.
+func (e *BTCPayError) ErrorDetails() (string, string) {
+	return e.Code, e.Message
+}
+
+// SignBTCPayPayload computes the "sha256=<hex HMAC>" value of the BTCPay-Sig header.
+func SignBTCPayPayload(payload []byte, webhookSecret string) string {
+	h := hmac.New(sha256.New, []byte(webhookSecret))
+	h.Write(payload)
+	return "sha256=" + hex.EncodeToString(h.Sum(nil))
+}
@@ -17,6 +17,7 @@ type ChartData struct {
 	Label      string     `json:"label"`
 	UserID     string     `json:"user_id"`
 	Collection Collection `json:"collection"`
+	Currency   string     `json:"currency,omitempty"`
 }
 
 // Validate validates that the Collection is within the allowed values
@@ -0,0 +1,164 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/config"
+	"github.com/appwrite/sdk-for-go/models"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+type DiscountType string
+
+const (
+	DiscountPercentage DiscountType = "percentage"
+	DiscountFixed      DiscountType = "fixed"
+)
+
+var (
+	ErrInvalidCouponCode     = errors.New("invalid coupon code")
+	ErrCouponNotFound        = errors.New("coupon not found")
+	ErrCouponDisabled        = errors.New("coupon is disabled")
+	ErrCouponNotYetValid     = errors.New("coupon is not valid yet")
+	ErrCouponExpired         = errors.New("coupon has expired")
+	ErrCouponNotApplicable   = errors.New("coupon does not apply to this plan")
+	ErrCouponExhausted       = errors.New("coupon has been fully redeemed")
+	ErrCouponUserLimit       = errors.New("coupon redemption limit reached for this user")
+	ErrCouponFirstPurchase   = errors.New("coupon is only valid for a first purchase")
+	ErrCouponInvalidDiscount = errors.New("coupon has an invalid discount")
+	ErrCouponCurrency        = errors.New("coupon is not valid in this currency")
+	couponCodePattern        = regexp.MustCompile(`^[A-Z0-9][A-Z0-9_-]{2,35}$`)
+)
+
+// CouponData is a promo code stored in the coupons collection. The normalized code is
+// also the document ID, so a code can only exist once.
+//
+// Value is a percentage (15 means 15% off) for DiscountPercentage and an amount in
+// Currency for DiscountFixed; a fixed coupon without a currency is in
+// config.BaseCurrency and only applies to prices in that currency. An empty PlanIDs
+// list makes the coupon valid for every plan, and a zero MaxRedemptions or
+// MaxRedemptionsPerUser means unlimited.
+type CouponData struct {
+	Code                  string       `json:"code"`
+	DiscountType          DiscountType `json:"discount_type"`
+	Value                 float64      `json:"value"`
+	Currency              string       `json:"currency,omitempty"`
+	PlanIDs               []string     `json:"plan_ids,omitempty"`
+	ValidFrom             string       `json:"valid_from,omitempty"`
+	ValidUntil            string       `json:"valid_until,omitempty"`
+	MaxRedemptions        int64        `json:"max_redemptions,omitempty"`
+	MaxRedemptionsPerUser int64        `json:"max_redemptions_per_user,omitempty"`
+	Redemptions           int64        `json:"redemptions"`
+	FirstPurchaseOnly     bool         `json:"first_purchase_only,omitempty"`
+	Disabled              bool         `json:"disabled,omitempty"`
+}
+
+type Coupon struct {
+	*models.Document
+	*CouponData
+}
+
+func NewCoupon(document *models.Document) (*Coupon, error) {
+	var couponData CouponData
+	if err := document.Decode(&couponData); err != nil {
+		return nil, err
+	}
+	return &Coupon{
+		Document:   document,
+		CouponData: &couponData,
+	}, nil
+}
+
+// NormalizeCouponCode trims and upper-cases a code entered by a user and checks that
+// it can be used as a document ID.
+func NormalizeCouponCode(code string) (string, error) {
+	normalized := strings.ToUpper(strings.TrimSpace(code))
+	if !couponCodePattern.MatchString(normalized) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidCouponCode, code)
+	}
+	return normalized, nil
+}
+
+// Validate checks that the discount is well-formed.
+func (c *CouponData) Validate() error {
+	switch c.DiscountType {
+	case DiscountPercentage:
+		if c.Value <= 0 || c.Value > 100 {
+			return fmt.Errorf("%w: percentage %g must be in (0, 100]", ErrCouponInvalidDiscount, c.Value)
+		}
+	case DiscountFixed:
+		if c.Value <= 0 {
+			return fmt.Errorf("%w: fixed amount %g must be positive", ErrCouponInvalidDiscount, c.Value)
+		}
+	default:
+		return fmt.Errorf("%w: unknown discount type %q", ErrCouponInvalidDiscount, c.DiscountType)
+	}
+	return nil
+}
+
+// CheckPlan reports whether the coupon can be used for the plan at the given time. It
+// does not check redemption limits, which need the redemption history.
+func (c *CouponData) CheckPlan(planID string, now time.Time) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	if c.Disabled {
+		return ErrCouponDisabled
+	}
+	if c.ValidFrom != "" {
+		validFrom, err := time.Parse(time.RFC3339, c.ValidFrom)
+		if err != nil {
+			return fmt.Errorf("invalid valid_from %q on coupon %s: %w", c.ValidFrom, c.Code, err)
+		}
+		if now.Before(validFrom) {
+			return ErrCouponNotYetValid
+		}
+	}
+	if c.ValidUntil != "" {
+		validUntil, err := time.Parse(time.RFC3339, c.ValidUntil)
+		if err != nil {
+			return fmt.Errorf("invalid valid_until %q on coupon %s: %w", c.ValidUntil, c.Code, err)
+		}
+		if !now.Before(validUntil) {
+			return ErrCouponExpired
+		}
+	}
+	if len(c.PlanIDs) > 0 && !slices.Contains(c.PlanIDs, planID) {
+		return ErrCouponNotApplicable
+	}
+	return nil
+}
+
+// CheckCurrency reports whether the coupon can discount a price in the given fiat
+// currency. Percentage coupons apply to any currency, fixed ones only to their own.
+func (c *CouponData) CheckCurrency(currency string) error {
+	if c.DiscountType != DiscountFixed {
+		return nil
+	}
+	couponCurrency := c.Currency
+	if couponCurrency == "" {
+		couponCurrency = config.BaseCurrency
+	}
+	if !strings.EqualFold(couponCurrency, currency) {
+		return fmt.Errorf("%w: coupon %s is in %s, the price in %s", ErrCouponCurrency, c.Code, couponCurrency, currency)
+	}
+	return nil
+}
+
+// Apply returns the price after the coupon's discount, rounded to cents. A fixed
+// discount never makes the price negative; check its currency with CheckCurrency
+// first.
+func (c *CouponData) Apply(price float64) float64 {
+	discounted := price
+	switch c.DiscountType {
+	case DiscountPercentage:
+		discounted = price - price*c.Value/100
+	case DiscountFixed:
+		discounted = price - c.Value
+	}
+	return math.Max(0, math.Round(discounted*100)/100)
+}
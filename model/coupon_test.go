@@ -0,0 +1,120 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCouponCheckPlanAndApply(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name        string
+		coupon      CouponData
+		planID      string
+		price       float64
+		expectPrice float64
+		expectErr   error
+	}{
+		{
+			name:        "Percentage Discount",
+			coupon:      CouponData{Code: "SUMMER15", DiscountType: DiscountPercentage, Value: 15},
+			planID:      "1",
+			price:       10,
+			expectPrice: 8.5,
+		},
+		{
+			name:        "Fixed Discount Never Goes Negative",
+			coupon:      CouponData{Code: "FIVEOFF", DiscountType: DiscountFixed, Value: 50},
+			planID:      "1",
+			price:       10,
+			expectPrice: 0,
+		},
+		{
+			name:        "Eligible Plan",
+			coupon:      CouponData{Code: "YEARLY", DiscountType: DiscountFixed, Value: 20, PlanIDs: []string{"4"}},
+			planID:      "4",
+			price:       96,
+			expectPrice: 76,
+		},
+		{
+			name:      "Ineligible Plan",
+			coupon:    CouponData{Code: "YEARLY", DiscountType: DiscountFixed, Value: 20, PlanIDs: []string{"4"}},
+			planID:    "1",
+			expectErr: ErrCouponNotApplicable,
+		},
+		{
+			name:      "Not Yet Valid",
+			coupon:    CouponData{Code: "LATER", DiscountType: DiscountPercentage, Value: 10, ValidFrom: "2025-07-01T00:00:00Z"},
+			planID:    "1",
+			expectErr: ErrCouponNotYetValid,
+		},
+		{
+			name:      "Expired",
+			coupon:    CouponData{Code: "EARLIER", DiscountType: DiscountPercentage, Value: 10, ValidUntil: "2025-06-01T00:00:00Z"},
+			planID:    "1",
+			expectErr: ErrCouponExpired,
+		},
+		{
+			name:      "Disabled",
+			coupon:    CouponData{Code: "OFF", DiscountType: DiscountPercentage, Value: 10, Disabled: true},
+			planID:    "1",
+			expectErr: ErrCouponDisabled,
+		},
+		{
+			name:      "Invalid Percentage",
+			coupon:    CouponData{Code: "TOOMUCH", DiscountType: DiscountPercentage, Value: 150},
+			planID:    "1",
+			expectErr: ErrCouponInvalidDiscount,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.coupon.CheckPlan(tc.planID, now)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("Expected error %v, got %v", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if got := tc.coupon.Apply(tc.price); got != tc.expectPrice {
+				t.Errorf("Expected price %v, got %v", tc.expectPrice, got)
+			}
+		})
+	}
+}
+
+func TestCouponCheckCurrency(t *testing.T) {
+	testCases := []struct {
+		name      string
+		coupon    CouponData
+		currency  string
+		expectErr error
+	}{
+		{name: "Percentage In Any Currency", coupon: CouponData{DiscountType: DiscountPercentage, Value: 10}, currency: "gbp"},
+		{name: "Fixed In Base Currency", coupon: CouponData{DiscountType: DiscountFixed, Value: 5}, currency: "usd"},
+		{name: "Fixed In Own Currency", coupon: CouponData{DiscountType: DiscountFixed, Value: 5, Currency: "EUR"}, currency: "eur"},
+		{name: "Fixed In Other Currency", coupon: CouponData{DiscountType: DiscountFixed, Value: 5, Currency: "eur"}, currency: "usd", expectErr: ErrCouponCurrency},
+		{name: "Fixed Without Currency", coupon: CouponData{DiscountType: DiscountFixed, Value: 5}, currency: "eur", expectErr: ErrCouponCurrency},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.coupon.CheckCurrency(tc.currency); !errors.Is(err, tc.expectErr) {
+				t.Errorf("Expected error %v, got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestNormalizeCouponCode(t *testing.T) {
+	if code, err := NormalizeCouponCode("  summer-15 "); err != nil || code != "SUMMER-15" {
+		t.Errorf("Expected SUMMER-15, got %q, %v", code, err)
+	}
+	for _, code := range []string{"", "ab", "-LEADING", "WITH SPACE", "TOO-LONG-FOR-AN-APPWRITE-DOCUMENT-ID1"} {
+		if _, err := NormalizeCouponCode(code); !errors.Is(err, ErrInvalidCouponCode) {
+			t.Errorf("Expected %q to be rejected, got %v", code, err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	"errors"
+	"fmt"
 )
 
 type PaymentStatusEnum string
@@ -33,3 +34,63 @@ func (p PaymentStatusEnum) Validate() error {
 	}
 	return nil
 }
+
+// ErrIllegalTransition is matched by every *TransitionError.
+var ErrIllegalTransition = errors.New("illegal payment status transition")
+
+// ErrDuplicateUpdate is returned for a redelivered update that was applied already.
+var ErrDuplicateUpdate = errors.New("payment update already applied")
+
+// TransitionError reports a status change that the payment state machine does not allow.
+type TransitionError struct {
+	From PaymentStatusEnum
+	To   PaymentStatusEnum
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("illegal payment status transition from %q to %q", e.From, e.To)
+}
+
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrIllegalTransition
+}
+
+// paymentTransitions lists, for every status, the statuses a payment may move to next.
+// An expired payment may still be completed because NowPayments keeps processing funds
+// that arrive after the payment window has closed, and a finished payment may still
+// fail when it is charged back.
+var paymentTransitions = map[PaymentStatusEnum][]PaymentStatusEnum{
+	StatusWaiting:       {StatusConfirming, StatusConfirmed, StatusSending, StatusPartiallyPaid, StatusFinished, StatusFailed, StatusExpired},
+	StatusConfirming:    {StatusConfirmed, StatusSending, StatusPartiallyPaid, StatusFinished, StatusFailed, StatusExpired},
+	StatusConfirmed:     {StatusSending, StatusPartiallyPaid, StatusFinished, StatusFailed},
+	StatusSending:       {StatusPartiallyPaid, StatusFinished, StatusFailed},
+	StatusPartiallyPaid: {StatusConfirming, StatusConfirmed, StatusSending, StatusFinished, StatusFailed, StatusExpired, StatusRefunded},
+	StatusFinished:      {StatusRefunded, StatusFailed},
+	StatusFailed:        {StatusRefunded},
+	StatusExpired:       {StatusConfirming, StatusConfirmed, StatusSending, StatusPartiallyPaid, StatusFinished},
+	StatusRefunded:      {},
+}
+
+// CanTransitionTo reports whether a payment in status p may move to next.
+func (p PaymentStatusEnum) CanTransitionTo(next PaymentStatusEnum) bool {
+	for _, allowed := range paymentTransitions[p] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTerminal reports whether NowPayments has stopped processing a payment in status p.
+func (p PaymentStatusEnum) IsTerminal() bool {
+	switch p {
+	case StatusFinished, StatusFailed, StatusRefunded, StatusExpired:
+		return true
+	}
+	return false
+}
+
+// NonTerminalStatuses returns every status of a payment that is still being processed.
+func NonTerminalStatuses() []PaymentStatusEnum {
+	return []PaymentStatusEnum{StatusWaiting, StatusConfirming, StatusConfirmed, StatusSending, StatusPartiallyPaid}
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPaymentStatusTransitions(t *testing.T) {
+	testCases := []struct {
+		from    PaymentStatusEnum
+		to      PaymentStatusEnum
+		allowed bool
+	}{
+		{StatusWaiting, StatusConfirming, true},
+		{StatusConfirming, StatusFinished, true},
+		{StatusPartiallyPaid, StatusFinished, true},
+		{StatusFinished, StatusRefunded, true},
+		{StatusFinished, StatusFailed, true},
+		{StatusExpired, StatusFinished, true},
+		{StatusFinished, StatusWaiting, false},
+		{StatusFinished, StatusConfirming, false},
+		{StatusRefunded, StatusFinished, false},
+		{StatusFailed, StatusFinished, false},
+		{StatusConfirmed, StatusWaiting, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.from)+"->"+string(tc.to), func(t *testing.T) {
+			if got := tc.from.CanTransitionTo(tc.to); got != tc.allowed {
+				t.Errorf("CanTransitionTo = %v; want %v", got, tc.allowed)
+			}
+		})
+	}
+
+	var err error = &TransitionError{From: StatusRefunded, To: StatusFinished}
+	if !errors.Is(err, ErrIllegalTransition) {
+		t.Errorf("Expected TransitionError to match ErrIllegalTransition")
+	}
+}
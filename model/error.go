@@ -1,5 +1,11 @@
 package model
 
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
 // ErrorPageData holds the information to display on the error page
 type ErrorPageData struct {
 	ErrorCode       int    // e.g., 404, 500
@@ -10,3 +16,45 @@ type ErrorPageData struct {
 	RequestID       string // Optional: For tracking/reporting internal errors
 	ShowSupportInfo bool   // Whether to show contact support or detailed request info
 }
+
+// NewErrorPageData describes an error for the error page. The Appwrite message is only
+// shown for invalid requests, where it tells the user what to correct; other errors get
+// a generic message.
+func NewErrorPageData(err error) *ErrorPageData {
+	data := &ErrorPageData{
+		ErrorCode:    http.StatusInternalServerError,
+		ErrorTitle:   "Internal Server Error",
+		ErrorMessage: "Something went wrong on our side. Please try again later.",
+		Timestamp:    time.Now().UTC().Format(time.RFC3339),
+	}
+	switch {
+	case errors.Is(err, ErrNotFound):
+		data.ErrorCode, data.ErrorTitle = http.StatusNotFound, "Page Not Found"
+		data.ErrorMessage = "The page or item you are looking for does not exist or has been removed."
+	case errors.Is(err, ErrUnauthorized):
+		data.ErrorCode, data.ErrorTitle = http.StatusUnauthorized, "Sign In Required"
+		data.ErrorMessage = "Please sign in to continue."
+	case errors.Is(err, ErrForbidden):
+		data.ErrorCode, data.ErrorTitle = http.StatusForbidden, "Access Denied"
+		data.ErrorMessage = "You do not have permission to view this page."
+	case errors.Is(err, ErrConflict):
+		data.ErrorCode, data.ErrorTitle = http.StatusConflict, "Conflict"
+		data.ErrorMessage = "This item already exists or was changed in the meantime."
+	case errors.Is(err, ErrRateLimited):
+		data.ErrorCode, data.ErrorTitle = http.StatusTooManyRequests, "Too Many Requests"
+		data.ErrorMessage = "You are doing that too often. Please wait a moment and try again."
+	case errors.Is(err, ErrValidation):
+		data.ErrorCode, data.ErrorTitle = http.StatusBadRequest, "Invalid Request"
+		data.ErrorMessage = "The request could not be processed."
+		var serviceErr *ServiceError
+		if errors.As(err, &serviceErr) && serviceErr.Message != "" {
+			data.ErrorMessage = serviceErr.Message
+		}
+	case errors.Is(err, ErrUnavailable):
+		data.ErrorCode, data.ErrorTitle = http.StatusServiceUnavailable, "Service Unavailable"
+		data.ErrorMessage = "The service is temporarily unavailable. Please try again in a few minutes."
+	}
+	data.IsUserError = data.ErrorCode < http.StatusInternalServerError
+	data.ShowSupportInfo = !data.IsUserError
+	return data
+}
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestNewErrorPageData(t *testing.T) {
+	testCases := []struct {
+		name          string
+		err           error
+		expectCode    int
+		expectMessage string
+	}{
+		{name: "Not Found", err: &ServiceError{Kind: ErrNotFound, Code: 404, Message: "Document with the requested ID could not be found."}, expectCode: 404, expectMessage: "The page or item you are looking for does not exist or has been removed."},
+		{name: "Validation Shows Message", err: &ServiceError{Kind: ErrValidation, Code: 400, Message: "Invalid email param."}, expectCode: 400, expectMessage: "Invalid email param."},
+		{name: "Rate Limited", err: fmt.Errorf("failed to create session: %w", &ServiceError{Kind: ErrRateLimited, Code: 429}), expectCode: 429, expectMessage: "You are doing that too often. Please wait a moment and try again."},
+		{name: "Unavailable", err: &ServiceError{Kind: ErrUnavailable, Code: 503, Message: "Server Error"}, expectCode: 503, expectMessage: "The service is temporarily unavailable. Please try again in a few minutes."},
+		{name: "Unknown Error", err: errors.New("boom"), expectCode: 500, expectMessage: "Something went wrong on our side. Please try again later."},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := NewErrorPageData(tc.err)
+			if data.ErrorCode != tc.expectCode || data.ErrorMessage != tc.expectMessage {
+				t.Errorf("Expected %d %q, got %d %q", tc.expectCode, tc.expectMessage, data.ErrorCode, data.ErrorMessage)
+			}
+			if data.IsUserError != (tc.expectCode < 500) || data.ShowSupportInfo == data.IsUserError {
+				t.Errorf("Unexpected IsUserError %v and ShowSupportInfo %v for %d", data.IsUserError, data.ShowSupportInfo, data.ErrorCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/appwrite/sdk-for-go/client"
+	"net/http"
+)
+
+// Kinds of failures reported by Appwrite, independent of the service that ran into
+// them. Every service error wraps one of them, so callers can test for, say, a missing
+// document with errors.Is(err, model.ErrNotFound).
+var (
+	ErrValidation   = errors.New("invalid request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrUnavailable  = errors.New("service unavailable")
+)
+
+// ServiceError is an Appwrite failure translated by TranslateError. Kind is one of the
+// sentinel errors above; Code, Type and Message are those of the Appwrite response,
+// such as 404, "document_not_found" and "Document with the requested ID could not be
+// found.". The SDK error stays reachable with errors.As.
+type ServiceError struct {
+	Kind    error
+	Code    int
+	Type    string
+	Message string
+	Err     error
+}
+
+func (e *ServiceError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return e.Err.Error()
+}
+
+func (e *ServiceError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// TranslateError turns an error of the Appwrite SDK into a *ServiceError. Other errors,
+// including ones translated already, are returned unchanged. Services wrap the result
+// with %w:
+//
+//	return nil, fmt.Errorf("failed to get user %s: %w", userId, model.TranslateError(err))
+func TranslateError(err error) error {
+	var serviceErr *ServiceError
+	if err == nil || errors.As(err, &serviceErr) {
+		return err
+	}
+	var appwriteErr *client.AppwriteError
+	if !errors.As(err, &appwriteErr) {
+		return err
+	}
+	translated := &ServiceError{
+		Code:    appwriteErr.GetStatusCode(),
+		Message: appwriteErr.GetMessage(),
+		Err:     err,
+	}
+	var response struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	}
+	if json.Unmarshal([]byte(appwriteErr.GetResponse()), &response) == nil {
+		translated.Type = response.Type
+		if response.Message != "" {
+			translated.Message = response.Message
+		}
+	}
+	translated.Kind = errorKind(translated.Code)
+	return translated
+}
+
+func errorKind(code int) error {
+	switch {
+	case code == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case code == http.StatusForbidden:
+		return ErrForbidden
+	case code == http.StatusNotFound:
+		return ErrNotFound
+	case code == http.StatusConflict:
+		return ErrConflict
+	case code == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case code >= 400 && code < 500:
+		return ErrValidation
+	default:
+		return ErrUnavailable
+	}
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"github.com/appwrite/sdk-for-go/appwrite"
+	"github.com/appwrite/sdk-for-go/client"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// appwriteError fails a request against a fake Appwrite server with the given status and
+// body, since the SDK error cannot be built outside the SDK.
+func appwriteError(t *testing.T, status int, body string) error {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+	users := appwrite.NewUsers(appwrite.NewClient(appwrite.WithEndpoint(server.URL), appwrite.WithProject("project")))
+	_, err := users.Get("user-1")
+	if err == nil {
+		t.Fatalf("Expected the fake server to fail the request")
+	}
+	return err
+}
+
+func TestTranslateError(t *testing.T) {
+	testCases := []struct {
+		name        string
+		status      int
+		body        string
+		expectKind  error
+		expectType  string
+		expectError string
+	}{
+		{name: "Not Found", status: 404, body: `{"message":"User with the requested ID could not be found.","code":404,"type":"user_not_found"}`, expectKind: ErrNotFound, expectType: "user_not_found", expectError: "User with the requested ID could not be found."},
+		{name: "Unauthorized", status: 401, body: `{"message":"Invalid credentials.","code":401,"type":"user_invalid_credentials"}`, expectKind: ErrUnauthorized, expectType: "user_invalid_credentials", expectError: "Invalid credentials."},
+		{name: "Forbidden", status: 403, body: `{"message":"Missing scope.","code":403,"type":"general_unauthorized_scope"}`, expectKind: ErrForbidden, expectType: "general_unauthorized_scope", expectError: "Missing scope."},
+		{name: "Conflict", status: 409, body: `{"message":"Document already exists.","code":409,"type":"document_already_exists"}`, expectKind: ErrConflict, expectType: "document_already_exists", expectError: "Document already exists."},
+		{name: "Rate Limited", status: 429, body: `{"message":"Rate limit exceeded.","code":429,"type":"general_rate_limit_exceeded"}`, expectKind: ErrRateLimited, expectType: "general_rate_limit_exceeded", expectError: "Rate limit exceeded."},
+		{name: "Validation", status: 400, body: `{"message":"Invalid email param.","code":400,"type":"general_argument_invalid"}`, expectKind: ErrValidation, expectType: "general_argument_invalid", expectError: "Invalid email param."},
+		{name: "Server Error", status: 503, body: `{"message":"Server Error","code":503,"type":"general_server_error"}`, expectKind: ErrUnavailable, expectType: "general_server_error", expectError: "Server Error"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sdkErr := appwriteError(t, tc.status, tc.body)
+			err := fmt.Errorf("failed to get user user-1: %w", TranslateError(sdkErr))
+			if !errors.Is(err, tc.expectKind) {
+				t.Errorf("Expected %v, got %v", tc.expectKind, err)
+			}
+			var serviceErr *ServiceError
+			if !errors.As(err, &serviceErr) {
+				t.Fatalf("Expected a *ServiceError, got %T", err)
+			}
+			if serviceErr.Code != tc.status || serviceErr.Type != tc.expectType || serviceErr.Error() != tc.expectError {
+				t.Errorf("Unexpected service error: %+v", serviceErr)
+			}
+			var appwriteErr *client.AppwriteError
+			if !errors.As(err, &appwriteErr) || appwriteErr.GetStatusCode() != tc.status {
+				t.Errorf("Expected the SDK error to stay reachable, got %v", err)
+			}
+			if again := TranslateError(err); again != err {
+				t.Errorf("Expected a translated error to be returned unchanged, got %v", again)
+			}
+		})
+	}
+
+	other := errors.New("failed to decode document")
+	if TranslateError(other) != other || TranslateError(nil) != nil {
+		t.Errorf("Expected errors not coming from the SDK to be returned unchanged")
+	}
+}
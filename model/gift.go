@@ -0,0 +1,152 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/config"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// DefaultGiftRedeemWindow is how long the recipient of a gift sent to an email address
+// has to redeem it.
+const DefaultGiftRedeemWindow = 365 * 24 * time.Hour
+
+var (
+	ErrInvalidBeneficiary    = errors.New("invalid gift beneficiary")
+	ErrGiftNotFound          = errors.New("gift not found")
+	ErrGiftAlreadyRedeemed   = errors.New("gift has already been redeemed")
+	ErrGiftExpired           = errors.New("gift can no longer be redeemed")
+	ErrGiftNotPaid           = errors.New("gift has not been paid yet")
+	ErrGiftRecipientMismatch = errors.New("gift was sent to another email address")
+	ErrGiftEmailNotVerified  = errors.New("email address must be verified to redeem a gift")
+)
+
+// Gift is a subscription bought for another account. The beneficiary is either an
+// existing user, who is entitled as soon as the payment finishes, or an email address.
+// A gift to an email address carries a code that the recipient redeems once signed in
+// with that address; the subscription then starts on redemption, and the code must be
+// redeemed before RedeemBy.
+type Gift struct {
+	UserID   string
+	Email    string
+	Code     string
+	RedeemBy time.Time
+}
+
+// NewGift creates a gift for a beneficiary given as a user ID or an email address. A
+// redeemWindow of 0 means DefaultGiftRedeemWindow.
+func NewGift(beneficiary string, redeemWindow time.Duration) (*Gift, error) {
+	beneficiary = strings.TrimSpace(beneficiary)
+	if beneficiary == "" {
+		return nil, fmt.Errorf("%w: a user ID or an email address is required", ErrInvalidBeneficiary)
+	}
+	if !strings.Contains(beneficiary, "@") {
+		return &Gift{UserID: beneficiary}, nil
+	}
+	address, err := mail.ParseAddress(beneficiary)
+	if err != nil || address.Address != beneficiary {
+		return nil, fmt.Errorf("%w: %q is not a valid email address", ErrInvalidBeneficiary, beneficiary)
+	}
+	code, err := NewGiftCode()
+	if err != nil {
+		return nil, err
+	}
+	if redeemWindow <= 0 {
+		redeemWindow = DefaultGiftRedeemWindow
+	}
+	return &Gift{
+		Email:    strings.ToLower(beneficiary),
+		Code:     code,
+		RedeemBy: time.Now().UTC().Add(redeemWindow),
+	}, nil
+}
+
+// NewGiftCode returns a random code of the form GIFT-XXXX-XXXX-XXXX-XXXX.
+func NewGiftCode() (string, error) {
+	random := make([]byte, 10)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("could not generate gift code: %w", err)
+	}
+	encoded := base32.StdEncoding.EncodeToString(random)
+	groups := make([]string, 0, 5)
+	groups = append(groups, "GIFT")
+	for i := 0; i < len(encoded); i += 4 {
+		groups = append(groups, encoded[i:i+4])
+	}
+	return strings.Join(groups, "-"), nil
+}
+
+// NormalizeGiftCode trims and upper-cases a gift code entered by a user.
+func NormalizeGiftCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// WithGift makes the payment a gift. The buyer passed to the payment data constructor
+// is recorded as the purchaser and the beneficiary becomes the user of the payment, so
+// the subscription is granted to them. For an existing user, activeUntil should be the
+// beneficiary's active expiry. A gift to an email address has no user and no expiry
+// until it is redeemed, see RedeemGift.
+func WithGift(gift *Gift) PaymentDataOption {
+	return func(p *PaymentData) {
+		if gift == nil {
+			return
+		}
+		p.PurchaserID = p.UserID
+		p.UserID = gift.UserID
+		p.BeneficiaryEmail = gift.Email
+		p.GiftCode = gift.Code
+		if !gift.RedeemBy.IsZero() {
+			p.GiftRedeemBy = gift.RedeemBy.UTC().Format(time.RFC3339)
+		}
+		if gift.UserID == "" {
+			p.ExpiresAt = ""
+		}
+	}
+}
+
+// IsGift reports whether the payment was bought for another account.
+func (p *PaymentData) IsGift() bool {
+	return p.PurchaserID != ""
+}
+
+// RedeemGift assigns a paid gift to the signed-in user with the given email address.
+// The subscription runs for the plan's period starting at activeUntil when the user is
+// already subscribed, and now otherwise.
+func (p *PaymentData) RedeemGift(userID, email string, plan config.SubscriptionPlan, activeUntil, now time.Time) error {
+	if p.GiftCode == "" {
+		return ErrGiftNotFound
+	}
+	if p.UserID != "" || p.RedeemedAt != "" {
+		return ErrGiftAlreadyRedeemed
+	}
+	if p.GiftRedeemBy != "" {
+		redeemBy, err := time.Parse(time.RFC3339, p.GiftRedeemBy)
+		if err != nil {
+			return fmt.Errorf("invalid gift_redeem_by %q on order %s: %w", p.GiftRedeemBy, p.OrderID, err)
+		}
+		if !now.Before(redeemBy) {
+			return ErrGiftExpired
+		}
+	}
+	if p.PaymentStatus != StatusFinished {
+		return ErrGiftNotPaid
+	}
+	if p.BeneficiaryEmail != "" && !strings.EqualFold(p.BeneficiaryEmail, strings.TrimSpace(email)) {
+		return ErrGiftRecipientMismatch
+	}
+	if plan == nil || plan.GetID() != p.PlanID {
+		return fmt.Errorf("gift on order %s is for plan %q", p.OrderID, p.PlanID)
+	}
+	expiresAt, err := ExpirationDate(plan, activeUntil)
+	if err != nil {
+		return err
+	}
+	p.UserID = userID
+	p.ExpiresAt = expiresAt
+	p.RedeemedAt = now.UTC().Format(time.RFC3339)
+	return nil
+}
@@ -0,0 +1,81 @@
+package model
+
+import (
+	"errors"
+	"github.com/antidote-recognize0663/comics-galore-library/config"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestNewGift(t *testing.T) {
+	byID, err := NewGift("user-42", 0)
+	if err != nil || byID.UserID != "user-42" || byID.Code != "" {
+		t.Errorf("Expected a gift to user-42 without code, got %+v, %v", byID, err)
+	}
+	byEmail, err := NewGift(" Friend@Example.com ", time.Hour)
+	if err != nil {
+		t.Fatalf("NewGift for an email failed: %v", err)
+	}
+	if byEmail.Email != "friend@example.com" || byEmail.UserID != "" {
+		t.Errorf("Unexpected gift: %+v", byEmail)
+	}
+	if !regexp.MustCompile(`^GIFT(-[A-Z2-7]{4}){4}$`).MatchString(byEmail.Code) {
+		t.Errorf("Unexpected gift code %q", byEmail.Code)
+	}
+	if _, err := NewGift("not an@email", 0); !errors.Is(err, ErrInvalidBeneficiary) {
+		t.Errorf("Expected an invalid email to be rejected, got %v", err)
+	}
+	if _, err := NewGift("", 0); !errors.Is(err, ErrInvalidBeneficiary) {
+		t.Errorf("Expected an empty beneficiary to be rejected, got %v", err)
+	}
+}
+
+func TestRedeemGift(t *testing.T) {
+	plan := (*config.NewSubscriptionPlans())[0]
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	gift := &Gift{Email: "friend@example.com", Code: "GIFT-AAAA-BBBB-CCCC-DDDD", RedeemBy: now.Add(24 * time.Hour)}
+	newGift := func(status PaymentStatusEnum) *PaymentData {
+		data := &PaymentData{UserID: "buyer", PlanID: plan.GetID(), PaymentStatus: status, ExpiresAt: "2025-07-15T12:00:00Z"}
+		WithGift(gift)(data)
+		return data
+	}
+
+	pending := newGift(StatusFinished)
+	if pending.PurchaserID != "buyer" || pending.UserID != "" || pending.ExpiresAt != "" || !pending.IsGift() {
+		t.Fatalf("Expected an unassigned gift bought by buyer, got %+v", pending)
+	}
+
+	testCases := []struct {
+		name      string
+		data      *PaymentData
+		email     string
+		now       time.Time
+		expectErr error
+	}{
+		{name: "Redeemed", data: newGift(StatusFinished), email: "FRIEND@example.com", now: now},
+		{name: "Not Paid", data: newGift(StatusWaiting), email: "friend@example.com", now: now, expectErr: ErrGiftNotPaid},
+		{name: "Other Recipient", data: newGift(StatusFinished), email: "other@example.com", now: now, expectErr: ErrGiftRecipientMismatch},
+		{name: "Redeem Window Passed", data: newGift(StatusFinished), email: "friend@example.com", now: now.Add(48 * time.Hour), expectErr: ErrGiftExpired},
+		{name: "Not A Gift", data: &PaymentData{PlanID: plan.GetID(), PaymentStatus: StatusFinished}, email: "friend@example.com", now: now, expectErr: ErrGiftNotFound},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.data.RedeemGift("friend", tc.email, plan, time.Time{}, tc.now)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("Expected %v, got %v", tc.expectErr, err)
+			}
+			if err == nil && (tc.data.UserID != "friend" || tc.data.ExpiresAt == "" || tc.data.RedeemedAt == "") {
+				t.Errorf("Expected the gift to be assigned to friend, got %+v", tc.data)
+			}
+		})
+	}
+
+	redeemed := newGift(StatusFinished)
+	if err := redeemed.RedeemGift("friend", "friend@example.com", plan, time.Time{}, now); err != nil {
+		t.Fatalf("RedeemGift failed: %v", err)
+	}
+	if err := redeemed.RedeemGift("someone", "friend@example.com", plan, time.Time{}, now); !errors.Is(err, ErrGiftAlreadyRedeemed) {
+		t.Errorf("Expected a second redemption to be rejected, got %v", err)
+	}
+}
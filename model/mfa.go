@@ -0,0 +1,55 @@
+package model
+
+import "github.com/appwrite/sdk-for-go/models"
+
+// MfaFactor is a second factor a session can be verified with.
+type MfaFactor string
+
+const (
+	MfaFactorTotp         MfaFactor = "totp"
+	MfaFactorEmail        MfaFactor = "email"
+	MfaFactorPhone        MfaFactor = "phone"
+	MfaFactorRecoveryCode MfaFactor = "recoverycode"
+)
+
+// MfaAuthenticator is a TOTP authenticator being enrolled. URI is the otpauth:// URI
+// to render as a QR code for authenticator apps, Secret the key to enter manually.
+type MfaAuthenticator struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+func NewMfaAuthenticator(mfaType *models.MfaType) *MfaAuthenticator {
+	return &MfaAuthenticator{Secret: mfaType.Secret, URI: mfaType.Uri}
+}
+
+type MfaChallenge struct {
+	*models.MfaChallenge
+}
+
+type MfaRecoveryCodes struct {
+	*models.MfaRecoveryCodes
+}
+
+type MfaFactors struct {
+	*models.MfaFactors
+}
+
+// Enabled returns the factors the user can complete a challenge with.
+func (f *MfaFactors) Enabled() []MfaFactor {
+	var enabled []MfaFactor
+	for _, factor := range []struct {
+		factor  MfaFactor
+		enabled bool
+	}{
+		{MfaFactorTotp, f.Totp},
+		{MfaFactorEmail, f.Email},
+		{MfaFactorPhone, f.Phone},
+		{MfaFactorRecoveryCode, f.RecoveryCode},
+	} {
+		if factor.enabled {
+			enabled = append(enabled, factor.factor)
+		}
+	}
+	return enabled
+}
@@ -25,6 +25,27 @@ type Currency struct {
 	Currency  string  `json:"currency"`
 }
 
+// MinimumAmount is the smallest payment NowPayments accepts for a currency pair.
+type MinimumAmount struct {
+	CurrencyFrom   string  `json:"currency_from"`
+	CurrencyTo     string  `json:"currency_to"`
+	MinAmount      float64 `json:"min_amount"`
+	FiatEquivalent float64 `json:"fiat_equivalent,omitempty"`
+}
+
+// NowPaymentsError is the body NowPayments sends along with a non-2xx status.
+type NowPaymentsError struct {
+	Status     bool   `json:"status"`
+	StatusCode int    `json:"statusCode"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+// ErrorDetails returns the code and message of the error body.
+func (e *NowPaymentsError) ErrorDetails() (string, string) {
+	return e.Code, e.Message
+}
+
 type StatusResponse struct {
 	Message string `json:"message"`
 }
@@ -57,6 +78,68 @@ func NewPaymentRequest(priceAmount float64, priceCurrency string, payAmount floa
 	}
 }
 
+// NewOrderID returns a unique order ID prefixed with the subscription plan it was
+// created for, so the plan can be recovered from any IPN via PlanIDFromOrderID. The
+// unique suffix is hexadecimal, so plan IDs may contain the "-" separator themselves.
+func NewOrderID(planID string) string {
+	if planID == "" {
+		return id.Unique()
+	}
+	return fmt.Sprintf("%s-%s", planID, id.Unique())
+}
+
+// PlanIDFromOrderID returns the plan ID encoded by NewOrderID, or an empty string.
+// Payments store their plan_id, which is to be preferred wherever it is available.
+func PlanIDFromOrderID(orderID string) string {
+	separator := strings.LastIndex(orderID, "-")
+	if separator <= 0 {
+		return ""
+	}
+	return orderID[:separator]
+}
+
+type InvoiceRequest struct {
+	PriceAmount      float64 `json:"price_amount"`                  // (required) The fiat amount of the invoice
+	PriceCurrency    string  `json:"price_currency"`                // (required) The fiat currency in which price_amount is specified (e.g., usd, eur)
+	PayCurrency      *string `json:"pay_currency,omitempty"`        // (optional) Preselected cryptocurrency; the buyer chooses on the hosted page if empty
+	IPNCallbackURL   *string `json:"ipn_callback_url,omitempty"`    // (optional) URL to receive callbacks
+	OrderID          *string `json:"order_id,omitempty"`            // (optional) Inner store order id
+	OrderDescription *string `json:"order_description,omitempty"`   // (optional) Inner store order description
+	SuccessURL       *string `json:"success_url,omitempty"`         // (optional) URL the buyer is sent to after a successful payment
+	CancelURL        *string `json:"cancel_url,omitempty"`          // (optional) URL the buyer is sent to after cancelling the payment
+	IsFixedRate      *bool   `json:"is_fixed_rate,omitempty"`       // (optional) Boolean, true or false; required for fixed-rate exchanges
+	IsFeePaidByUser  *bool   `json:"is_fee_paid_by_user,omitempty"` // (optional) Boolean, true or false; applicable for fixed-rate exchanges with user-paid fees
+}
+
+func NewInvoiceRequest(priceAmount float64, priceCurrency string, orderID string, orderDescription string, ipnCallbackURL string) *InvoiceRequest {
+	return &InvoiceRequest{
+		OrderID:          &orderID,
+		PriceAmount:      priceAmount,
+		PriceCurrency:    priceCurrency,
+		IPNCallbackURL:   &ipnCallbackURL,
+		OrderDescription: &orderDescription,
+	}
+}
+
+type InvoiceResponse struct {
+	ID               string      `json:"id"`
+	TokenID          string      `json:"token_id"`
+	OrderID          string      `json:"order_id"`
+	OrderDescription string      `json:"order_description"`
+	PriceAmount      json.Number `json:"price_amount"`
+	PriceCurrency    string      `json:"price_currency"`
+	PayCurrency      string      `json:"pay_currency"`
+	IPNCallbackURL   string      `json:"ipn_callback_url"`
+	InvoiceURL       string      `json:"invoice_url"`
+	SuccessURL       string      `json:"success_url"`
+	CancelURL        string      `json:"cancel_url"`
+	CreatedAt        string      `json:"created_at"`
+	UpdatedAt        string      `json:"updated_at"`
+	// CouponCode and DiscountAmount are set by the service for a redeemed coupon.
+	CouponCode     string  `json:"-"`
+	DiscountAmount float64 `json:"-"`
+}
+
 type PaymentResponse struct {
 	PaymentID        string            `json:"payment_id"`
 	PaymentStatus    PaymentStatusEnum `json:"payment_status"`
@@ -68,6 +151,9 @@ type PaymentResponse struct {
 	OrderID          string            `json:"order_id"`
 	OrderDescription string            `json:"order_description"`
 	IPNCallbackURL   string            `json:"ipn_callback_url"`
+	// CouponCode and DiscountAmount are set by the service for a redeemed coupon.
+	CouponCode     string  `json:"-"`
+	DiscountAmount float64 `json:"-"`
 }
 
 type EstimatedPrice struct {
@@ -77,9 +163,11 @@ type EstimatedPrice struct {
 	EstimatedAmount string  `json:"estimated_amount"`
 }
 
+// NowPaymentsIPN is an IPN, or a payment status looked up from NowPayments. Raw is the
+// body the IPN was delivered with, as set by ValidatePayload.
 type NowPaymentsIPN struct {
 	PaymentID        int64             `json:"payment_id"`
-	InvoiceID        string            `json:"invoice_id,omitempty"`
+	InvoiceID        json.Number       `json:"invoice_id,omitempty"`
 	PaymentStatus    PaymentStatusEnum `json:"payment_status"`
 	PayAddress       string            `json:"pay_address"`
 	PayinExtraID     string            `json:"payin_extra_id,omitempty"`
@@ -100,6 +188,20 @@ type NowPaymentsIPN struct {
 	BurningPercent   string            `json:"burning_percent,omitempty"`
 	Type             string            `json:"type"`
 	PaymentExtraIDs  []int64           `json:"payment_extra_ids"`
+	Raw              json.RawMessage   `json:"-"`
+}
+
+// NowPaymentsPaymentList is a page of payments listed from the NowPayments API.
+type NowPaymentsPaymentList struct {
+	Data       []NowPaymentsIPN `json:"data"`
+	Limit      int              `json:"limit"`
+	Page       int              `json:"page"`
+	PagesCount int              `json:"pagesCount"`
+	Total      int              `json:"total"`
+}
+
+type NowPaymentsAuthResponse struct {
+	Token string `json:"token"`
 }
 
 func createCanonicalJSON(payload []byte) (string, error) {
@@ -132,24 +234,32 @@ func createCanonicalJSON(payload []byte) (string, error) {
 	return builder.String(), nil
 }
 
+// SignPayload computes the signature NowPayments sends in the x-nowpayments-sig header:
+// the hex-encoded HMAC-SHA512 of the payload re-serialized with its keys sorted.
+func SignPayload(payload []byte, ipnSecret string) (string, error) {
+	canonicalPayload, err := createCanonicalJSON(payload)
+	if err != nil {
+		return "", err
+	}
+	h := hmac.New(sha512.New, []byte(ipnSecret))
+	h.Write([]byte(canonicalPayload))
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 func ValidatePayload(receivedHMAC string, receivedPayload []byte, ipnSecret string) (bool, string, *NowPaymentsIPN) {
 	var nowPaymentsIpn NowPaymentsIPN
 	if err := json.Unmarshal(receivedPayload, &nowPaymentsIpn); err != nil {
 		return false, "Invalid JSON format", nil
 	}
+	nowPaymentsIpn.Raw = append(json.RawMessage(nil), receivedPayload...)
 
-	// 1. Create the canonical payload using the new helper function.
-	canonicalPayload, err := createCanonicalJSON(receivedPayload)
+	// 1. Compute the HMAC over the canonical payload.
+	computedHMAC, err := SignPayload(receivedPayload, ipnSecret)
 	if err != nil {
 		return false, err.Error(), nil
 	}
 
-	// 2. Compute the HMAC using the canonical payload.
-	h := hmac.New(sha512.New, []byte(ipnSecret))
-	h.Write([]byte(canonicalPayload))
-	computedHMAC := hex.EncodeToString(h.Sum(nil))
-
-	// 3. Securely compare the signatures.
+	// 2. Securely compare the signatures.
 	if hmac.Equal([]byte(computedHMAC), []byte(receivedHMAC)) {
 		return true, "", &nowPaymentsIpn
 	}
@@ -89,3 +89,25 @@ func TestValidatePayload(t *testing.T) {
 		})
 	}
 }
+
+func TestNewOrderID(t *testing.T) {
+	testCases := []struct {
+		name   string
+		planID string
+	}{
+		{name: "Plan", planID: "1"},
+		{name: "Plan With Separator", planID: "yearly-2025"},
+		{name: "No Plan", planID: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			orderID := NewOrderID(tc.planID)
+			if orderID == NewOrderID(tc.planID) {
+				t.Errorf("Expected unique order IDs, got %q twice", orderID)
+			}
+			if planID := PlanIDFromOrderID(orderID); planID != tc.planID {
+				t.Errorf("Expected plan %q from order %q, got %q", tc.planID, orderID, planID)
+			}
+		})
+	}
+}
@@ -1,7 +1,10 @@
 package model
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/config"
 	"github.com/appwrite/sdk-for-go/models"
 	"time"
 )
@@ -24,6 +27,8 @@ type PaymentStatus struct {
 
 type PaymentData struct {
 	UserID           string            `json:"user_id,omitempty"`
+	PlanID           string            `json:"plan_id,omitempty"`
+	Provider         string            `json:"provider,omitempty"`
 	OrderID          string            `json:"order_id,omitempty"`
 	QRCodeURL        string            `json:"qr_code_url,omitempty"`
 	PaymentID        string            `json:"payment_id"`
@@ -40,6 +45,123 @@ type PaymentData struct {
 	PayinHash        string            `json:"payin_hash,omitempty"`
 	PayoutHash       string            `json:"payout_hash,omitempty"`
 	ActuallyPaid     float64           `json:"actually_paid,omitempty"`
+	OutcomeAmount    float64           `json:"outcome_amount,omitempty"`
+	OutcomeCurrency  string            `json:"outcome_currency,omitempty"`
+	InvoiceID        string            `json:"invoice_id,omitempty"`
+	InvoiceURL       string            `json:"invoice_url,omitempty"`
+	StatusHistory    []string          `json:"status_history,omitempty"`
+	CouponCode       string            `json:"coupon_code,omitempty"`
+	DiscountAmount   float64           `json:"discount_amount,omitempty"`
+	RemindersSent    []string          `json:"reminders_sent,omitempty"`
+	PurchaserID      string            `json:"purchaser_id,omitempty"`
+	BeneficiaryEmail string            `json:"beneficiary_email,omitempty"`
+	GiftCode         string            `json:"gift_code,omitempty"`
+	GiftRedeemBy     string            `json:"gift_redeem_by,omitempty"`
+	RedeemedAt       string            `json:"redeemed_at,omitempty"`
+	UpgradeOf        []string          `json:"upgrade_of,omitempty"`
+	UpgradeCredit    float64           `json:"upgrade_credit,omitempty"`
+	SupersededBy     string            `json:"superseded_by,omitempty"`
+}
+
+type PaymentDataOption func(*PaymentData)
+
+// WithCouponRedemption records that the payment redeemed a coupon, and how much was
+// taken off the plan price.
+func WithCouponRedemption(code string, discountAmount float64) PaymentDataOption {
+	return func(p *PaymentData) {
+		p.CouponCode = code
+		p.DiscountAmount = discountAmount
+	}
+}
+
+// StatusTransition is one accepted change of a payment's status. Appwrite attributes
+// cannot hold nested objects, so transitions are stored JSON-encoded in the
+// status_history string array of the payment document.
+type StatusTransition struct {
+	From PaymentStatusEnum `json:"from"`
+	To   PaymentStatusEnum `json:"to"`
+	At   string            `json:"at"`
+	IPN  json.RawMessage   `json:"ipn,omitempty"`
+}
+
+// NewStatusTransition encodes a transition from one status to another, together with
+// the raw notification that caused it, as a status_history entry.
+func NewStatusTransition(from, to PaymentStatusEnum, raw []byte) (string, error) {
+	transition := StatusTransition{
+		From: from,
+		To:   to,
+		At:   time.Now().UTC().Format(time.RFC3339),
+	}
+	if len(raw) > 0 {
+		transition.IPN = raw
+	}
+	entry, err := json.Marshal(transition)
+	if err != nil {
+		return "", fmt.Errorf("could not encode status transition: %w", err)
+	}
+	return string(entry), nil
+}
+
+// Transitions decodes the status history of the payment, oldest first.
+func (p *PaymentData) Transitions() ([]StatusTransition, error) {
+	transitions := make([]StatusTransition, 0, len(p.StatusHistory))
+	for _, entry := range p.StatusHistory {
+		var transition StatusTransition
+		if err := json.Unmarshal([]byte(entry), &transition); err != nil {
+			return nil, fmt.Errorf("could not decode status transition: %w", err)
+		}
+		transitions = append(transitions, transition)
+	}
+	return transitions, nil
+}
+
+// HasApplied reports whether the raw notification already moved the payment to status.
+func (p *PaymentData) HasApplied(status PaymentStatusEnum, raw []byte) bool {
+	if p.PaymentStatus != status || len(raw) == 0 {
+		return false
+	}
+	// Encoded like NewStatusTransition stores it.
+	encoded, err := json.Marshal(json.RawMessage(raw))
+	if err != nil {
+		return false
+	}
+	transitions, err := p.Transitions()
+	if err != nil {
+		return false
+	}
+	for _, transition := range transitions {
+		if transition.To == status && bytes.Equal(transition.IPN, encoded) {
+			return true
+		}
+	}
+	return false
+}
+
+// ProviderName returns the provider that processes the payment. Payments stored before
+// providers were recorded were all made with NowPayments.
+func (p *PaymentData) ProviderName() string {
+	if p.Provider == "" {
+		return ProviderNowPayments
+	}
+	return p.Provider
+}
+
+// WasFinished reports whether the payment has been finished at some point, that is
+// whether it granted access, even if it was refunded or charged back since.
+func (p *PaymentData) WasFinished() bool {
+	if p.PaymentStatus == StatusFinished {
+		return true
+	}
+	transitions, err := p.Transitions()
+	if err != nil {
+		return false
+	}
+	for _, transition := range transitions {
+		if transition.To == StatusFinished {
+			return true
+		}
+	}
+	return false
 }
 
 type Payment struct {
@@ -52,6 +174,19 @@ type PaymentList struct {
 	Payments []Payment `json:"documents"`
 }
 
+// NewPayments decodes the payments of a document list. The documents of a list carry
+// no raw data of their own, so they cannot be decoded one by one with NewPayment.
+func NewPayments(documents *models.DocumentList) ([]Payment, error) {
+	if len(documents.Documents) == 0 {
+		return []Payment{}, nil
+	}
+	var list PaymentList
+	if err := documents.Decode(&list); err != nil {
+		return nil, err
+	}
+	return list.Payments, nil
+}
+
 func NewPayment(document *models.Document) (*Payment, error) {
 	var paymentData PaymentData
 	if err := document.Decode(&paymentData); err != nil {
@@ -63,22 +198,20 @@ func NewPayment(document *models.Document) (*Payment, error) {
 	}, nil
 }
 
-func NewPaymentData(userId string, payment *PaymentResponse) (*PaymentData, error) {
-	durationMap := map[float64]Duration{
-		10:  {0, 1, 0}, // 1 month
-		20:  {0, 2, 0}, // 2 months
-		30:  {0, 3, 0}, // 3 months
-		60:  {0, 6, 0}, // 6 months
-		120: {1, 0, 0}, // 1 year
-	}
-	expiresAt, err := getExpirationDate(time.Now(), payment.PriceAmount, durationMap)
+// NewPaymentData builds the payment entry for a direct payment of the given plan. The
+// subscription runs for the plan's period starting at activeUntil when the user still
+// has an active subscription (a renewal), and at the current time otherwise. With
+// WithGift the plan is bought for another account.
+func NewPaymentData(userId string, plan config.SubscriptionPlan, payment *PaymentResponse, activeUntil time.Time, opts ...PaymentDataOption) (*PaymentData, error) {
+	expiresAt, err := ExpirationDate(plan, activeUntil)
 	if err != nil {
 		return nil, err
 	}
-	//expiresAt := expiresAt.Format(time.RFC3339)
 	qrCodeURL := buildQRCodeURL(payment.PayCurrency, payment.PayAddress, payment.PriceAmount, payment.PaymentID)
-	return &PaymentData{
+	data := &PaymentData{
 		UserID:           userId,
+		PlanID:           plan.GetID(),
+		Provider:         ProviderNowPayments,
 		OrderID:          payment.OrderID,
 		PaymentID:        payment.PaymentID,
 		PayAmount:        payment.PayAmount,
@@ -90,22 +223,65 @@ func NewPaymentData(userId string, payment *PaymentResponse) (*PaymentData, erro
 		OrderDescription: payment.OrderDescription,
 		QRCodeURL:        qrCodeURL,
 		ExpiresAt:        expiresAt,
-	}, nil
+		CouponCode:       payment.CouponCode,
+		DiscountAmount:   payment.DiscountAmount,
+	}
+	for _, opt := range opts {
+		opt(data)
+	}
+	return data, nil
 }
 
-type Duration struct {
-	Years  int
-	Months int
-	Days   int
+// NewInvoicePaymentData builds the payment entry for a hosted-checkout invoice. The
+// NowPayments payment ID and pay address are only known once the buyer picks a coin on
+// the invoice page, so they are filled in by the IPNs that follow. The expiry is
+// computed the same way as in NewPaymentData.
+func NewInvoicePaymentData(userId string, plan config.SubscriptionPlan, invoice *InvoiceResponse, activeUntil time.Time, opts ...PaymentDataOption) (*PaymentData, error) {
+	priceAmount, err := invoice.PriceAmount.Float64()
+	if err != nil {
+		return nil, fmt.Errorf("invalid invoice price amount %q: %w", invoice.PriceAmount, err)
+	}
+	expiresAt, err := ExpirationDate(plan, activeUntil)
+	if err != nil {
+		return nil, err
+	}
+	data := &PaymentData{
+		UserID:           userId,
+		PlanID:           plan.GetID(),
+		Provider:         ProviderNowPayments,
+		OrderID:          invoice.OrderID,
+		InvoiceID:        invoice.ID,
+		InvoiceURL:       invoice.InvoiceURL,
+		PayCurrency:      invoice.PayCurrency,
+		PriceAmount:      priceAmount,
+		PaymentStatus:    StatusWaiting,
+		PriceCurrency:    invoice.PriceCurrency,
+		OrderDescription: invoice.OrderDescription,
+		ExpiresAt:        expiresAt,
+		CouponCode:       invoice.CouponCode,
+		DiscountAmount:   invoice.DiscountAmount,
+	}
+	for _, opt := range opts {
+		opt(data)
+	}
+	return data, nil
 }
 
-func getExpirationDate(startTime time.Time, amount float64, durationMap map[float64]Duration) (string, error) {
-	duration, exists := durationMap[amount]
-	if !exists {
-		return "", fmt.Errorf("invalid amount: no duration configured for %.2f", amount)
+// ExpirationDate returns the end of the plan's period, starting at activeUntil when
+// that is still ahead and at the current time otherwise, formatted as stored in
+// expires_at.
+func ExpirationDate(plan config.SubscriptionPlan, activeUntil time.Time) (string, error) {
+	if plan == nil {
+		return "", fmt.Errorf("a subscription plan is required")
+	}
+	if plan.GetPeriod().IsZero() {
+		return "", fmt.Errorf("subscription plan %q has no period configured", plan.GetID())
+	}
+	startTime := time.Now().UTC()
+	if activeUntil.After(startTime) {
+		startTime = activeUntil.UTC()
 	}
-	expirationDate := startTime.AddDate(duration.Years, duration.Months, duration.Days)
-	return expirationDate.Format(time.RFC3339), nil
+	return plan.GetPeriod().AddTo(startTime).Format(time.RFC3339), nil
 }
 
 func buildQRCodeURL(currency, address string, amount float64, id string) string {
@@ -0,0 +1,104 @@
+package model
+
+import (
+	"github.com/antidote-recognize0663/comics-galore-library/config"
+	"testing"
+	"time"
+)
+
+func TestNewPaymentDataExpiry(t *testing.T) {
+	semiAnnual := config.NewSubscriptionPlan("3", "Semi-annual Plan", 60, config.Period{Months: 6}, 0.1)
+	activeUntil := time.Now().UTC().AddDate(0, 0, 10).Truncate(time.Second)
+
+	testCases := []struct {
+		name        string
+		plan        config.SubscriptionPlan
+		activeUntil time.Time
+		expectStart time.Time
+		expectErr   bool
+	}{
+		{
+			name:        "Discounted Plan Without Active Subscription",
+			plan:        semiAnnual,
+			expectStart: time.Now().UTC(),
+		},
+		{
+			name:        "Renewal Extends Active Subscription",
+			plan:        semiAnnual,
+			activeUntil: activeUntil,
+			expectStart: activeUntil,
+		},
+		{
+			name:        "Expired Subscription Starts Now",
+			plan:        semiAnnual,
+			activeUntil: time.Now().UTC().AddDate(0, -1, 0),
+			expectStart: time.Now().UTC(),
+		},
+		{
+			name:      "Plan Without Period",
+			plan:      config.NewSubscriptionPlan("9", "Broken Plan", 5, config.Period{}, 0),
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			payment := &PaymentResponse{PaymentID: "1", PriceAmount: tc.plan.GetPrice(), PayCurrency: "btc"}
+			data, err := NewPaymentData("user-1", tc.plan, payment, tc.activeUntil)
+			if tc.expectErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if data.PlanID != tc.plan.GetID() {
+				t.Errorf("Expected plan ID %q, got %q", tc.plan.GetID(), data.PlanID)
+			}
+			expiresAt, err := time.Parse(time.RFC3339, data.ExpiresAt)
+			if err != nil {
+				t.Fatalf("Invalid expires_at %q: %v", data.ExpiresAt, err)
+			}
+			expected := tc.plan.GetPeriod().AddTo(tc.expectStart)
+			if diff := expiresAt.Sub(expected); diff < -2*time.Second || diff > 2*time.Second {
+				t.Errorf("Expected expires_at around %s, got %s", expected, expiresAt)
+			}
+		})
+	}
+}
+
+func TestWasFinished(t *testing.T) {
+	history := func(statuses ...PaymentStatusEnum) []string {
+		var entries []string
+		from := PaymentStatusEnum("")
+		for _, status := range statuses {
+			entry, err := NewStatusTransition(from, status, nil)
+			if err != nil {
+				t.Fatalf("NewStatusTransition failed: %v", err)
+			}
+			entries = append(entries, entry)
+			from = status
+		}
+		return entries
+	}
+
+	testCases := []struct {
+		name     string
+		data     PaymentData
+		expected bool
+	}{
+		{name: "Finished", data: PaymentData{PaymentStatus: StatusFinished}, expected: true},
+		{name: "Refunded After Finishing", data: PaymentData{PaymentStatus: StatusRefunded, StatusHistory: history(StatusWaiting, StatusFinished, StatusRefunded)}, expected: true},
+		{name: "Refunded Partial Payment", data: PaymentData{PaymentStatus: StatusRefunded, StatusHistory: history(StatusWaiting, StatusPartiallyPaid, StatusRefunded)}},
+		{name: "Failed Without History", data: PaymentData{PaymentStatus: StatusFailed}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.data.WasFinished(); got != tc.expected {
+				t.Errorf("WasFinished = %v; want %v", got, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/config"
+	"github.com/appwrite/sdk-for-go/models"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// PrefsVersion is the prefs schema version; prefs without a version are version 1.
+const PrefsVersion = 2
+
+var (
+	ErrInvalidPrefs = errors.New("invalid preferences")
+
+	localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+	coinPattern   = regexp.MustCompile(`^[a-z0-9]{2,20}$`)
+)
+
+type ReadingDirection string
+
+const (
+	ReadingLTR ReadingDirection = "ltr"
+	// ReadingRTL is the right-to-left page order of manga.
+	ReadingRTL ReadingDirection = "rtl"
+)
+
+// EmailOptIns are the non-account emails the user agreed to receive.
+type EmailOptIns struct {
+	Newsletter            bool `json:"newsletter"`
+	NewReleases           bool `json:"new_releases"`
+	SubscriptionReminders bool `json:"subscription_reminders"`
+}
+
+// Prefs are the user's Appwrite prefs; HiddenCategories is keyed by config.Category.
+type Prefs struct {
+	Version          int              `json:"version"`
+	AvatarID         string           `json:"avatar_id"`
+	Twitter          string           `json:"twitter"`
+	Facebook         string           `json:"facebook"`
+	Tumblr           string           `json:"tumblr"`
+	HiddenCategories map[string]bool  `json:"hidden_categories,omitempty"`
+	BlurNSFW         bool             `json:"blur_nsfw"`
+	ReadingDirection ReadingDirection `json:"reading_direction"`
+	PaymentCoin      string           `json:"payment_coin,omitempty"`
+	EmailOptIns      EmailOptIns      `json:"email_opt_ins"`
+	Locale           string           `json:"locale"`
+}
+
+// prefsMigrations upgrade a prefs document from the version they are keyed by.
+var prefsMigrations = map[int]func(raw map[string]interface{}){
+	1: func(raw map[string]interface{}) {
+		setDefault(raw, "blur_nsfw", true)
+		setDefault(raw, "reading_direction", string(ReadingLTR))
+		setDefault(raw, "email_opt_ins", map[string]interface{}{"subscription_reminders": true})
+		setDefault(raw, "locale", "en")
+	},
+}
+
+func setDefault(raw map[string]interface{}, key string, value interface{}) {
+	if _, ok := raw[key]; !ok {
+		raw[key] = value
+	}
+}
+
+// UpgradePrefs upgrades a raw prefs document in place and reports whether it changed.
+func UpgradePrefs(raw map[string]interface{}) bool {
+	version := 1
+	if v, ok := raw["version"].(float64); ok && v >= 1 {
+		version = int(v)
+	}
+	upgraded := false
+	for ; version < PrefsVersion; version++ {
+		prefsMigrations[version](raw)
+		upgraded = true
+	}
+	raw["version"] = version
+	return upgraded
+}
+
+func (p *Prefs) UnmarshalJSON(data []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw == nil {
+		raw = map[string]interface{}{}
+	}
+	UpgradePrefs(raw)
+	upgraded, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	type prefs Prefs
+	var decoded prefs
+	if err := json.Unmarshal(upgraded, &decoded); err != nil {
+		return err
+	}
+	*p = Prefs(decoded)
+	return nil
+}
+
+// NewPrefs decodes the prefs returned by Appwrite, falling back to the defaults.
+func NewPrefs(preferences *models.Preferences) *Prefs {
+	var prefs Prefs
+	if err := preferences.Decode(&prefs); err != nil {
+		log.Println(err)
+		return DefaultPrefs()
+	}
+	return &prefs
+}
+
+// DefaultPrefs returns the preferences of a user who never changed any.
+func DefaultPrefs() *Prefs {
+	var prefs Prefs
+	_ = prefs.UnmarshalJSON([]byte("{}"))
+	return &prefs
+}
+
+func (p *Prefs) IsCategoryHidden(category config.Category) bool {
+	return p.HiddenCategories[category.GetValue()]
+}
+
+func (p *Prefs) Validate() error {
+	if p.ReadingDirection != ReadingLTR && p.ReadingDirection != ReadingRTL {
+		return fmt.Errorf("%w: unknown reading direction %q", ErrInvalidPrefs, p.ReadingDirection)
+	}
+	if !localePattern.MatchString(p.Locale) {
+		return fmt.Errorf("%w: invalid locale %q", ErrInvalidPrefs, p.Locale)
+	}
+	if p.PaymentCoin != "" && !coinPattern.MatchString(p.PaymentCoin) {
+		return fmt.Errorf("%w: invalid payment coin %q", ErrInvalidPrefs, p.PaymentCoin)
+	}
+	for value := range p.HiddenCategories {
+		if value == "" {
+			return fmt.Errorf("%w: empty category", ErrInvalidPrefs)
+		}
+	}
+	return nil
+}
+
+// PrefsPatch is a partial update of the prefs; nil fields are left unchanged.
+type PrefsPatch struct {
+	AvatarID         *string
+	Twitter          *string
+	Facebook         *string
+	Tumblr           *string
+	HiddenCategories map[string]bool
+	BlurNSFW         *bool
+	ReadingDirection *ReadingDirection
+	PaymentCoin      *string
+	EmailOptIns      *EmailOptInsPatch
+	Locale           *string
+}
+
+type EmailOptInsPatch struct {
+	Newsletter            *bool
+	NewReleases           *bool
+	SubscriptionReminders *bool
+}
+
+// HideCategory hides or shows the category again.
+func (p *PrefsPatch) HideCategory(category config.Category, hidden bool) *PrefsPatch {
+	if p.HiddenCategories == nil {
+		p.HiddenCategories = map[string]bool{}
+	}
+	p.HiddenCategories[category.GetValue()] = hidden
+	return p
+}
+
+// ApplyTo changes the prefs as described by the patch.
+func (p *PrefsPatch) ApplyTo(prefs *Prefs) {
+	setIfNotNil(&prefs.AvatarID, p.AvatarID)
+	setIfNotNil(&prefs.Twitter, p.Twitter)
+	setIfNotNil(&prefs.Facebook, p.Facebook)
+	setIfNotNil(&prefs.Tumblr, p.Tumblr)
+	setIfNotNil(&prefs.BlurNSFW, p.BlurNSFW)
+	setIfNotNil(&prefs.ReadingDirection, p.ReadingDirection)
+	setIfNotNil(&prefs.Locale, p.Locale)
+	if p.PaymentCoin != nil {
+		prefs.PaymentCoin = strings.ToLower(strings.TrimSpace(*p.PaymentCoin))
+	}
+	if p.EmailOptIns != nil {
+		setIfNotNil(&prefs.EmailOptIns.Newsletter, p.EmailOptIns.Newsletter)
+		setIfNotNil(&prefs.EmailOptIns.NewReleases, p.EmailOptIns.NewReleases)
+		setIfNotNil(&prefs.EmailOptIns.SubscriptionReminders, p.EmailOptIns.SubscriptionReminders)
+	}
+	for value, hidden := range p.HiddenCategories {
+		if !hidden {
+			delete(prefs.HiddenCategories, value)
+			continue
+		}
+		if prefs.HiddenCategories == nil {
+			prefs.HiddenCategories = map[string]bool{}
+		}
+		prefs.HiddenCategories[value] = true
+	}
+}
+
+// Merge applies the patch to a raw prefs document, keeping unknown keys.
+func (p *PrefsPatch) Merge(raw map[string]interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode prefs: %w", err)
+	}
+	var prefs Prefs
+	if err := json.Unmarshal(data, &prefs); err != nil {
+		return nil, fmt.Errorf("could not decode prefs: %w", err)
+	}
+	p.ApplyTo(&prefs)
+	if err := prefs.Validate(); err != nil {
+		return nil, err
+	}
+	data, err = json.Marshal(prefs)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode prefs: %w", err)
+	}
+	var updated map[string]interface{}
+	if err := json.Unmarshal(data, &updated); err != nil {
+		return nil, fmt.Errorf("could not decode prefs: %w", err)
+	}
+	merged := make(map[string]interface{}, len(raw)+len(updated))
+	for key, value := range raw {
+		merged[key] = value
+	}
+	// hidden_categories is omitted once the last category is shown again.
+	delete(merged, "hidden_categories")
+	for key, value := range updated {
+		merged[key] = value
+	}
+	return merged, nil
+}
+
+func setIfNotNil[T any](field *T, value *T) {
+	if value != nil {
+		*field = *value
+	}
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestPrefsUpgrade(t *testing.T) {
+	var prefs Prefs
+	if err := json.Unmarshal([]byte(`{"avatar_id":"avatar-1","twitter":"@reader"}`), &prefs); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if prefs.Version != PrefsVersion || prefs.AvatarID != "avatar-1" || prefs.Twitter != "@reader" || !prefs.BlurNSFW ||
+		prefs.ReadingDirection != ReadingLTR || prefs.Locale != "en" || !prefs.EmailOptIns.SubscriptionReminders || prefs.EmailOptIns.Newsletter {
+		t.Errorf("Unexpected upgraded prefs: %+v", prefs)
+	}
+
+	current := map[string]interface{}{"version": float64(2), "blur_nsfw": false, "reading_direction": "rtl", "locale": "ja"}
+	if UpgradePrefs(current) || current["blur_nsfw"] != false {
+		t.Errorf("Expected current prefs to be left alone, got %v", current)
+	}
+	if err := DefaultPrefs().Validate(); err != nil {
+		t.Errorf("Expected the default prefs to be valid, got %v", err)
+	}
+}
+
+func TestPrefsPatchMerge(t *testing.T) {
+	rtl := ReadingRTL
+	coin := " XMR "
+	locale := "pt-BR"
+	hide := false
+	newsletter := true
+	invalid := ReadingDirection("ttb")
+
+	testCases := []struct {
+		name      string
+		raw       map[string]interface{}
+		patch     *PrefsPatch
+		check     func(t *testing.T, merged map[string]interface{})
+		expectErr error
+	}{
+		{
+			name:  "Legacy Prefs",
+			raw:   map[string]interface{}{"avatar_id": "avatar-1", "theme": "dark"},
+			patch: &PrefsPatch{ReadingDirection: &rtl, PaymentCoin: &coin, EmailOptIns: &EmailOptInsPatch{Newsletter: &newsletter}},
+			check: func(t *testing.T, merged map[string]interface{}) {
+				optIns := merged["email_opt_ins"].(map[string]interface{})
+				if merged["avatar_id"] != "avatar-1" || merged["theme"] != "dark" || merged["reading_direction"] != "rtl" ||
+					merged["payment_coin"] != "xmr" || merged["version"] != float64(PrefsVersion) ||
+					optIns["newsletter"] != true || optIns["subscription_reminders"] != true {
+					t.Errorf("Unexpected merged prefs: %v", merged)
+				}
+			},
+		},
+		{
+			name:  "Show Hidden Category",
+			raw:   map[string]interface{}{"version": float64(2), "reading_direction": "ltr", "locale": "en", "hidden_categories": map[string]interface{}{"manga": true, "western": true}},
+			patch: &PrefsPatch{HiddenCategories: map[string]bool{"manga": hide, "hentai": true}, Locale: &locale},
+			check: func(t *testing.T, merged map[string]interface{}) {
+				hidden := merged["hidden_categories"].(map[string]interface{})
+				if len(hidden) != 2 || hidden["western"] != true || hidden["hentai"] != true || merged["locale"] != "pt-BR" {
+					t.Errorf("Unexpected merged prefs: %v", merged)
+				}
+			},
+		},
+		{
+			name:  "Last Hidden Category Shown",
+			raw:   map[string]interface{}{"version": float64(2), "reading_direction": "ltr", "locale": "en", "hidden_categories": map[string]interface{}{"manga": true}},
+			patch: &PrefsPatch{HiddenCategories: map[string]bool{"manga": false}},
+			check: func(t *testing.T, merged map[string]interface{}) {
+				if _, ok := merged["hidden_categories"]; ok {
+					t.Errorf("Expected no hidden categories, got %v", merged)
+				}
+			},
+		},
+		{name: "Invalid Reading Direction", raw: map[string]interface{}{}, patch: &PrefsPatch{ReadingDirection: &invalid}, expectErr: ErrInvalidPrefs},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			merged, err := tc.patch.Merge(tc.raw)
+			if tc.expectErr != nil {
+				if !errors.Is(err, tc.expectErr) {
+					t.Errorf("Expected %v, got %v", tc.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Merge failed: %v", err)
+			}
+			tc.check(t, merged)
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/config"
+	"strconv"
+	"time"
+)
+
+// Names of the payment providers, as stored in the provider attribute of a payment.
+const (
+	ProviderNowPayments = "nowpayments"
+	ProviderBTCPay      = "btcpay"
+)
+
+// CheckoutRequest asks a payment provider to collect a payment for a subscription plan.
+type CheckoutRequest struct {
+	OrderID         string
+	PlanID          string
+	Description     string
+	PriceAmount     float64
+	PriceCurrency   string
+	PayCurrency     string
+	NotificationURL string // where the provider posts status updates, when it supports one per payment
+	RedirectURL     string // where the buyer is sent once the payment is complete
+}
+
+// NewCheckoutRequest prepares a checkout for the plan at the given final price.
+func NewCheckoutRequest(plan config.SubscriptionPlan, priceAmount float64, priceCurrency string) *CheckoutRequest {
+	return &CheckoutRequest{
+		OrderID:       NewOrderID(plan.GetID()),
+		PlanID:        plan.GetID(),
+		Description:   plan.GetName(),
+		PriceAmount:   priceAmount,
+		PriceCurrency: priceCurrency,
+	}
+}
+
+// Checkout is a payment created by a provider.
+type Checkout struct {
+	Provider      string
+	OrderID       string
+	PaymentID     string
+	InvoiceID     string
+	Status        PaymentStatusEnum
+	Description   string
+	PriceAmount   float64
+	PriceCurrency string
+	PayAmount     float64
+	PayCurrency   string
+	PayAddress    string
+	CheckoutURL   string
+}
+
+// PaymentUpdate is the state of a payment as reported by a provider.
+type PaymentUpdate struct {
+	Provider         string
+	OrderID          string
+	PaymentID        string
+	InvoiceID        string
+	Status           PaymentStatusEnum
+	OrderDescription string
+	PriceAmount      float64
+	PriceCurrency    string
+	PayAmount        float64
+	PayCurrency      string
+	PayAddress       string
+	ActuallyPaid     float64
+	OutcomeAmount    float64
+	OutcomeCurrency  string
+	PayinHash        string
+	PayoutHash       string
+	Raw              json.RawMessage
+}
+
+// ToPaymentUpdate converts a NowPayments IPN or payment status.
+func (ipn *NowPaymentsIPN) ToPaymentUpdate() *PaymentUpdate {
+	update := &PaymentUpdate{
+		Provider:         ProviderNowPayments,
+		OrderID:          ipn.OrderID,
+		InvoiceID:        ipn.InvoiceID.String(),
+		Status:           ipn.PaymentStatus,
+		OrderDescription: ipn.OrderDescription,
+		PriceAmount:      ipn.PriceAmount,
+		PriceCurrency:    ipn.PriceCurrency,
+		PayAmount:        ipn.PayAmount,
+		PayCurrency:      ipn.PayCurrency,
+		PayAddress:       ipn.PayAddress,
+		ActuallyPaid:     ipn.ActuallyPaid,
+		OutcomeAmount:    ipn.OutcomeAmount,
+		OutcomeCurrency:  ipn.OutcomeCurrency,
+		PayinHash:        ipn.PayinHash,
+		PayoutHash:       ipn.PayoutHash,
+	}
+	if ipn.PaymentID != 0 {
+		update.PaymentID = strconv.FormatInt(ipn.PaymentID, 10)
+	}
+	if len(ipn.Raw) > 0 {
+		update.Raw = ipn.Raw
+	} else if raw, err := json.Marshal(ipn); err == nil {
+		update.Raw = raw
+	}
+	return update
+}
+
+// NewCheckoutPaymentData builds the payment entry for a checkout of any provider.
+func NewCheckoutPaymentData(userId string, plan config.SubscriptionPlan, checkout *Checkout, activeUntil time.Time, opts ...PaymentDataOption) (*PaymentData, error) {
+	if checkout == nil {
+		return nil, fmt.Errorf("a checkout is required")
+	}
+	expiresAt, err := ExpirationDate(plan, activeUntil)
+	if err != nil {
+		return nil, err
+	}
+	status := checkout.Status
+	if status == "" {
+		status = StatusWaiting
+	}
+	data := &PaymentData{
+		UserID:           userId,
+		PlanID:           plan.GetID(),
+		Provider:         checkout.Provider,
+		OrderID:          checkout.OrderID,
+		PaymentID:        checkout.PaymentID,
+		InvoiceID:        checkout.InvoiceID,
+		InvoiceURL:       checkout.CheckoutURL,
+		PayAmount:        checkout.PayAmount,
+		PayAddress:       checkout.PayAddress,
+		PayCurrency:      checkout.PayCurrency,
+		PriceAmount:      checkout.PriceAmount,
+		PriceCurrency:    checkout.PriceCurrency,
+		PaymentStatus:    status,
+		OrderDescription: checkout.Description,
+		ExpiresAt:        expiresAt,
+	}
+	if checkout.PayAddress != "" {
+		data.QRCodeURL = buildQRCodeURL(checkout.PayCurrency, checkout.PayAddress, checkout.PriceAmount, checkout.PaymentID)
+	}
+	for _, opt := range opts {
+		opt(data)
+	}
+	return data, nil
+}
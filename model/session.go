@@ -1,9 +1,70 @@
 package model
 
-import "github.com/appwrite/sdk-for-go/models"
+import (
+	"github.com/appwrite/sdk-for-go/models"
+	"strings"
+)
 
 type SessionData struct{}
 
 type Session struct {
 	*models.Session
 }
+
+// DeviceSession describes where a session was created, for listing a user's signed-in
+// devices. Unlike Session, it never carries the session secret or OAuth2 tokens.
+type DeviceSession struct {
+	ID          string `json:"id"`
+	Current     bool   `json:"current"`
+	Provider    string `json:"provider"`
+	Client      string `json:"client"`
+	OS          string `json:"os"`
+	Device      string `json:"device"`
+	IP          string `json:"ip"`
+	CountryCode string `json:"country_code"`
+	Country     string `json:"country"`
+	CreatedAt   string `json:"created_at"`
+	ExpiresAt   string `json:"expires_at"`
+}
+
+func NewDeviceSession(session *models.Session) *DeviceSession {
+	return &DeviceSession{
+		ID:          session.Id,
+		Current:     session.Current,
+		Provider:    session.Provider,
+		Client:      joinNonEmpty(session.ClientName, session.ClientVersion),
+		OS:          joinNonEmpty(session.OsName, session.OsVersion),
+		Device:      joinNonEmpty(session.DeviceBrand, session.DeviceModel),
+		IP:          session.Ip,
+		CountryCode: session.CountryCode,
+		Country:     session.CountryName,
+		CreatedAt:   session.CreatedAt,
+		ExpiresAt:   session.Expire,
+	}
+}
+
+type SessionList struct {
+	Total    int             `json:"total"`
+	Sessions []DeviceSession `json:"sessions"`
+}
+
+func NewSessionList(sessions *models.SessionList) *SessionList {
+	list := &SessionList{
+		Total:    sessions.Total,
+		Sessions: make([]DeviceSession, 0, len(sessions.Sessions)),
+	}
+	for i := range sessions.Sessions {
+		list.Sessions = append(list.Sessions, *NewDeviceSession(&sessions.Sessions[i]))
+	}
+	return list
+}
+
+func joinNonEmpty(parts ...string) string {
+	nonEmpty := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return strings.Join(nonEmpty, " ")
+}
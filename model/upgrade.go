@@ -0,0 +1,182 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/config"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// UpgradeQuoteValidity is how long the amount of an upgrade quote is honoured.
+const UpgradeQuoteValidity = 15 * time.Minute
+
+var (
+	ErrNoActiveSubscription = errors.New("no active subscription to upgrade")
+	ErrUpgradeSamePlan      = errors.New("already subscribed to this plan")
+	ErrUpgradeNotAllowed    = errors.New("the remaining subscription is worth more than the target plan")
+	ErrUpgradeQuoteExpired  = errors.New("upgrade quote has expired")
+	ErrUpgradeQuoteChanged  = errors.New("upgrade quote no longer matches the subscription")
+	ErrUpgradePending       = errors.New("another upgrade of the subscription is pending")
+)
+
+// UpgradeQuote is the price of switching a user's active subscription to another plan.
+// The unused part of every active payment is credited against the discounted price of
+// the target plan, and the target plan's period starts when the upgrade is paid,
+// replacing the current expiry.
+type UpgradeQuote struct {
+	UserID           string    `json:"user_id"`
+	FromPlanID       string    `json:"from_plan_id"`
+	ToPlanID         string    `json:"to_plan_id"`
+	Currency         string    `json:"currency"`
+	Credit           float64   `json:"credit"`
+	TargetPrice      float64   `json:"target_price"`
+	AmountDue        float64   `json:"amount_due"`
+	CurrentExpiresAt string    `json:"current_expires_at"`
+	NewExpiresAt     string    `json:"new_expires_at"`
+	ReplacesOrderIDs []string  `json:"replaces_order_ids"`
+	ValidUntil       time.Time `json:"valid_until"`
+}
+
+// NewUpgradeQuote prices the upgrade of the user's active payments to the target plan
+// in the given fiat currency. plans must contain the plans of the active payments.
+func NewUpgradeQuote(userID string, active []*PaymentData, plans []config.SubscriptionPlan, target config.SubscriptionPlan, currency string, now time.Time) (*UpgradeQuote, error) {
+	if len(active) == 0 {
+		return nil, ErrNoActiveSubscription
+	}
+	if target == nil || target.GetPeriod().IsZero() {
+		return nil, fmt.Errorf("a subscription plan with a period is required")
+	}
+	currency = strings.ToLower(currency)
+	if currency == "" {
+		currency = config.BaseCurrency
+	}
+	targetPrice, ok := target.GetPriceIn(currency)
+	if !ok {
+		return nil, fmt.Errorf("plan %q has no price in %s", target.GetID(), currency)
+	}
+	quote := &UpgradeQuote{
+		UserID:      userID,
+		ToPlanID:    target.GetID(),
+		Currency:    currency,
+		TargetPrice: targetPrice,
+		ValidUntil:  now.Add(UpgradeQuoteValidity),
+	}
+	var latest time.Time
+	for _, payment := range active {
+		plan, ok := findPlan(plans, payment.PlanID)
+		if !ok {
+			return nil, fmt.Errorf("order %s is for unknown plan %q", payment.OrderID, payment.PlanID)
+		}
+		value, expiresAt, err := RemainingValue(payment, plan, currency, now)
+		if err != nil {
+			return nil, err
+		}
+		quote.Credit += value
+		quote.ReplacesOrderIDs = append(quote.ReplacesOrderIDs, payment.OrderID)
+		if expiresAt.After(latest) {
+			latest = expiresAt
+			quote.FromPlanID = payment.PlanID
+			quote.CurrentExpiresAt = payment.ExpiresAt
+		}
+	}
+	if quote.FromPlanID == target.GetID() {
+		return nil, ErrUpgradeSamePlan
+	}
+	quote.Credit = math.Round(quote.Credit*100) / 100
+	quote.AmountDue = math.Round((targetPrice-quote.Credit)*100) / 100
+	if quote.AmountDue <= 0 {
+		return nil, ErrUpgradeNotAllowed
+	}
+	quote.NewExpiresAt = target.GetPeriod().AddTo(now.UTC()).Format(time.RFC3339)
+	return quote, nil
+}
+
+// RemainingValue returns the value of the unused part of a payment's subscription in
+// the given currency, together with its expiry. The payment's own price is used when it
+// was paid in that currency, and the plan's price otherwise. A payment that starts in
+// the future, such as a renewal, is worth its full price.
+func RemainingValue(payment *PaymentData, plan config.SubscriptionPlan, currency string, now time.Time) (float64, time.Time, error) {
+	expiresAt, err := time.Parse(time.RFC3339, payment.ExpiresAt)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("invalid expires_at %q on order %s: %w", payment.ExpiresAt, payment.OrderID, err)
+	}
+	period := plan.GetPeriod()
+	startsAt := expiresAt.AddDate(-period.Years, -period.Months, -period.Days)
+	total := expiresAt.Sub(startsAt)
+	if total <= 0 {
+		return 0, time.Time{}, fmt.Errorf("plan %q has no period configured", plan.GetID())
+	}
+	if now.After(startsAt) {
+		startsAt = now
+	}
+	remaining := expiresAt.Sub(startsAt)
+	if remaining <= 0 {
+		return 0, expiresAt, nil
+	}
+	price := payment.PriceAmount
+	if price <= 0 || !strings.EqualFold(payment.PriceCurrency, currency) {
+		planPrice, ok := plan.GetPriceIn(currency)
+		if !ok {
+			return 0, time.Time{}, fmt.Errorf("plan %q has no price in %s", plan.GetID(), currency)
+		}
+		price = planPrice
+	}
+	return price * float64(remaining) / float64(total), expiresAt, nil
+}
+
+// Expired reports whether the quote can no longer be paid.
+func (q *UpgradeQuote) Expired(now time.Time) bool {
+	return !now.Before(q.ValidUntil)
+}
+
+// QuotedAt returns the time the quote was priced at.
+func (q *UpgradeQuote) QuotedAt() time.Time {
+	return q.ValidUntil.Add(-UpgradeQuoteValidity)
+}
+
+// Matches reports whether the quote prices the same upgrade as other: the same user,
+// target plan, currency, replaced payments and amounts.
+func (q *UpgradeQuote) Matches(other *UpgradeQuote) bool {
+	return other != nil &&
+		q.UserID == other.UserID &&
+		q.ToPlanID == other.ToPlanID &&
+		strings.EqualFold(q.Currency, other.Currency) &&
+		q.Credit == other.Credit &&
+		q.TargetPrice == other.TargetPrice &&
+		q.AmountDue == other.AmountDue &&
+		q.NewExpiresAt == other.NewExpiresAt &&
+		slices.Equal(q.ReplacesOrderIDs, other.ReplacesOrderIDs)
+}
+
+// NewUpgradeCheckoutRequest prepares the checkout for the amount due on the quote.
+func NewUpgradeCheckoutRequest(quote *UpgradeQuote, target config.SubscriptionPlan) *CheckoutRequest {
+	request := NewCheckoutRequest(target, quote.AmountDue, quote.Currency)
+	request.Description = fmt.Sprintf("Upgrade to %s", target.GetName())
+	return request
+}
+
+// WithUpgrade makes the payment the upgrade priced by the quote. Once it finishes, the
+// payments it replaces are superseded and its own expiry is set from the moment it
+// was paid.
+func WithUpgrade(quote *UpgradeQuote) PaymentDataOption {
+	return func(p *PaymentData) {
+		if quote == nil {
+			return
+		}
+		p.UpgradeOf = quote.ReplacesOrderIDs
+		p.UpgradeCredit = quote.Credit
+		p.ExpiresAt = quote.NewExpiresAt
+	}
+}
+
+func findPlan(plans []config.SubscriptionPlan, planID string) (config.SubscriptionPlan, bool) {
+	for _, plan := range plans {
+		if plan.GetID() == planID {
+			return plan, true
+		}
+	}
+	return nil, false
+}
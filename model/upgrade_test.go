@@ -0,0 +1,80 @@
+package model
+
+import (
+	"errors"
+	"github.com/antidote-recognize0663/comics-galore-library/config"
+	"testing"
+	"time"
+)
+
+func TestNewUpgradeQuote(t *testing.T) {
+	plans := *config.NewSubscriptionPlans()
+	monthly, yearly := plans[0], plans[3]
+	now := time.Date(2025, 6, 15, 0, 0, 0, 0, time.UTC)
+	current := &PaymentData{OrderID: "1-a", PlanID: "1", PriceAmount: 10, PriceCurrency: "usd", ExpiresAt: "2025-06-30T00:00:00Z"}
+	renewal := &PaymentData{OrderID: "1-b", PlanID: "1", PriceAmount: 9, PriceCurrency: "eur", ExpiresAt: "2025-07-30T00:00:00Z"}
+
+	testCases := []struct {
+		name         string
+		active       []*PaymentData
+		target       config.SubscriptionPlan
+		currency     string
+		expectCredit float64
+		expectDue    float64
+		expectErr    error
+	}{
+		// 15 of the 31 days paid for with 10 USD are left.
+		{name: "Half Used Month", active: []*PaymentData{current}, target: yearly, currency: "usd", expectCredit: 4.84, expectDue: 91.16},
+		// The renewal was paid in EUR, so the USD list price of the monthly plan is credited.
+		{name: "Renewal Not Started Yet", active: []*PaymentData{current, renewal}, target: yearly, currency: "usd", expectCredit: 14.84, expectDue: 81.16},
+		{name: "Same Plan", active: []*PaymentData{current}, target: monthly, currency: "usd", expectErr: ErrUpgradeSamePlan},
+		{name: "No Active Subscription", target: yearly, currency: "usd", expectErr: ErrNoActiveSubscription},
+		{
+			name:      "Downgrade",
+			active:    []*PaymentData{{OrderID: "4-a", PlanID: "4", PriceAmount: 96, PriceCurrency: "usd", ExpiresAt: "2026-06-01T00:00:00Z"}},
+			target:    plans[1],
+			currency:  "usd",
+			expectErr: ErrUpgradeNotAllowed,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			quote, err := NewUpgradeQuote("user", tc.active, plans, tc.target, tc.currency, now)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("Expected %v, got %v", tc.expectErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if quote.Credit != tc.expectCredit || quote.AmountDue != tc.expectDue {
+				t.Errorf("Expected a credit of %v and %v due, got %v and %v", tc.expectCredit, tc.expectDue, quote.Credit, quote.AmountDue)
+			}
+			if quote.FromPlanID != "1" || quote.NewExpiresAt != "2026-06-15T00:00:00Z" || len(quote.ReplacesOrderIDs) != len(tc.active) {
+				t.Errorf("Unexpected quote: %+v", quote)
+			}
+		})
+	}
+
+	quote, err := NewUpgradeQuote("user", []*PaymentData{current}, plans, yearly, "usd", now)
+	if err != nil {
+		t.Fatalf("NewUpgradeQuote failed: %v", err)
+	}
+	data := &PaymentData{UserID: "user", PlanID: "4", ExpiresAt: "2026-07-01T00:00:00Z"}
+	WithUpgrade(quote)(data)
+	if data.ExpiresAt != quote.NewExpiresAt || data.UpgradeCredit != 4.84 || data.UpgradeOf[0] != "1-a" {
+		t.Errorf("Unexpected upgrade payment: %+v", data)
+	}
+	if !quote.Expired(now.Add(UpgradeQuoteValidity)) || quote.Expired(now) {
+		t.Errorf("Expected the quote to be valid for %s", UpgradeQuoteValidity)
+	}
+
+	repriced, err := NewUpgradeQuote("user", []*PaymentData{current}, plans, yearly, "USD", quote.QuotedAt())
+	if err != nil || !repriced.Matches(quote) {
+		t.Errorf("Expected the quote to be reproduced at %s, got %+v, %v", quote.QuotedAt(), repriced, err)
+	}
+	tampered := *quote
+	tampered.AmountDue = 1
+	if tampered.Matches(quote) {
+		t.Errorf("Expected a quote with another amount due not to match")
+	}
+}
@@ -0,0 +1,93 @@
+package model
+
+import (
+	"fmt"
+	"github.com/appwrite/sdk-for-go/query"
+	"time"
+)
+
+const (
+	DefaultUserPageSize = 25
+	MaxUserPageSize     = 100
+)
+
+type UserStatus string
+
+const (
+	UserStatusAny     UserStatus = ""
+	UserStatusActive  UserStatus = "active"
+	UserStatusBlocked UserStatus = "blocked"
+)
+
+// UserFilter selects the users listed by account.Admin.ListUsers. Zero fields do not
+// filter. Search matches the name, email, phone and ID of the user. Pages hold Limit
+// users, DefaultUserPageSize by default, and the next page starts after Cursor, the
+// NextCursor of the previous UserList.
+type UserFilter struct {
+	Search           string
+	Status           UserStatus
+	Label            string
+	EmailVerified    *bool
+	RegisteredAfter  time.Time
+	RegisteredBefore time.Time
+	Limit            int
+	Cursor           string
+}
+
+// PageSize returns the number of users per page.
+func (f *UserFilter) PageSize() int {
+	switch {
+	case f.Limit <= 0:
+		return DefaultUserPageSize
+	case f.Limit > MaxUserPageSize:
+		return MaxUserPageSize
+	default:
+		return f.Limit
+	}
+}
+
+// Queries returns the Appwrite queries of the filter, newest users first. Search is
+// not part of them, it is passed to Appwrite separately.
+func (f *UserFilter) Queries() ([]string, error) {
+	queries := []string{query.Limit(f.PageSize()), query.OrderDesc("$createdAt")}
+	switch f.Status {
+	case UserStatusAny:
+	case UserStatusActive:
+		queries = append(queries, query.Equal("status", true))
+	case UserStatusBlocked:
+		queries = append(queries, query.Equal("status", false))
+	default:
+		return nil, fmt.Errorf("%w: unknown user status %q", ErrValidation, f.Status)
+	}
+	if f.Label != "" {
+		queries = append(queries, query.Contains("labels", f.Label))
+	}
+	if f.EmailVerified != nil {
+		queries = append(queries, query.Equal("emailVerification", *f.EmailVerified))
+	}
+	if !f.RegisteredAfter.IsZero() {
+		queries = append(queries, query.GreaterThanEqual("registration", f.RegisteredAfter.UTC().Format(time.RFC3339)))
+	}
+	if !f.RegisteredBefore.IsZero() {
+		queries = append(queries, query.LessThan("registration", f.RegisteredBefore.UTC().Format(time.RFC3339)))
+	}
+	if f.Cursor != "" {
+		queries = append(queries, query.CursorAfter(f.Cursor))
+	}
+	return queries, nil
+}
+
+// UserList is a page of users. NextCursor is empty on the last page.
+type UserList struct {
+	Total      int       `json:"total"`
+	Users      []Account `json:"users"`
+	NextCursor string    `json:"-"`
+}
+
+// UserOverview is what moderators see of a user: the account with its labels, and the
+// latest payments and uploads.
+type UserOverview struct {
+	Account  *Account
+	Payments *PaymentList
+	Uploads  *PostList
+}
@@ -15,34 +15,61 @@ type Admin interface {
 	SignUp(username, email, password string) (*model.Account, error)
 	PasswordReset(email string, recoveryUrl string) (*model.Token, error)
 	UpdateVerification(secret, userId string) (*model.Token, error)
+	ListSessions(userId string) (*model.SessionList, error)
+	DeleteSession(userId, sessionId string) error
+	DeleteSessions(userId string) error
+	ListUsers(filter *model.UserFilter) (*model.UserList, error)
+	BlockUser(userId string) (*model.Account, error)
+	UnblockUser(userId string) (*model.Account, error)
+	VerifyEmail(userId string) (*model.Account, error)
+	UpdateEmail(userId, email string) (*model.Account, error)
+	UpdateName(userId, name string) (*model.Account, error)
+	DeleteUser(userId string) error
+	GetUserOverview(userId string) (*model.UserOverview, error)
 }
 
 type admin struct {
-	apiKey    string
-	endpoint  string
-	projectID string
+	apiKey               string
+	endpoint             string
+	projectID            string
+	databaseID           string
+	paymentsDatabaseID   string
+	paymentsCollectionID string
+	postsCollectionID    string
 }
 
 func NewAdminWithConfig(config *config.Config) Admin {
 	return &admin{
-		apiKey:    config.Appwrite.ApiKey,
-		endpoint:  config.Appwrite.Endpoint,
-		projectID: config.Appwrite.ProjectID,
+		apiKey:               config.Appwrite.ApiKey,
+		endpoint:             config.Appwrite.Endpoint,
+		projectID:            config.Appwrite.ProjectID,
+		databaseID:           config.Appwrite.DatabaseID,
+		paymentsDatabaseID:   config.Appwrite.DatabaseID,
+		paymentsCollectionID: config.Appwrite.CollectionIDPayments,
+		postsCollectionID:    config.Appwrite.CollectionIDBlogposts,
 	}
 }
 
 func NewAdmin(options ...Option) Admin {
 	cfg := &Config{
-		endpoint:  "https://fra.cloud.appwrite.io/v1",
-		projectID: "6512130e80992b6c3e11",
+		endpoint:             "https://fra.cloud.appwrite.io/v1",
+		projectID:            "6512130e80992b6c3e11",
+		databaseID:           "651213bf7705981232aa",
+		paymentsDatabaseID:   "6510add9771bcf260b40",
+		paymentsCollectionID: "67806dd1003557f3794e",
+		postsCollectionID:    "65121414e190acfc7abd",
 	}
 	for _, option := range options {
 		option(cfg)
 	}
 	return &admin{
-		apiKey:    cfg.apiKey,
-		endpoint:  cfg.endpoint,
-		projectID: cfg.projectID,
+		apiKey:               cfg.apiKey,
+		endpoint:             cfg.endpoint,
+		projectID:            cfg.projectID,
+		databaseID:           cfg.databaseID,
+		paymentsDatabaseID:   cfg.paymentsDatabaseID,
+		paymentsCollectionID: cfg.paymentsCollectionID,
+		postsCollectionID:    cfg.postsCollectionID,
 	}
 }
 
@@ -56,7 +83,7 @@ func (s *admin) SignIn(email, password string) (*model.Session, error) {
 	account := appwrite.NewAccount(*utils.NewAdminClient(s.apiKey, utils.WithProject(s.projectID), utils.WithEndpoint(s.endpoint)))
 	session, err := account.CreateEmailPasswordSession(email, password)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create session: %w", err)
+		return nil, fmt.Errorf("failed to create session: %w", model.TranslateError(err))
 	}
 	return &model.Session{Session: session}, nil
 }
@@ -68,7 +95,7 @@ func (s *admin) GetUser(userId string) (*model.Account, error) {
 	users := appwrite.NewUsers(*utils.NewAdminClient(s.apiKey, utils.WithProject(s.projectID), utils.WithEndpoint(s.endpoint)))
 	user, err := users.Get(userId)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user %s: %w", userId, err)
+		return nil, fmt.Errorf("failed to get user %s: %w", userId, model.TranslateError(err))
 	}
 	return model.NewAccount(user), nil
 }
@@ -90,7 +117,7 @@ func (s *admin) SignUp(username, email, password string) (*model.Account, error)
 		users.WithCreatePassword(password),
 		users.WithCreateName(username))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
+		return nil, fmt.Errorf("failed to create user: %w", model.TranslateError(err))
 	}
 	return model.NewAccount(user), nil
 }
@@ -105,7 +132,7 @@ func (s *admin) PasswordReset(email string, recoveryUrl string) (*model.Token, e
 	account := appwrite.NewAccount(*utils.NewAdminClient(s.apiKey, utils.WithProject(s.projectID), utils.WithEndpoint(s.endpoint)))
 	token, err := account.CreateRecovery(email, recoveryUrl)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create password recovery: %w", err)
+		return nil, fmt.Errorf("failed to create password recovery: %w", model.TranslateError(err))
 	}
 	return &model.Token{Token: token}, nil
 }
@@ -120,7 +147,7 @@ func (s *admin) UpdateVerification(secret, userId string) (*model.Token, error)
 	account := appwrite.NewAccount(*utils.NewAdminClient(s.apiKey, utils.WithProject(s.projectID), utils.WithEndpoint(s.endpoint)))
 	token, err := account.UpdateVerification(userId, secret)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update verification: %w", err)
+		return nil, fmt.Errorf("failed to update verification: %w", model.TranslateError(err))
 	}
 	return &model.Token{Token: token}, nil
 }
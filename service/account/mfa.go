@@ -0,0 +1,174 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/antidote-recognize0663/comics-galore-library/utils"
+	"github.com/appwrite/sdk-for-go/account"
+	"github.com/appwrite/sdk-for-go/appwrite"
+	"net/http"
+)
+
+// CreateMfaAuthenticator starts the enrollment of a TOTP authenticator app. Render the
+// URI of the result with image.Qrcode for the user to scan, then confirm the enrollment
+// with VerifyMfaAuthenticator.
+func (s *session) CreateMfaAuthenticator(secret string) (*model.MfaAuthenticator, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("secret cannot be empty")
+	}
+	authenticator, err := s.sessionAccount(secret).CreateMfaAuthenticator(string(model.MfaFactorTotp))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create authenticator: %w", model.TranslateError(err))
+	}
+	return model.NewMfaAuthenticator(authenticator), nil
+}
+
+// VerifyMfaAuthenticator completes the enrollment of the TOTP authenticator with a code
+// generated by the app.
+func (s *session) VerifyMfaAuthenticator(secret, otp string) (*model.Account, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("secret cannot be empty")
+	}
+	if otp == "" {
+		return nil, fmt.Errorf("otp cannot be empty")
+	}
+	user, err := s.sessionAccount(secret).UpdateMfaAuthenticator(string(model.MfaFactorTotp), otp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify authenticator: %w", model.TranslateError(err))
+	}
+	return model.NewAccount(user), nil
+}
+
+// DeleteMfaAuthenticator removes the TOTP authenticator of the account.
+func (s *session) DeleteMfaAuthenticator(secret string) error {
+	if secret == "" {
+		return fmt.Errorf("secret cannot be empty")
+	}
+	if _, err := s.sessionAccount(secret).DeleteMfaAuthenticator(string(model.MfaFactorTotp)); err != nil {
+		return fmt.Errorf("failed to delete authenticator: %w", model.TranslateError(err))
+	}
+	return nil
+}
+
+// UpdateMfa turns multi-factor authentication on or off for the account. Generate
+// recovery codes with CreateMfaRecoveryCodes before turning it on.
+func (s *session) UpdateMfa(secret string, enabled bool) (*model.Account, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("secret cannot be empty")
+	}
+	user, err := s.sessionAccount(secret).UpdateMFA(enabled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update MFA: %w", model.TranslateError(err))
+	}
+	return model.NewAccount(user), nil
+}
+
+// ListMfaFactors returns the factors enrolled for the account.
+func (s *session) ListMfaFactors(secret string) (*model.MfaFactors, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("secret cannot be empty")
+	}
+	factors, err := s.sessionAccount(secret).ListMfaFactors()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list MFA factors: %w", model.TranslateError(err))
+	}
+	return &model.MfaFactors{MfaFactors: factors}, nil
+}
+
+// CreateMfaRecoveryCodes generates the one-time recovery codes of the account. They can
+// only be generated once; use RegenerateMfaRecoveryCodes afterwards.
+func (s *session) CreateMfaRecoveryCodes(secret string) (*model.MfaRecoveryCodes, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("secret cannot be empty")
+	}
+	codes, err := s.sessionAccount(secret).CreateMfaRecoveryCodes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recovery codes: %w", model.TranslateError(err))
+	}
+	return &model.MfaRecoveryCodes{MfaRecoveryCodes: codes}, nil
+}
+
+// GetMfaRecoveryCodes returns the unused recovery codes. Appwrite requires a session
+// that completed an MFA challenge recently.
+func (s *session) GetMfaRecoveryCodes(secret string) (*model.MfaRecoveryCodes, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("secret cannot be empty")
+	}
+	codes, err := s.sessionAccount(secret).GetMfaRecoveryCodes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recovery codes: %w", model.TranslateError(err))
+	}
+	return &model.MfaRecoveryCodes{MfaRecoveryCodes: codes}, nil
+}
+
+// RegenerateMfaRecoveryCodes replaces the recovery codes, invalidating the old ones.
+func (s *session) RegenerateMfaRecoveryCodes(secret string) (*model.MfaRecoveryCodes, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("secret cannot be empty")
+	}
+	codes, err := s.sessionAccount(secret).UpdateMfaRecoveryCodes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to regenerate recovery codes: %w", model.TranslateError(err))
+	}
+	return &model.MfaRecoveryCodes{MfaRecoveryCodes: codes}, nil
+}
+
+// MfaRequired reports whether the session still has to complete an MFA challenge before
+// it can be used, which is the case right after signing in to an account with MFA on.
+func (s *session) MfaRequired(secret string) (bool, error) {
+	if secret == "" {
+		return false, fmt.Errorf("secret cannot be empty")
+	}
+	_, err := s.sessionAccount(secret).Get()
+	if err == nil {
+		return false, nil
+	}
+	err = model.TranslateError(err)
+	var serviceErr *model.ServiceError
+	if errors.As(err, &serviceErr) && serviceErr.Code == http.StatusUnauthorized && serviceErr.Type == "user_more_factors_required" {
+		return true, nil
+	}
+	return false, fmt.Errorf("failed to get account: %w", err)
+}
+
+// CreateMfaChallenge starts an MFA challenge for a session that requires one. A code is
+// sent for the email and phone factors; TOTP and recovery codes are at hand already.
+func (s *session) CreateMfaChallenge(secret string, factor model.MfaFactor) (*model.MfaChallenge, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("secret cannot be empty")
+	}
+	switch factor {
+	case model.MfaFactorTotp, model.MfaFactorEmail, model.MfaFactorPhone, model.MfaFactorRecoveryCode:
+	default:
+		return nil, fmt.Errorf("unknown MFA factor %q", factor)
+	}
+	challenge, err := s.sessionAccount(secret).CreateMfaChallenge(string(factor))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create MFA challenge: %w", model.TranslateError(err))
+	}
+	return &model.MfaChallenge{MfaChallenge: challenge}, nil
+}
+
+// CompleteMfaChallenge verifies the session with the code of the challenge, finishing
+// the sign-in.
+func (s *session) CompleteMfaChallenge(secret, challengeID, otp string) (*model.Session, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("secret cannot be empty")
+	}
+	if challengeID == "" {
+		return nil, fmt.Errorf("challengeID cannot be empty")
+	}
+	if otp == "" {
+		return nil, fmt.Errorf("otp cannot be empty")
+	}
+	session, err := s.sessionAccount(secret).UpdateMfaChallenge(challengeID, otp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete MFA challenge: %w", model.TranslateError(err))
+	}
+	return &model.Session{Session: session}, nil
+}
+
+func (s *session) sessionAccount(secret string) *account.Account {
+	return appwrite.NewAccount(*utils.NewSessionClient(secret, utils.WithEndpoint(s.endpoint), utils.WithProject(s.projectID)))
+}
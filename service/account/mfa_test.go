@@ -0,0 +1,66 @@
+package account
+
+import (
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMfa(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		secret := r.Header.Get("X-Appwrite-Session")
+		switch {
+		case r.URL.Path == "/v1/account" && secret == "unverified":
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"message":"More factors are required","code":401,"type":"user_more_factors_required"}`))
+		case r.URL.Path == "/v1/account" && secret == "expired":
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"message":"Unauthorized","code":401,"type":"general_unauthorized_scope"}`))
+		case r.URL.Path == "/v1/account":
+			_, _ = w.Write([]byte(`{"$id":"user-1","mfa":true,"prefs":{}}`))
+		case r.URL.Path == "/v1/account/mfa/authenticators/totp" && r.Method == http.MethodPost:
+			_, _ = w.Write([]byte(`{"secret":"JBSWY3DPEHPK3PXP","uri":"otpauth://totp/Comics:user@example.com?secret=JBSWY3DPEHPK3PXP"}`))
+		case r.URL.Path == "/v1/account/mfa/factors":
+			_, _ = w.Write([]byte(`{"totp":true,"phone":false,"email":true,"recoveryCode":true}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"not found","code":404}`))
+		}
+	}))
+	defer server.Close()
+	s := NewSession(WithEndpoint(server.URL+"/v1"), WithProject("project"))
+
+	for secret, expected := range map[string]bool{"unverified": true, "verified": false} {
+		required, err := s.MfaRequired(secret)
+		if err != nil || required != expected {
+			t.Errorf("MfaRequired(%s): expected %v, got %v, %v", secret, expected, required, err)
+		}
+	}
+	if _, err := s.MfaRequired("expired"); err == nil {
+		t.Errorf("Expected other authorization errors to be returned")
+	}
+
+	authenticator, err := s.CreateMfaAuthenticator("verified")
+	if err != nil {
+		t.Fatalf("CreateMfaAuthenticator failed: %v", err)
+	}
+	if authenticator.Secret != "JBSWY3DPEHPK3PXP" || !strings.HasPrefix(authenticator.URI, "otpauth://totp/") {
+		t.Errorf("Unexpected authenticator: %+v", authenticator)
+	}
+
+	factors, err := s.ListMfaFactors("verified")
+	if err != nil {
+		t.Fatalf("ListMfaFactors failed: %v", err)
+	}
+	enabled := factors.Enabled()
+	if len(enabled) != 3 || enabled[0] != model.MfaFactorTotp || enabled[2] != model.MfaFactorRecoveryCode {
+		t.Errorf("Unexpected factors: %v", enabled)
+	}
+
+	if _, err := s.CreateMfaChallenge("unverified", "sms"); err == nil {
+		t.Errorf("Expected an unknown factor to be rejected")
+	}
+}
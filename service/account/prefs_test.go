@@ -0,0 +1,56 @@
+package account
+
+import (
+	"encoding/json"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUpdatePreferences(t *testing.T) {
+	var stored map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/account/prefs":
+			_, _ = w.Write([]byte(`{"avatar_id":"avatar-1","tumblr":"reader","theme":"dark"}`))
+		case r.Method == http.MethodPatch && r.URL.Path == "/v1/account/prefs":
+			var body struct {
+				Prefs map[string]interface{} `json:"prefs"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			stored = body.Prefs
+			user, _ := json.Marshal(map[string]interface{}{"$id": "user-1", "name": "Reader", "prefs": body.Prefs})
+			_, _ = w.Write(user)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"not found","code":404}`))
+		}
+	}))
+	defer server.Close()
+	s := NewSession(WithEndpoint(server.URL+"/v1"), WithProject("project"))
+
+	prefs, err := s.GetPrefs("secret")
+	if err != nil {
+		t.Fatalf("GetPrefs failed: %v", err)
+	}
+	if prefs.Version != model.PrefsVersion || prefs.AvatarID != "avatar-1" || !prefs.BlurNSFW {
+		t.Errorf("Expected the legacy prefs to be upgraded, got %+v", prefs)
+	}
+
+	blur := false
+	account, err := s.UpdatePreferences("secret", &model.PrefsPatch{BlurNSFW: &blur})
+	if err != nil {
+		t.Fatalf("UpdatePreferences failed: %v", err)
+	}
+	if stored["avatar_id"] != "avatar-1" || stored["tumblr"] != "reader" || stored["theme"] != "dark" || stored["blur_nsfw"] != false {
+		t.Errorf("Expected a partial merge, stored %v", stored)
+	}
+	if account.Id != "user-1" || account.Prefs.BlurNSFW || account.Prefs.Tumblr != "reader" {
+		t.Errorf("Unexpected account: %+v", account.Prefs)
+	}
+	if _, err := s.UpdatePreferences("secret", nil); err == nil {
+		t.Errorf("Expected a nil patch to be rejected")
+	}
+}
@@ -7,15 +7,36 @@ import (
 	"github.com/antidote-recognize0663/comics-galore-library/utils"
 	"github.com/appwrite/sdk-for-go/appwrite"
 	"github.com/appwrite/sdk-for-go/models"
+	"net/url"
 	"resty.dev/v3"
 )
 
 type Session interface {
-	//SignIn(email, password string) (*model.Session, error)
+	SignIn(email, password string) (*model.Session, error)
+	OAuth2URL(provider, successURL, failureURL string, scopes ...string) (string, error)
+	CreateMagicURLToken(email, redirectURL string) (*model.Token, error)
+	CreateEmailToken(email string) (*model.Token, error)
+	SignInWithToken(userID, secret string) (*model.Session, error)
+	SignInWithCallback(query url.Values) (*model.Session, error)
+	MfaRequired(secret string) (bool, error)
+	CreateMfaChallenge(secret string, factor model.MfaFactor) (*model.MfaChallenge, error)
+	CompleteMfaChallenge(secret, challengeID, otp string) (*model.Session, error)
+	CreateMfaAuthenticator(secret string) (*model.MfaAuthenticator, error)
+	VerifyMfaAuthenticator(secret, otp string) (*model.Account, error)
+	DeleteMfaAuthenticator(secret string) error
+	UpdateMfa(secret string, enabled bool) (*model.Account, error)
+	ListMfaFactors(secret string) (*model.MfaFactors, error)
+	CreateMfaRecoveryCodes(secret string) (*model.MfaRecoveryCodes, error)
+	GetMfaRecoveryCodes(secret string) (*model.MfaRecoveryCodes, error)
+	RegenerateMfaRecoveryCodes(secret string) (*model.MfaRecoveryCodes, error)
 	DeleteCurrentSession(secret string) error
+	ListSessions(secret string) (*model.SessionList, error)
+	GetSession(secret, sessionID string) (*model.DeviceSession, error)
+	DeleteSession(secret, sessionID string) error
+	DeleteOtherSessions(secret string) (int, error)
 	GetAccount(secret string) (*model.Account, error)
 	GetAccount2(secret string) (*model.Account, error)
-	UpdatePreferences(secret string, prefs *model.Prefs) (*model.Account, error)
+	UpdatePreferences(secret string, patch *model.PrefsPatch) (*model.Account, error)
 	CreateVerification(secret string, verificationUrl string) (*model.Token, error)
 	VerifyAccount(secret, userId string) (*model.Token, error)
 	UpdateName(secret, name string) (*model.Account, error)
@@ -25,6 +46,7 @@ type Session interface {
 }
 
 type session struct {
+	apiKey    string
 	endpoint  string
 	projectID string
 }
@@ -37,15 +59,16 @@ func (s *session) GetAccount2(secret string) (*model.Account, error) {
 		secret, utils.WithEndpoint(s.endpoint), utils.WithProject(s.projectID)))
 	data, err := account.Get()
 	if err != nil {
-		return nil, fmt.Errorf("error getting account: %v", err)
+		return nil, fmt.Errorf("error getting account: %w", model.TranslateError(err))
 	}
 	var response model.Account
 	if err := data.Decode(&response); err != nil {
-		return nil, fmt.Errorf("error decoding account: %v", err)
+		return nil, fmt.Errorf("error decoding account: %w", err)
 	}
 	return &response, nil
 }
 
+// GetPrefs returns the user's prefs, upgraded to model.PrefsVersion.
 func (s *session) GetPrefs(secret string) (*model.Prefs, error) {
 	if secret == "" {
 		return nil, fmt.Errorf("secret cannot be empty")
@@ -54,28 +77,35 @@ func (s *session) GetPrefs(secret string) (*model.Prefs, error) {
 		secret, utils.WithEndpoint(s.endpoint), utils.WithProject(s.projectID)))
 	prefs, err := account.GetPrefs()
 	if err != nil {
-		return nil, fmt.Errorf("error getting prefs: %v", err)
+		return nil, fmt.Errorf("error getting prefs: %w", model.TranslateError(err))
 	}
 	var preferences model.Prefs
 	if err := prefs.Decode(&preferences); err != nil {
-		return nil, fmt.Errorf("failed to decode prefs: %v", err)
+		return nil, fmt.Errorf("failed to decode prefs: %w", err)
 	}
-	return &model.Prefs{
-		Tumblr:   preferences.Tumblr,
-		Twitter:  preferences.Twitter,
-		AvatarID: preferences.AvatarID,
-		Facebook: preferences.Facebook,
-	}, nil
+	return &preferences, nil
 }
 
 type Config struct {
-	apiKey    string
-	endpoint  string
-	projectID string
+	apiKey               string
+	endpoint             string
+	projectID            string
+	databaseID           string
+	paymentsDatabaseID   string
+	paymentsCollectionID string
+	postsCollectionID    string
 }
 
 type Option func(*Config)
 
+// WithApiKey sets the API key of the Admin, which the Session also creates sessions
+// with, see Session.SignIn.
+func WithApiKey(apiKey string) Option {
+	return func(c *Config) {
+		c.apiKey = apiKey
+	}
+}
+
 func WithEndpoint(endpoint string) Option {
 	return func(c *Config) {
 		c.endpoint = endpoint
@@ -88,6 +118,34 @@ func WithProject(projectID string) Option {
 	}
 }
 
+// WithDatabaseID sets the database of the posts collection, which the Admin reads for
+// GetUserOverview.
+func WithDatabaseID(databaseID string) Option {
+	return func(c *Config) {
+		c.databaseID = databaseID
+	}
+}
+
+// WithPaymentsDatabaseID sets the database of the payments collection, which the Admin
+// reads for GetUserOverview.
+func WithPaymentsDatabaseID(databaseID string) Option {
+	return func(c *Config) {
+		c.paymentsDatabaseID = databaseID
+	}
+}
+
+func WithPaymentsCollectionID(collectionID string) Option {
+	return func(c *Config) {
+		c.paymentsCollectionID = collectionID
+	}
+}
+
+func WithPostsCollectionID(collectionID string) Option {
+	return func(c *Config) {
+		c.postsCollectionID = collectionID
+	}
+}
+
 func (s *session) UpdateName(secret, name string) (*model.Account, error) {
 	if secret == "" {
 		return nil, fmt.Errorf("secret cannot be empty")
@@ -96,7 +154,7 @@ func (s *session) UpdateName(secret, name string) (*model.Account, error) {
 		secret, utils.WithEndpoint(s.endpoint), utils.WithProject(s.projectID)))
 	user, err := account.UpdateName(name)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update username %w", err)
+		return nil, fmt.Errorf("failed to update username %w", model.TranslateError(err))
 	}
 	return model.NewAccount(user), nil
 }
@@ -109,7 +167,7 @@ func (s *session) UpdateEmail(secret, email, password string) (*model.Account, e
 		secret, utils.WithEndpoint(s.endpoint), utils.WithProject(s.projectID)))
 	user, err := account.UpdateEmail(email, password)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update email %w", err)
+		return nil, fmt.Errorf("failed to update email %w", model.TranslateError(err))
 	}
 	return model.NewAccount(user), nil
 }
@@ -122,13 +180,14 @@ func (s *session) UpdatePassword(secret, oldPassword, newPassword string) (*mode
 		secret, utils.WithEndpoint(s.endpoint), utils.WithProject(s.projectID)))
 	user, err := account.UpdatePassword(newPassword, account.WithUpdatePasswordOldPassword(oldPassword))
 	if err != nil {
-		return nil, fmt.Errorf("failed to update password %w", err)
+		return nil, fmt.Errorf("failed to update password %w", model.TranslateError(err))
 	}
 	return model.NewAccount(user), nil
 }
 
 func NewSessionWithConfig(config *config.Config) Session {
 	return &session{
+		apiKey:    config.Appwrite.ApiKey,
 		endpoint:  config.Appwrite.Endpoint,
 		projectID: config.Appwrite.ProjectID,
 	}
@@ -143,27 +202,12 @@ func NewSession(options ...Option) Session {
 		option(cfg)
 	}
 	return &session{
+		apiKey:    cfg.apiKey,
 		endpoint:  cfg.endpoint,
 		projectID: cfg.projectID,
 	}
 }
 
-/*func (s *session) SignIn(email, password string) (*model.Session, error) {
-	if email == "" {
-		return nil, fmt.Errorf("email cannot be empty")
-	}
-	if password == "" {
-		return nil, fmt.Errorf("password cannot be empty")
-	}
-	client := appwrite.NewClient(appwrite.WithEndpoint(s.endpoint), appwrite.WithProject(s.projectID))
-	account := appwrite.NewAccount(client)
-	session, err := account.CreateEmailPasswordSession(email, password)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create session: %w", err)
-	}
-	return &model.Session{Session: session}, nil
-}*/
-
 func (s *session) DeleteCurrentSession(secret string) error {
 	if secret == "" {
 		return fmt.Errorf("secret cannot be empty")
@@ -173,7 +217,7 @@ func (s *session) DeleteCurrentSession(secret string) error {
 
 	_, err := account.DeleteSession("current")
 	if err != nil {
-		return fmt.Errorf("failed to delete session: %w", err)
+		return fmt.Errorf("failed to delete session: %w", model.TranslateError(err))
 	}
 	return nil
 }
@@ -189,7 +233,7 @@ func (s *session) GetAccount(secret string) (*model.Account, error) {
 			SetResult(&models.User{}).
 			Get(s.endpoint + "/account")
 		if err != nil {
-			return nil, fmt.Errorf("failed to retrieve account information: %w", err)
+			return nil, fmt.Errorf("failed to retrieve account information: %w", model.TranslateError(err))
 		}
 		data := resp.Result().(*models.User)
 		return model.NewAccount(data), nil
@@ -197,22 +241,32 @@ func (s *session) GetAccount(secret string) (*model.Account, error) {
 	return nil, fmt.Errorf("secret is empty")
 }
 
-func (s *session) UpdatePreferences(secret string, prefs *model.Prefs) (*model.Account, error) {
-	request := map[string]interface{}{"prefs": *prefs}
-	client := resty.New()
-	resp, err := client.R().
-		SetHeader("Content-Type", "application/json").
-		SetHeader("X-Appwrite-Session", secret).
-		SetHeader("X-Appwrite-Project", s.projectID).
-		SetHeader("X-Appwrite-Response-Format", "1.6.0").
-		SetBody(request).
-		SetResult(&models.User{}).
-		Patch(s.endpoint + "/account/prefs")
+// UpdatePreferences merges the patch into the user's current prefs.
+func (s *session) UpdatePreferences(secret string, patch *model.PrefsPatch) (*model.Account, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("secret cannot be empty")
+	}
+	if patch == nil {
+		return nil, fmt.Errorf("patch cannot be nil")
+	}
+	account := s.sessionAccount(secret)
+	prefs, err := account.GetPrefs()
+	if err != nil {
+		return nil, fmt.Errorf("error getting prefs: %w", model.TranslateError(err))
+	}
+	var current map[string]interface{}
+	if err := prefs.Decode(&current); err != nil {
+		return nil, fmt.Errorf("failed to decode prefs: %w", err)
+	}
+	merged, err := patch.Merge(current)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update preferences: %w", err)
+		return nil, err
 	}
-	data := resp.Result().(*models.User)
-	return model.NewAccount(data), nil
+	user, err := account.UpdatePrefs(merged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update preferences: %w", model.TranslateError(err))
+	}
+	return model.NewAccount(user), nil
 }
 
 func (s *session) CreateVerification(secret string, verificationUrl string) (*model.Token, error) {
@@ -223,7 +277,7 @@ func (s *session) CreateVerification(secret string, verificationUrl string) (*mo
 		secret, utils.WithEndpoint(s.endpoint), utils.WithProject(s.projectID)))
 	token, err := account.CreateVerification(verificationUrl)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create verification link: %w", err)
+		return nil, fmt.Errorf("failed to create verification link: %w", model.TranslateError(err))
 	}
 	return &model.Token{Token: token}, nil
 }
@@ -233,7 +287,7 @@ func (s *session) VerifyAccount(secret, userId string) (*model.Token, error) {
 		secret, utils.WithEndpoint(s.endpoint), utils.WithProject(s.projectID)))
 	token, err := account.UpdateVerification(userId, secret)
 	if err != nil {
-		return nil, fmt.Errorf("failed to verify account %s: %w", userId, err)
+		return nil, fmt.Errorf("failed to verify account %s: %w", userId, model.TranslateError(err))
 	}
 	return &model.Token{Token: token}, nil
 	//TODO: increment number of registered user on admin backend
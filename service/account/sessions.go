@@ -0,0 +1,116 @@
+package account
+
+import (
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/antidote-recognize0663/comics-galore-library/utils"
+	"github.com/appwrite/sdk-for-go/appwrite"
+)
+
+// ListSessions lists the sessions of the signed-in user, one per device, the current one
+// flagged as such.
+func (s *session) ListSessions(secret string) (*model.SessionList, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("secret cannot be empty")
+	}
+	sessions, err := s.sessionAccount(secret).ListSessions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", model.TranslateError(err))
+	}
+	return model.NewSessionList(sessions), nil
+}
+
+// GetSession returns one of the signed-in user's sessions, or the current one for the
+// ID "current".
+func (s *session) GetSession(secret, sessionID string) (*model.DeviceSession, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("secret cannot be empty")
+	}
+	if sessionID == "" {
+		return nil, fmt.Errorf("sessionID cannot be empty")
+	}
+	session, err := s.sessionAccount(secret).GetSession(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session %s: %w", sessionID, model.TranslateError(err))
+	}
+	return model.NewDeviceSession(session), nil
+}
+
+// DeleteSession signs the user out of one of their sessions.
+func (s *session) DeleteSession(secret, sessionID string) error {
+	if secret == "" {
+		return fmt.Errorf("secret cannot be empty")
+	}
+	if sessionID == "" {
+		return fmt.Errorf("sessionID cannot be empty")
+	}
+	if _, err := s.sessionAccount(secret).DeleteSession(sessionID); err != nil {
+		return fmt.Errorf("failed to delete session %s: %w", sessionID, model.TranslateError(err))
+	}
+	return nil
+}
+
+// DeleteOtherSessions signs the user out of every session but the current one and
+// returns how many were deleted.
+func (s *session) DeleteOtherSessions(secret string) (int, error) {
+	if secret == "" {
+		return 0, fmt.Errorf("secret cannot be empty")
+	}
+	sessionAccount := s.sessionAccount(secret)
+	sessions, err := sessionAccount.ListSessions()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list sessions: %w", model.TranslateError(err))
+	}
+	deleted := 0
+	for _, session := range sessions.Sessions {
+		if session.Current {
+			continue
+		}
+		if _, err := sessionAccount.DeleteSession(session.Id); err != nil {
+			return deleted, fmt.Errorf("failed to delete session %s: %w", session.Id, model.TranslateError(err))
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// ListSessions lists the sessions of a user.
+func (s *admin) ListSessions(userId string) (*model.SessionList, error) {
+	if userId == "" {
+		return nil, fmt.Errorf("userId cannot be empty")
+	}
+	users := appwrite.NewUsers(*utils.NewAdminClient(s.apiKey, utils.WithProject(s.projectID), utils.WithEndpoint(s.endpoint)))
+	sessions, err := users.ListSessions(userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions of user %s: %w", userId, model.TranslateError(err))
+	}
+	return model.NewSessionList(sessions), nil
+}
+
+// DeleteSession signs a user out of one session.
+func (s *admin) DeleteSession(userId, sessionId string) error {
+	if userId == "" {
+		return fmt.Errorf("userId cannot be empty")
+	}
+	if sessionId == "" {
+		return fmt.Errorf("sessionId cannot be empty")
+	}
+	users := appwrite.NewUsers(*utils.NewAdminClient(s.apiKey, utils.WithProject(s.projectID), utils.WithEndpoint(s.endpoint)))
+	if _, err := users.DeleteSession(userId, sessionId); err != nil {
+		return fmt.Errorf("failed to delete session %s of user %s: %w", sessionId, userId, model.TranslateError(err))
+	}
+	return nil
+}
+
+// DeleteSessions signs a user out everywhere, for instance after a chargeback or an
+// abuse report.
+func (s *admin) DeleteSessions(userId string) error {
+	if userId == "" {
+		return fmt.Errorf("userId cannot be empty")
+	}
+	users := appwrite.NewUsers(*utils.NewAdminClient(s.apiKey, utils.WithProject(s.projectID), utils.WithEndpoint(s.endpoint)))
+	if _, err := users.DeleteSessions(userId); err != nil {
+		return fmt.Errorf("failed to delete sessions of user %s: %w", userId, model.TranslateError(err))
+	}
+	return nil
+}
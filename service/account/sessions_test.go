@@ -0,0 +1,52 @@
+package account
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDeleteOtherSessions(t *testing.T) {
+	var mu sync.Mutex
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/account/sessions":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"total":3,"sessions":[
+				{"$id":"phone","clientName":"Mobile Safari","clientVersion":"17.2","osName":"iOS","osVersion":"17.2","ip":"203.0.113.7","countryCode":"de","countryName":"Germany","secret":"leaked?"},
+				{"$id":"laptop","clientName":"Firefox","osName":"Linux","current":true},
+				{"$id":"tablet","clientName":"Chrome","osName":"Android"}]}`))
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/v1/account/sessions/"):
+			mu.Lock()
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/v1/account/sessions/"))
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"not found","code":404}`))
+		}
+	}))
+	defer server.Close()
+	s := NewSession(WithEndpoint(server.URL+"/v1"), WithProject("project"))
+
+	list, err := s.ListSessions("secret")
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	phone := list.Sessions[0]
+	if list.Total != 3 || phone.Client != "Mobile Safari 17.2" || phone.OS != "iOS 17.2" || phone.Country != "Germany" || phone.IP != "203.0.113.7" {
+		t.Errorf("Unexpected sessions: %+v", list)
+	}
+
+	count, err := s.DeleteOtherSessions("secret")
+	if err != nil {
+		t.Fatalf("DeleteOtherSessions failed: %v", err)
+	}
+	if count != 2 || len(deleted) != 2 || deleted[0] != "phone" || deleted[1] != "tablet" {
+		t.Errorf("Expected the phone and tablet sessions to be deleted, got %v", deleted)
+	}
+}
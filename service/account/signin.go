@@ -0,0 +1,134 @@
+package account
+
+import (
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/antidote-recognize0663/comics-galore-library/utils"
+	"github.com/appwrite/sdk-for-go/account"
+	"github.com/appwrite/sdk-for-go/appwrite"
+	"github.com/appwrite/sdk-for-go/id"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var oauth2ProviderPattern = regexp.MustCompile(`^[a-z0-9]+$`)
+
+// SignIn creates an email and password session. Like every sign-in of the Session, it
+// is made with the API key, so the returned session carries the secret to store in the
+// session cookie.
+func (s *session) SignIn(email, password string) (*model.Session, error) {
+	if email == "" {
+		return nil, fmt.Errorf("email cannot be empty")
+	}
+	if password == "" {
+		return nil, fmt.Errorf("password cannot be empty")
+	}
+	adminAccount, err := s.adminAccount()
+	if err != nil {
+		return nil, err
+	}
+	session, err := adminAccount.CreateEmailPasswordSession(email, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", model.TranslateError(err))
+	}
+	return &model.Session{Session: session}, nil
+}
+
+// OAuth2URL returns the URL to send the browser to for signing in with an OAuth2
+// provider enabled in the Appwrite console, such as "google" or "github". Appwrite
+// redirects to successURL with the userId and secret query parameters, which are
+// exchanged for a session with SignInWithCallback, or to failureURL.
+func (s *session) OAuth2URL(provider, successURL, failureURL string, scopes ...string) (string, error) {
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	if !oauth2ProviderPattern.MatchString(provider) {
+		return "", fmt.Errorf("invalid OAuth2 provider %q", provider)
+	}
+	if successURL == "" {
+		return "", fmt.Errorf("successURL cannot be empty")
+	}
+	params := url.Values{}
+	params.Set("project", s.projectID)
+	params.Set("success", successURL)
+	if failureURL != "" {
+		params.Set("failure", failureURL)
+	}
+	for _, scope := range scopes {
+		params.Add("scopes[]", scope)
+	}
+	return fmt.Sprintf("%s/account/tokens/oauth2/%s?%s", strings.TrimSuffix(s.endpoint, "/"), provider, params.Encode()), nil
+}
+
+// CreateMagicURLToken emails the user a sign-in link to redirectURL, valid for one
+// hour. The link carries the userId and secret query parameters, see
+// SignInWithCallback. A user is created for an unknown email address.
+func (s *session) CreateMagicURLToken(email, redirectURL string) (*model.Token, error) {
+	if email == "" {
+		return nil, fmt.Errorf("email cannot be empty")
+	}
+	if redirectURL == "" {
+		return nil, fmt.Errorf("redirectURL cannot be empty")
+	}
+	adminAccount, err := s.adminAccount()
+	if err != nil {
+		return nil, err
+	}
+	token, err := adminAccount.CreateMagicURLToken(id.Unique(), email, adminAccount.WithCreateMagicURLTokenUrl(redirectURL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create magic URL token: %w", model.TranslateError(err))
+	}
+	return &model.Token{Token: token}, nil
+}
+
+// CreateEmailToken emails the user a one-time code, valid for 15 minutes. The code is
+// exchanged for a session with SignInWithToken, together with the UserId of the
+// returned token. A user is created for an unknown email address.
+func (s *session) CreateEmailToken(email string) (*model.Token, error) {
+	if email == "" {
+		return nil, fmt.Errorf("email cannot be empty")
+	}
+	adminAccount, err := s.adminAccount()
+	if err != nil {
+		return nil, err
+	}
+	token, err := adminAccount.CreateEmailToken(id.Unique(), email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create email token: %w", model.TranslateError(err))
+	}
+	return &model.Token{Token: token}, nil
+}
+
+// SignInWithToken exchanges a token secret, such as an email one-time code or the
+// secret of an OAuth2 or magic URL callback, for a session.
+func (s *session) SignInWithToken(userID, secret string) (*model.Session, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("userId cannot be empty")
+	}
+	if secret == "" {
+		return nil, fmt.Errorf("secret cannot be empty")
+	}
+	adminAccount, err := s.adminAccount()
+	if err != nil {
+		return nil, err
+	}
+	session, err := adminAccount.CreateSession(userID, strings.TrimSpace(secret))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session from token: %w", model.TranslateError(err))
+	}
+	return &model.Session{Session: session}, nil
+}
+
+// SignInWithCallback completes an OAuth2 or magic URL sign-in from the query string
+// Appwrite redirected the browser with.
+func (s *session) SignInWithCallback(query url.Values) (*model.Session, error) {
+	return s.SignInWithToken(query.Get("userId"), query.Get("secret"))
+}
+
+// adminAccount returns the Account service authenticated with the API key. Without the
+// key Appwrite would create sessions without returning their secret.
+func (s *session) adminAccount() (*account.Account, error) {
+	if s.apiKey == "" {
+		return nil, fmt.Errorf("an API key is required to sign in, see WithApiKey")
+	}
+	return appwrite.NewAccount(*utils.NewAdminClient(s.apiKey, utils.WithProject(s.projectID), utils.WithEndpoint(s.endpoint))), nil
+}
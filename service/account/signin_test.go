@@ -0,0 +1,77 @@
+package account
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestOAuth2URL(t *testing.T) {
+	s := NewSession(WithEndpoint("https://appwrite.example.com/v1/"), WithProject("project"))
+	link, err := s.OAuth2URL("GitHub", "https://comics.example.com/auth/callback", "https://comics.example.com/signin", "read:user", "user:email")
+	if err != nil {
+		t.Fatalf("OAuth2URL failed: %v", err)
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("Invalid URL %q: %v", link, err)
+	}
+	query := parsed.Query()
+	if parsed.Host != "appwrite.example.com" || parsed.Path != "/v1/account/tokens/oauth2/github" ||
+		query.Get("project") != "project" || query.Get("success") != "https://comics.example.com/auth/callback" ||
+		query.Get("failure") != "https://comics.example.com/signin" || len(query["scopes[]"]) != 2 {
+		t.Errorf("Unexpected OAuth2 URL %q", link)
+	}
+	if _, err := s.OAuth2URL("../admin", "https://comics.example.com/auth/callback", ""); err == nil {
+		t.Errorf("Expected an invalid provider to be rejected")
+	}
+	if _, err := s.OAuth2URL("google", "", ""); err == nil {
+		t.Errorf("Expected a missing success URL to be rejected")
+	}
+}
+
+func TestSignInWithCallback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/account/sessions/token" || r.Header.Get("X-Appwrite-Key") != "api-key" {
+			http.Error(w, `{"message":"unexpected request","code":400}`, http.StatusBadRequest)
+			return
+		}
+		if body["userId"] != "user-1" || body["secret"] != "123456" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"message":"Invalid token","code":401,"type":"user_invalid_token"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"$id":"session-1","userId":"user-1","secret":"session-secret"}`))
+	}))
+	defer server.Close()
+
+	s := NewSession(WithEndpoint(server.URL+"/v1"), WithProject("project"), WithApiKey("api-key"))
+	session, err := s.SignInWithCallback(url.Values{"userId": {"user-1"}, "secret": {"123456"}})
+	if err != nil {
+		t.Fatalf("SignInWithCallback failed: %v", err)
+	}
+	if session.Id != "session-1" || session.Secret != "session-secret" {
+		t.Errorf("Unexpected session: %+v", session.Session)
+	}
+	if _, err := s.SignInWithToken("user-1", "000000"); err == nil || !strings.Contains(err.Error(), "Invalid token") {
+		t.Errorf("Expected a wrong code to be rejected, got %v", err)
+	}
+	if _, err := s.SignInWithCallback(url.Values{}); err == nil {
+		t.Errorf("Expected a callback without token to be rejected")
+	}
+
+	withoutKey := NewSession(WithEndpoint(server.URL+"/v1"), WithProject("project"))
+	if _, err := withoutKey.SignInWithToken("user-1", "123456"); err == nil {
+		t.Errorf("Expected a sign-in without API key to be rejected")
+	}
+	if _, err := withoutKey.SignIn("user@example.com", "password"); err == nil {
+		t.Errorf("Expected a sign-in without API key to be rejected")
+	}
+}
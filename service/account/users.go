@@ -0,0 +1,183 @@
+package account
+
+import (
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/antidote-recognize0663/comics-galore-library/utils"
+	"github.com/appwrite/sdk-for-go/appwrite"
+	"github.com/appwrite/sdk-for-go/client"
+	"github.com/appwrite/sdk-for-go/databases"
+	"github.com/appwrite/sdk-for-go/models"
+	"github.com/appwrite/sdk-for-go/query"
+	"github.com/appwrite/sdk-for-go/users"
+)
+
+// overviewLimit is the number of payments and uploads shown in a UserOverview.
+const overviewLimit = 25
+
+// ListUsers lists the users matching the filter, newest first. A nil filter lists all
+// users.
+func (s *admin) ListUsers(filter *model.UserFilter) (*model.UserList, error) {
+	if filter == nil {
+		filter = &model.UserFilter{}
+	}
+	queries, err := filter.Queries()
+	if err != nil {
+		return nil, err
+	}
+	u := s.users()
+	options := []users.ListOption{u.WithListQueries(queries)}
+	if filter.Search != "" {
+		options = append(options, u.WithListSearch(filter.Search))
+	}
+	found, err := u.List(options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", model.TranslateError(err))
+	}
+	list := model.UserList{Users: []model.Account{}}
+	if len(found.Users) > 0 {
+		if err := found.Decode(&list); err != nil {
+			return nil, fmt.Errorf("failed to decode users: %w", err)
+		}
+	}
+	list.Total = found.Total
+	if len(list.Users) == filter.PageSize() {
+		list.NextCursor = list.Users[len(list.Users)-1].Id
+	}
+	return &list, nil
+}
+
+// BlockUser blocks a user. Blocked users cannot sign in, and Appwrite rejects the
+// requests of their existing sessions.
+func (s *admin) BlockUser(userId string) (*model.Account, error) {
+	return s.updateStatus(userId, false)
+}
+
+func (s *admin) UnblockUser(userId string) (*model.Account, error) {
+	return s.updateStatus(userId, true)
+}
+
+func (s *admin) updateStatus(userId string, active bool) (*model.Account, error) {
+	if userId == "" {
+		return nil, fmt.Errorf("userId cannot be empty")
+	}
+	user, err := s.users().UpdateStatus(userId, active)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update status of user %s: %w", userId, model.TranslateError(err))
+	}
+	return model.NewAccount(user), nil
+}
+
+// VerifyEmail marks the user's email as verified without a verification link.
+func (s *admin) VerifyEmail(userId string) (*model.Account, error) {
+	if userId == "" {
+		return nil, fmt.Errorf("userId cannot be empty")
+	}
+	user, err := s.users().UpdateEmailVerification(userId, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify email of user %s: %w", userId, model.TranslateError(err))
+	}
+	return model.NewAccount(user), nil
+}
+
+// UpdateEmail changes the user's email. Appwrite marks the new email as unverified.
+func (s *admin) UpdateEmail(userId, email string) (*model.Account, error) {
+	if userId == "" {
+		return nil, fmt.Errorf("userId cannot be empty")
+	}
+	if email == "" {
+		return nil, fmt.Errorf("email cannot be empty")
+	}
+	user, err := s.users().UpdateEmail(userId, email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update email of user %s: %w", userId, model.TranslateError(err))
+	}
+	return model.NewAccount(user), nil
+}
+
+func (s *admin) UpdateName(userId, name string) (*model.Account, error) {
+	if userId == "" {
+		return nil, fmt.Errorf("userId cannot be empty")
+	}
+	if name == "" {
+		return nil, fmt.Errorf("name cannot be empty")
+	}
+	user, err := s.users().UpdateName(userId, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update name of user %s: %w", userId, model.TranslateError(err))
+	}
+	return model.NewAccount(user), nil
+}
+
+// DeleteUser deletes the account. The user's payments and uploads are kept.
+func (s *admin) DeleteUser(userId string) error {
+	if userId == "" {
+		return fmt.Errorf("userId cannot be empty")
+	}
+	if _, err := s.users().Delete(userId); err != nil {
+		return fmt.Errorf("failed to delete user %s: %w", userId, model.TranslateError(err))
+	}
+	return nil
+}
+
+// GetUserOverview returns the user together with the latest payments, including gifts
+// the user bought, and the latest uploads.
+func (s *admin) GetUserOverview(userId string) (*model.UserOverview, error) {
+	account, err := s.GetUser(userId)
+	if err != nil {
+		return nil, err
+	}
+	database := appwrite.NewDatabases(s.client())
+	payments, err := listDocuments(database, s.paymentsDatabaseID, s.paymentsCollectionID, []string{
+		query.Limit(overviewLimit),
+		query.Or([]string{
+			query.Equal("user_id", userId),
+			query.Equal("purchaser_id", userId),
+		}),
+		query.OrderDesc("$createdAt"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list payments of user %s: %w", userId, err)
+	}
+	paymentList := model.PaymentList{DocumentList: payments, Payments: []model.Payment{}}
+	if len(payments.Documents) > 0 {
+		if err := payments.Decode(&paymentList); err != nil {
+			return nil, fmt.Errorf("failed to decode payments of user %s: %w", userId, err)
+		}
+	}
+	uploads, err := listDocuments(database, s.databaseID, s.postsCollectionID, []string{
+		query.Limit(overviewLimit),
+		query.Equal("uploader_id", userId),
+		query.OrderDesc("$createdAt"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list uploads of user %s: %w", userId, err)
+	}
+	postList := model.PostList{DocumentList: uploads, Posts: []model.Post{}}
+	if len(uploads.Documents) > 0 {
+		if err := uploads.Decode(&postList); err != nil {
+			return nil, fmt.Errorf("failed to decode uploads of user %s: %w", userId, err)
+		}
+	}
+	return &model.UserOverview{
+		Account:  account,
+		Payments: &paymentList,
+		Uploads:  &postList,
+	}, nil
+}
+
+func listDocuments(database *databases.Databases, databaseID, collectionID string, queries []string) (*models.DocumentList, error) {
+	documents, err := database.ListDocuments(databaseID, collectionID, database.WithListDocumentsQueries(queries))
+	if err != nil {
+		return nil, model.TranslateError(err)
+	}
+	return documents, nil
+}
+
+func (s *admin) client() client.Client {
+	return *utils.NewAdminClient(s.apiKey, utils.WithProject(s.projectID), utils.WithEndpoint(s.endpoint))
+}
+
+func (s *admin) users() *users.Users {
+	return appwrite.NewUsers(s.client())
+}
@@ -0,0 +1,115 @@
+package account
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListUsers(t *testing.T) {
+	var queries []string
+	var search string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/users" || r.Header.Get("X-Appwrite-Key") != "api-key" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"not found","code":404}`))
+			return
+		}
+		queries = r.URL.Query()["queries[]"]
+		search = r.URL.Query().Get("search")
+		_, _ = w.Write([]byte(`{"total":3,"users":[
+			{"$id":"user-3","name":"Spammer","email":"spam@example.com","status":false,"labels":["uploader"],"prefs":{"avatar_id":"avatar-3"}},
+			{"$id":"user-2","name":"Reader","email":"reader@example.com","status":false,"labels":[],"prefs":{}}]}`))
+	}))
+	defer server.Close()
+	a := NewAdmin(WithEndpoint(server.URL+"/v1"), WithProject("project"), WithApiKey("api-key"))
+
+	verified := false
+	list, err := a.ListUsers(&model.UserFilter{
+		Search:          "example.com",
+		Status:          model.UserStatusBlocked,
+		Label:           "uploader",
+		EmailVerified:   &verified,
+		RegisteredAfter: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Limit:           2,
+		Cursor:          "user-4",
+	})
+	if err != nil {
+		t.Fatalf("ListUsers failed: %v", err)
+	}
+	if list.Total != 3 || len(list.Users) != 2 || list.Users[0].Id != "user-3" || list.Users[0].Prefs.AvatarID != "avatar-3" || list.NextCursor != "user-2" {
+		t.Errorf("Unexpected user list: %+v", list)
+	}
+	joined := strings.Join(queries, "\n")
+	for _, expected := range []string{`"method":"limit","values":[2]`, `"attribute":"status","values":[false]`, `"method":"contains","attribute":"labels","values":["uploader"]`,
+		`"attribute":"emailVerification","values":[false]`, `"attribute":"registration","values":["2025-01-01T00:00:00Z"]`, `"method":"cursorAfter","values":["user-4"]`} {
+		if !strings.Contains(joined, expected) {
+			t.Errorf("Expected a query containing %s, got %v", expected, queries)
+		}
+	}
+	if search != "example.com" {
+		t.Errorf("Expected the search to be passed, got %q", search)
+	}
+
+	if _, err := a.ListUsers(&model.UserFilter{Status: "deleted"}); !errors.Is(err, model.ErrValidation) {
+		t.Errorf("Expected an unknown status to be rejected, got %v", err)
+	}
+}
+
+func TestGetUserOverview(t *testing.T) {
+	var blocked bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/users/user-1":
+			_, _ = w.Write([]byte(`{"$id":"user-1","name":"Reader","labels":["subscriber"],"status":true,"prefs":{}}`))
+		case r.Method == http.MethodPatch && r.URL.Path == "/v1/users/user-1/status":
+			var body map[string]bool
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			blocked = !body["status"]
+			_, _ = w.Write([]byte(`{"$id":"user-1","name":"Reader","status":false,"prefs":{}}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/databases/payments-db/collections/payments/documents":
+			_, _ = w.Write([]byte(`{"total":1,"documents":[{"$id":"payment-1","order_id":"1-abc","user_id":"user-1","payment_status":"finished"}]}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/databases/db/collections/posts/documents":
+			if !strings.Contains(strings.Join(r.URL.Query()["queries[]"], ""), `"attribute":"uploader_id","values":["user-1"]`) {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"message":"missing uploader filter","code":400}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"total":0,"documents":[]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"User with the requested ID could not be found.","code":404,"type":"user_not_found"}`))
+		}
+	}))
+	defer server.Close()
+	a := NewAdmin(WithEndpoint(server.URL+"/v1"), WithProject("project"), WithApiKey("api-key"),
+		WithDatabaseID("db"), WithPaymentsDatabaseID("payments-db"), WithPaymentsCollectionID("payments"), WithPostsCollectionID("posts"))
+
+	overview, err := a.GetUserOverview("user-1")
+	if err != nil {
+		t.Fatalf("GetUserOverview failed: %v", err)
+	}
+	if overview.Account.Id != "user-1" || len(overview.Account.Labels) != 1 || len(overview.Payments.Payments) != 1 ||
+		overview.Payments.Payments[0].OrderID != "1-abc" || len(overview.Uploads.Posts) != 0 {
+		t.Errorf("Unexpected overview: %+v", overview)
+	}
+
+	account, err := a.BlockUser("user-1")
+	if err != nil {
+		t.Fatalf("BlockUser failed: %v", err)
+	}
+	if !blocked || account.Status {
+		t.Errorf("Expected the user to be blocked, got %+v", account.User)
+	}
+
+	if _, err := a.GetUserOverview("user-404"); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unknown user, got %v", err)
+	}
+}
@@ -0,0 +1,263 @@
+package btcpay
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/config"
+	"github.com/antidote-recognize0663/comics-galore-library/internal/providerapi"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/antidote-recognize0663/comics-galore-library/service/payment"
+	"log"
+	"net/http"
+	"resty.dev/v3"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SignatureHeader is the header BTCPay Server delivers the webhook signature in.
+	SignatureHeader = "BTCPay-Sig"
+	// WebhookPath is the path the webhook handler is usually mounted at.
+	WebhookPath = "/btcpay/webhook"
+)
+
+// BTCPay is a BTCPay Server Greenfield API client; its store webhook points at WebhookPath.
+type BTCPay interface {
+	payment.PaymentProvider
+	CreateInvoice(ctx context.Context, request *model.BTCPayInvoiceRequest) (*model.BTCPayInvoice, error)
+	GetInvoice(ctx context.Context, invoiceID string) (*model.BTCPayInvoice, error)
+	Close() error
+}
+
+type btcPay struct {
+	client        *resty.Client
+	storeID       string
+	webhookSecret string
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func NewBTCPayWithConfig(cfg *config.Config, opts ...Option) BTCPay {
+	options := []Option{
+		WithApiKey(cfg.BTCPay.ApiKey),
+		WithEndpoint(cfg.BTCPay.Endpoint),
+		WithStoreID(cfg.BTCPay.StoreID),
+		WithWebhookSecret(cfg.BTCPay.WebhookSecret),
+	}
+	return NewBTCPay(append(options, opts...)...)
+}
+
+// NewBTCPay creates a client with a 15 second timeout and 3 retries of GET requests.
+func NewBTCPay(opts ...Option) BTCPay {
+	cfg := &Config{
+		timeout:          15 * time.Second,
+		retryCount:       3,
+		retryWaitTime:    500 * time.Millisecond,
+		retryMaxWaitTime: 5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	var client *resty.Client
+	if cfg.httpClient != nil {
+		client = resty.NewWithClient(cfg.httpClient)
+	} else {
+		client = resty.New()
+	}
+	client.
+		SetBaseURL(strings.TrimSuffix(cfg.endpoint, "/")+"/api/v1").
+		SetTimeout(cfg.timeout).
+		SetRetryCount(cfg.retryCount).
+		SetRetryWaitTime(cfg.retryWaitTime).
+		SetRetryMaxWaitTime(cfg.retryMaxWaitTime).
+		SetHeader("Authorization", "token "+cfg.apiKey).
+		SetHeader("Accept", "application/json")
+	return &btcPay{
+		client:        client,
+		storeID:       cfg.storeID,
+		webhookSecret: cfg.webhookSecret,
+	}
+}
+
+// Close releases the HTTP client. It is safe to call more than once.
+func (b *btcPay) Close() error {
+	b.closeOnce.Do(func() {
+		b.closeErr = b.client.Close()
+	})
+	return b.closeErr
+}
+
+func (b *btcPay) Name() string {
+	return model.ProviderBTCPay
+}
+
+func (b *btcPay) WebhookPath() string {
+	return WebhookPath
+}
+
+// CreateInvoice creates an invoice in the configured store; it is never retried.
+func (b *btcPay) CreateInvoice(ctx context.Context, request *model.BTCPayInvoiceRequest) (*model.BTCPayInvoice, error) {
+	resp, err := b.client.R().
+		SetContext(ctx).
+		SetPathParam("storeID", b.storeID).
+		SetHeader("Content-Type", "application/json").
+		SetBody(request).
+		SetResult(&model.BTCPayInvoice{}).
+		SetError(&model.BTCPayError{}).
+		Post("/stores/{storeID}/invoices")
+	if err := providerapi.CheckResponse("CreateInvoice", resp, err, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return resp.Result().(*model.BTCPayInvoice), nil
+}
+
+func (b *btcPay) GetInvoice(ctx context.Context, invoiceID string) (*model.BTCPayInvoice, error) {
+	if invoiceID == "" {
+		return nil, fmt.Errorf("invoiceID cannot be empty")
+	}
+	resp, err := b.client.R().
+		SetContext(ctx).
+		SetPathParam("storeID", b.storeID).
+		SetPathParam("invoiceID", invoiceID).
+		SetResult(&model.BTCPayInvoice{}).
+		SetError(&model.BTCPayError{}).
+		Get("/stores/{storeID}/invoices/{invoiceID}")
+	if err := providerapi.CheckResponse("GetInvoice", resp, err, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return resp.Result().(*model.BTCPayInvoice), nil
+}
+
+// CreateCheckout creates an invoice the buyer pays on its checkout link.
+func (b *btcPay) CreateCheckout(ctx context.Context, request *model.CheckoutRequest) (*model.Checkout, error) {
+	if request == nil {
+		return nil, fmt.Errorf("a checkout request is required")
+	}
+	invoice, err := b.CreateInvoice(ctx, model.NewBTCPayInvoiceRequest(request))
+	if err != nil {
+		return nil, err
+	}
+	update := invoice.ToPaymentUpdate()
+	return &model.Checkout{
+		Provider:      model.ProviderBTCPay,
+		OrderID:       request.OrderID,
+		PaymentID:     invoice.ID,
+		InvoiceID:     invoice.ID,
+		Status:        update.Status,
+		Description:   request.Description,
+		PriceAmount:   update.PriceAmount,
+		PriceCurrency: update.PriceCurrency,
+		PayCurrency:   strings.ToLower(request.PayCurrency),
+		CheckoutURL:   invoice.CheckoutLink,
+	}, nil
+}
+
+func (b *btcPay) GetStatus(ctx context.Context, paymentID string) (*model.PaymentUpdate, error) {
+	invoice, err := b.GetInvoice(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	return invoice.ToPaymentUpdate(), nil
+}
+
+// VerifyWebhook checks the BTCPay-Sig header and fetches the invoice of invoice events.
+func (b *btcPay) VerifyWebhook(r *http.Request, body []byte) (*model.PaymentUpdate, error) {
+	if b.webhookSecret == "" {
+		return nil, fmt.Errorf("no webhook secret configured")
+	}
+	signature := r.Header.Get(SignatureHeader)
+	if signature == "" {
+		return nil, fmt.Errorf("%w: missing %s header", payment.ErrWebhookSignature, SignatureHeader)
+	}
+	if !hmac.Equal([]byte(signature), []byte(model.SignBTCPayPayload(body, b.webhookSecret))) {
+		return nil, fmt.Errorf("%w: HMAC signature does not match", payment.ErrWebhookSignature)
+	}
+	var event model.BTCPayWebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %w", err)
+	}
+	if !strings.HasPrefix(event.Type, "Invoice") || event.InvoiceID == "" {
+		return nil, nil
+	}
+	if event.StoreID != "" && event.StoreID != b.storeID {
+		log.Printf("BTCPay: ignoring %s event for store %s", event.Type, event.StoreID)
+		return nil, nil
+	}
+	invoice, err := b.GetInvoice(r.Context(), event.InvoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if invoice.Metadata.OrderID == "" {
+		log.Printf("BTCPay: ignoring %s event for invoice %s without order ID", event.Type, invoice.ID)
+		return nil, nil
+	}
+	update := invoice.ToPaymentUpdate()
+	update.Raw = body
+	return update, nil
+}
+
+func WithApiKey(apiKey string) Option {
+	return func(c *Config) {
+		c.apiKey = apiKey
+	}
+}
+
+// WithEndpoint sets the URL of the BTCPay Server instance, without the /api/v1 suffix.
+func WithEndpoint(endpoint string) Option {
+	return func(c *Config) {
+		c.endpoint = endpoint
+	}
+}
+
+func WithStoreID(storeID string) Option {
+	return func(c *Config) {
+		c.storeID = storeID
+	}
+}
+
+func WithWebhookSecret(webhookSecret string) Option {
+	return func(c *Config) {
+		c.webhookSecret = webhookSecret
+	}
+}
+
+// WithTimeout sets the timeout applied to every request, retries included.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetry configures the retries of GET requests; a count of 0 disables them.
+func WithRetry(count int, waitTime, maxWaitTime time.Duration) Option {
+	return func(c *Config) {
+		c.retryCount = count
+		c.retryWaitTime = waitTime
+		c.retryMaxWaitTime = maxWaitTime
+	}
+}
+
+// WithHTTPClient makes the client use the given client and its connection pool.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Config) {
+		c.httpClient = httpClient
+	}
+}
+
+type Config struct {
+	apiKey           string
+	endpoint         string
+	storeID          string
+	webhookSecret    string
+	timeout          time.Duration
+	retryCount       int
+	retryWaitTime    time.Duration
+	retryMaxWaitTime time.Duration
+	httpClient       *http.Client
+}
+
+type Option func(*Config)
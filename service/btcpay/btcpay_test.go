@@ -0,0 +1,157 @@
+package btcpay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/antidote-recognize0663/comics-galore-library/service/payment"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type recordingPaymentService struct {
+	payment.Payment
+	mu      sync.Mutex
+	updates []*model.PaymentUpdate
+}
+
+func (r *recordingPaymentService) ApplyUpdate(data *model.PaymentUpdate) (*model.Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.updates = append(r.updates, data)
+	return &model.Payment{PaymentData: &model.PaymentData{OrderID: data.OrderID, PaymentStatus: data.Status}}, nil
+}
+
+func TestCheckoutAndWebhook(t *testing.T) {
+	const (
+		apiKey        = "fake-api-key"
+		storeID       = "store-1"
+		webhookSecret = "fake-webhook-secret"
+	)
+	var mu sync.Mutex
+	invoices := map[string]*model.BTCPayInvoice{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/stores/{storeID}/invoices", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token "+apiKey || r.PathValue("storeID") != storeID {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var request model.BTCPayInvoiceRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		invoice := &model.BTCPayInvoice{
+			ID:           "inv-1",
+			StoreID:      storeID,
+			Amount:       request.Amount,
+			Currency:     request.Currency,
+			Status:       model.BTCPayStatusNew,
+			CheckoutLink: "https://btcpay.example.com/i/inv-1",
+			Metadata:     request.Metadata,
+		}
+		invoices[invoice.ID] = invoice
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(invoice)
+	})
+	mux.HandleFunc("GET /api/v1/stores/{storeID}/invoices/{invoiceID}", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		invoice, ok := invoices[r.PathValue("invoiceID")]
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"code":"invoice-not-found","message":"The invoice was not found"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(invoice)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	provider := NewBTCPay(WithEndpoint(server.URL), WithApiKey(apiKey), WithStoreID(storeID), WithWebhookSecret(webhookSecret), WithRetry(0, 0, 0))
+	defer func() { _ = provider.Close() }()
+	ctx := context.Background()
+
+	checkout, err := provider.CreateCheckout(ctx, &model.CheckoutRequest{
+		OrderID:       "1-abc",
+		PlanID:        "1",
+		Description:   "Monthly Plan",
+		PriceAmount:   9,
+		PriceCurrency: "eur",
+		PayCurrency:   "btc",
+	})
+	if err != nil {
+		t.Fatalf("CreateCheckout failed: %v", err)
+	}
+	if checkout.PaymentID != "inv-1" || checkout.Status != model.StatusWaiting || checkout.PriceAmount != 9 || checkout.PriceCurrency != "eur" || checkout.CheckoutURL == "" {
+		t.Errorf("Unexpected checkout: %+v", checkout)
+	}
+	if _, err := provider.GetStatus(ctx, "missing"); !IsStatus(err, http.StatusNotFound) {
+		t.Errorf("Expected a 404 APIError for an unknown invoice, got: %v", err)
+	}
+
+	paymentService := &recordingPaymentService{}
+	webhook := httptest.NewServer(payment.NewWebhookHandler(provider, paymentService))
+	defer webhook.Close()
+	deliver := func(event model.BTCPayWebhookEvent, secret string) int {
+		body, _ := json.Marshal(event)
+		req, _ := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+		req.Header.Set(SignatureHeader, model.SignBTCPayPayload(body, secret))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Webhook delivery failed: %v", err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	mu.Lock()
+	invoices["inv-1"].Status = model.BTCPayStatusSettled
+	mu.Unlock()
+	settled := model.BTCPayWebhookEvent{DeliveryID: "d-1", Type: "InvoiceSettled", StoreID: storeID, InvoiceID: "inv-1"}
+	if status := deliver(settled, "wrong-secret"); status != http.StatusUnauthorized {
+		t.Errorf("Expected a forged delivery to be rejected with 401, got %d", status)
+	}
+	if status := deliver(settled, webhookSecret); status != http.StatusOK {
+		t.Errorf("Expected the settled delivery to be accepted, got %d", status)
+	}
+	if status := deliver(model.BTCPayWebhookEvent{DeliveryID: "d-2", Type: "PayoutCreated", StoreID: storeID}, webhookSecret); status != http.StatusOK {
+		t.Errorf("Expected a non-invoice event to be acknowledged, got %d", status)
+	}
+	if len(paymentService.updates) != 1 {
+		t.Fatalf("Expected exactly one applied update, got %d", len(paymentService.updates))
+	}
+	update := paymentService.updates[0]
+	if update.Provider != model.ProviderBTCPay || update.OrderID != "1-abc" || update.Status != model.StatusFinished {
+		t.Errorf("Unexpected update: %+v", update)
+	}
+}
+
+func TestInvoiceStatusMapping(t *testing.T) {
+	testCases := []struct {
+		status           string
+		additionalStatus string
+		expected         model.PaymentStatusEnum
+	}{
+		{model.BTCPayStatusNew, "None", model.StatusWaiting},
+		{model.BTCPayStatusNew, model.BTCPayAdditionalStatusPaidPartial, model.StatusPartiallyPaid},
+		{model.BTCPayStatusProcessing, "None", model.StatusConfirming},
+		{model.BTCPayStatusSettled, "PaidOver", model.StatusFinished},
+		{model.BTCPayStatusExpired, "PaidLate", model.StatusExpired},
+		{model.BTCPayStatusInvalid, "Marked", model.StatusFailed},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.status+"/"+tc.additionalStatus, func(t *testing.T) {
+			invoice := &model.BTCPayInvoice{Status: tc.status, AdditionalStatus: tc.additionalStatus}
+			if got := invoice.PaymentStatus(); got != tc.expected {
+				t.Errorf("PaymentStatus = %s; want %s", got, tc.expected)
+			}
+		})
+	}
+}
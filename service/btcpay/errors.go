@@ -0,0 +1,13 @@
+package btcpay
+
+import (
+	"github.com/antidote-recognize0663/comics-galore-library/internal/providerapi"
+)
+
+// APIError is returned when BTCPay Server answers with an unexpected HTTP status.
+type APIError = providerapi.APIError
+
+// IsStatus reports whether err is an *APIError with the given HTTP status code.
+func IsStatus(err error, statusCode int) bool {
+	return providerapi.IsStatus(err, statusCode)
+}
@@ -0,0 +1,271 @@
+package coupon
+
+import (
+	"errors"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/config"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/antidote-recognize0663/comics-galore-library/utils"
+	"github.com/appwrite/sdk-for-go/appwrite"
+	"github.com/appwrite/sdk-for-go/client"
+	"github.com/appwrite/sdk-for-go/databases"
+	"github.com/appwrite/sdk-for-go/query"
+	"net/http"
+	"time"
+)
+
+// redeemingStatuses are the payment statuses that count as a redemption of the coupon
+// recorded on the payment. Failed, expired and refunded payments give the use back.
+var redeemingStatuses = []interface{}{
+	string(model.StatusWaiting),
+	string(model.StatusConfirming),
+	string(model.StatusConfirmed),
+	string(model.StatusSending),
+	string(model.StatusPartiallyPaid),
+	string(model.StatusFinished),
+}
+
+type Coupon interface {
+	Create(data *model.CouponData) (*model.Coupon, error)
+	GetByCode(code string) (*model.Coupon, error)
+	Validate(code, userID, planID string) (*model.Coupon, error)
+	Redeem(code, userID, planID string) (*model.Coupon, error)
+	Release(code string) error
+	Disable(code string) error
+}
+
+type coupon struct {
+	database             *databases.Databases
+	databaseID           string
+	collectionID         string
+	paymentsCollectionID string
+}
+
+// Create stores a new coupon under its normalized code.
+func (c *coupon) Create(data *model.CouponData) (*model.Coupon, error) {
+	if data == nil {
+		return nil, fmt.Errorf("data is required to create a coupon")
+	}
+	if err := c.checkCollection(); err != nil {
+		return nil, err
+	}
+	code, err := model.NormalizeCouponCode(data.Code)
+	if err != nil {
+		return nil, err
+	}
+	if err := data.Validate(); err != nil {
+		return nil, err
+	}
+	data.Code = code
+	data.Redemptions = 0
+	document, err := c.database.CreateDocument(c.databaseID, c.collectionID, code, data)
+	if err != nil {
+		return nil, fmt.Errorf("could not create coupon %s: %w", code, model.TranslateError(err))
+	}
+	return model.NewCoupon(document)
+}
+
+// GetByCode looks a coupon up by the code a user entered. Unknown codes return
+// model.ErrCouponNotFound.
+func (c *coupon) GetByCode(code string) (*model.Coupon, error) {
+	if err := c.checkCollection(); err != nil {
+		return nil, err
+	}
+	normalized, err := model.NormalizeCouponCode(code)
+	if err != nil {
+		return nil, err
+	}
+	document, err := c.database.GetDocument(c.databaseID, c.collectionID, normalized)
+	if err != nil {
+		err = model.TranslateError(err)
+		var serviceErr *model.ServiceError
+		if errors.As(err, &serviceErr) && serviceErr.Code == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: %s", model.ErrCouponNotFound, normalized)
+		}
+		return nil, fmt.Errorf("could not get coupon %s: %w", normalized, err)
+	}
+	return model.NewCoupon(document)
+}
+
+// Validate checks that the user may use the coupon for the plan: the coupon must be
+// enabled, within its validity window and eligible for the plan, its global and
+// per-user redemption limits must not be reached, and a first-purchase coupon is only
+// accepted from users without a finished payment. It does not redeem the coupon.
+func (c *coupon) Validate(code, userID, planID string) (*model.Coupon, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("userID cannot be empty")
+	}
+	found, err := c.GetByCode(code)
+	if err != nil {
+		return nil, err
+	}
+	if err := found.CheckPlan(planID, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	if found.MaxRedemptions > 0 && found.Redemptions >= found.MaxRedemptions {
+		return nil, model.ErrCouponExhausted
+	}
+	if found.MaxRedemptionsPerUser > 0 {
+		used, err := c.countPayments(
+			query.Equal("user_id", userID),
+			query.Equal("coupon_code", found.Code),
+			query.Equal("payment_status", redeemingStatuses),
+		)
+		if err != nil {
+			return nil, err
+		}
+		if used >= found.MaxRedemptionsPerUser {
+			return nil, model.ErrCouponUserLimit
+		}
+	}
+	if found.FirstPurchaseOnly {
+		purchases, err := c.countPayments(
+			query.Equal("user_id", userID),
+			query.Equal("payment_status", string(model.StatusFinished)),
+		)
+		if err != nil {
+			return nil, err
+		}
+		if purchases > 0 {
+			return nil, model.ErrCouponFirstPurchase
+		}
+	}
+	return found, nil
+}
+
+// Redeem validates the coupon and counts one redemption against its global limit.
+// nowpayments.WithCoupon redeems coupons right before the payment is created and gives
+// them back with Release when it cannot be. The payment entry records the coupon code,
+// which is what the per-user limit counts; a payment service created with
+// payment.WithCoupons releases the redemption once the payment fails, expires or is
+// refunded.
+func (c *coupon) Redeem(code, userID, planID string) (*model.Coupon, error) {
+	found, err := c.Validate(code, userID, planID)
+	if err != nil {
+		return nil, err
+	}
+	setters := []databases.IncrementDocumentAttributeOption{c.database.WithIncrementDocumentAttributeValue(1)}
+	if found.MaxRedemptions > 0 {
+		setters = append(setters, c.database.WithIncrementDocumentAttributeMax(float64(found.MaxRedemptions)))
+	}
+	document, err := c.database.IncrementDocumentAttribute(c.databaseID, c.collectionID, found.Code, "redemptions", setters...)
+	if err != nil {
+		// The maximum is enforced by Appwrite as well, so a concurrent redemption
+		// that took the last use is reported as exhausted.
+		err = model.TranslateError(err)
+		var serviceErr *model.ServiceError
+		if found.MaxRedemptions > 0 && errors.As(err, &serviceErr) && serviceErr.Code == http.StatusBadRequest {
+			return nil, model.ErrCouponExhausted
+		}
+		return nil, fmt.Errorf("could not redeem coupon %s: %w", found.Code, err)
+	}
+	return model.NewCoupon(document)
+}
+
+// Release gives back a redemption counted by Redeem.
+func (c *coupon) Release(code string) error {
+	if err := c.checkCollection(); err != nil {
+		return err
+	}
+	normalized, err := model.NormalizeCouponCode(code)
+	if err != nil {
+		return err
+	}
+	_, err = c.database.DecrementDocumentAttribute(c.databaseID, c.collectionID, normalized, "redemptions",
+		c.database.WithDecrementDocumentAttributeValue(1),
+		c.database.WithDecrementDocumentAttributeMin(0))
+	if err != nil {
+		return fmt.Errorf("could not release coupon %s: %w", normalized, model.TranslateError(err))
+	}
+	return nil
+}
+
+func (c *coupon) Disable(code string) error {
+	if err := c.checkCollection(); err != nil {
+		return err
+	}
+	normalized, err := model.NormalizeCouponCode(code)
+	if err != nil {
+		return err
+	}
+	_, err = c.database.UpdateDocument(c.databaseID, c.collectionID, normalized,
+		c.database.WithUpdateDocumentData(map[string]interface{}{"disabled": true}))
+	if err != nil {
+		return fmt.Errorf("could not disable coupon %s: %w", normalized, model.TranslateError(err))
+	}
+	return nil
+}
+
+func (c *coupon) checkCollection() error {
+	if c.collectionID == "" {
+		return fmt.Errorf("no coupons collection configured, set APPWRITE_COLLECTION_ID_COUPONS")
+	}
+	return nil
+}
+
+func (c *coupon) countPayments(queries ...string) (int64, error) {
+	queries = append(queries, query.Limit(1), query.Select([]string{"$id"}))
+	response, err := c.database.ListDocuments(c.databaseID, c.paymentsCollectionID, c.database.WithListDocumentsQueries(queries))
+	if err != nil {
+		return 0, fmt.Errorf("could not list payments: %w", model.TranslateError(err))
+	}
+	return int64(response.Total), nil
+}
+
+type Config struct {
+	database             *databases.Databases
+	databaseID           string
+	collectionID         string
+	paymentsCollectionID string
+}
+
+type Option func(config *Config)
+
+func WithDatabaseID(databaseID string) Option {
+	return func(c *Config) {
+		c.databaseID = databaseID
+	}
+}
+
+func WithCollectionID(collectionID string) Option {
+	return func(c *Config) {
+		c.collectionID = collectionID
+	}
+}
+
+// WithPaymentsCollectionID sets the collection the redemption history is read from.
+func WithPaymentsCollectionID(collectionID string) Option {
+	return func(c *Config) {
+		c.paymentsCollectionID = collectionID
+	}
+}
+
+func NewCouponWithConfig(config *config.Config) Coupon {
+	adminClient := utils.NewAdminClient(config.Appwrite.ApiKey, utils.WithEndpoint(config.Appwrite.Endpoint), utils.WithProject(config.Appwrite.ProjectID))
+	return &coupon{
+		database:             appwrite.NewDatabases(*adminClient),
+		databaseID:           config.Appwrite.DatabaseID,
+		collectionID:         config.Appwrite.CollectionIDCoupons,
+		paymentsCollectionID: config.Appwrite.CollectionIDPayments,
+	}
+}
+
+// NewCoupon creates the coupon service. The coupons collection is read from
+// APPWRITE_COLLECTION_ID_COUPONS unless it is set with WithCollectionID.
+func NewCoupon(client *client.Client, options ...Option) Coupon {
+	cfg := &Config{
+		database:             appwrite.NewDatabases(*client),
+		databaseID:           "6510add9771bcf260b40",
+		collectionID:         config.NewAppwriteConfig().CollectionIDCoupons,
+		paymentsCollectionID: "67806dd1003557f3794e",
+	}
+	for _, option := range options {
+		option(cfg)
+	}
+	return &coupon{
+		database:             cfg.database,
+		databaseID:           cfg.databaseID,
+		collectionID:         cfg.collectionID,
+		paymentsCollectionID: cfg.paymentsCollectionID,
+	}
+}
@@ -0,0 +1,126 @@
+package coupon
+
+import (
+	"errors"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/internal/appwritetest"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"testing"
+)
+
+func newTestCoupon(server *appwritetest.Server) Coupon {
+	return NewCoupon(server.Client(), WithDatabaseID("db"), WithCollectionID("coupons"), WithPaymentsCollectionID("payments"))
+}
+
+func TestRedeem(t *testing.T) {
+	testCases := []struct {
+		name              string
+		coupon            map[string]interface{}
+		payments          []map[string]interface{}
+		expectErr         error
+		expectRedemptions float64
+	}{
+		{
+			name:              "Redeemed",
+			coupon:            map[string]interface{}{"max_redemptions": 2, "redemptions": 1},
+			expectRedemptions: 2,
+		},
+		{
+			name:              "Exhausted",
+			coupon:            map[string]interface{}{"max_redemptions": 2, "redemptions": 2},
+			expectErr:         model.ErrCouponExhausted,
+			expectRedemptions: 2,
+		},
+		{
+			name:   "User Limit",
+			coupon: map[string]interface{}{"max_redemptions_per_user": 1},
+			payments: []map[string]interface{}{
+				{"user_id": "user-1", "coupon_code": "SPRING20", "payment_status": "finished"},
+			},
+			expectErr: model.ErrCouponUserLimit,
+		},
+		{
+			name:   "Released Use Does Not Count",
+			coupon: map[string]interface{}{"max_redemptions_per_user": 1},
+			payments: []map[string]interface{}{
+				{"user_id": "user-1", "coupon_code": "SPRING20", "payment_status": "expired"},
+				{"user_id": "user-2", "coupon_code": "SPRING20", "payment_status": "finished"},
+			},
+			expectRedemptions: 1,
+		},
+		{
+			name:      "Unknown Code",
+			expectErr: model.ErrCouponNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := appwritetest.NewServer()
+			defer server.Close()
+			if tc.coupon != nil {
+				data := map[string]interface{}{"code": "SPRING20", "discount_type": "percentage", "value": 20, "redemptions": 0}
+				for key, value := range tc.coupon {
+					data[key] = value
+				}
+				server.Put("db", "coupons", "SPRING20", data)
+			}
+			for i, payment := range tc.payments {
+				server.Put("db", "payments", fmt.Sprintf("order-%d", i), payment)
+			}
+			service := newTestCoupon(server)
+
+			_, err := service.Redeem("spring20", "user-1", "1")
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("Expected error %v, got %v", tc.expectErr, err)
+			}
+			if tc.coupon == nil {
+				return
+			}
+			stored, _ := server.Document("db", "coupons", "SPRING20")
+			if redemptions, _ := stored["redemptions"].(float64); redemptions != tc.expectRedemptions {
+				t.Errorf("Expected %g redemptions, got %v", tc.expectRedemptions, stored["redemptions"])
+			}
+		})
+	}
+}
+
+func TestRelease(t *testing.T) {
+	server := appwritetest.NewServer()
+	defer server.Close()
+	server.Put("db", "coupons", "SPRING20", map[string]interface{}{
+		"code": "SPRING20", "discount_type": "percentage", "value": 20, "max_redemptions": 1, "redemptions": 0,
+	})
+	service := newTestCoupon(server)
+
+	if _, err := service.Redeem("SPRING20", "user-1", "1"); err != nil {
+		t.Fatalf("Redeem failed: %v", err)
+	}
+	if _, err := service.Redeem("SPRING20", "user-2", "1"); !errors.Is(err, model.ErrCouponExhausted) {
+		t.Fatalf("Expected the coupon to be exhausted, got %v", err)
+	}
+	if err := service.Release("spring20"); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if _, err := service.Redeem("SPRING20", "user-2", "1"); err != nil {
+		t.Errorf("Expected the released use to be redeemable, got %v", err)
+	}
+
+	// The count never drops below zero.
+	for i := 0; i < 3; i++ {
+		_ = service.Release("SPRING20")
+	}
+	stored, _ := server.Document("db", "coupons", "SPRING20")
+	if redemptions, _ := stored["redemptions"].(float64); redemptions != 0 {
+		t.Errorf("Expected 0 redemptions, got %v", stored["redemptions"])
+	}
+}
+
+func TestMissingCollection(t *testing.T) {
+	server := appwritetest.NewServer()
+	defer server.Close()
+	service := NewCoupon(server.Client(), WithDatabaseID("db"), WithCollectionID(""))
+
+	if _, err := service.GetByCode("SPRING20"); err == nil {
+		t.Errorf("Expected a coupon service without collection to fail")
+	}
+}
@@ -0,0 +1,255 @@
+package entitlement
+
+import (
+	"context"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/config"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/antidote-recognize0663/comics-galore-library/service/payment"
+	"github.com/antidote-recognize0663/comics-galore-library/service/user"
+	"github.com/antidote-recognize0663/comics-galore-library/utils"
+	"github.com/appwrite/sdk-for-go/appwrite"
+	"github.com/appwrite/sdk-for-go/client"
+	"github.com/appwrite/sdk-for-go/query"
+	"github.com/appwrite/sdk-for-go/users"
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Entitlement tells whether a user has an active subscription. The answer is computed
+// from the payments collection, so it does not depend on the subscriber label; Sync
+// brings the labels back in line with it.
+type Entitlement interface {
+	IsActive(userID string) (bool, error)
+	ActiveUntil(userID string) (time.Time, error)
+	Invalidate(userID string)
+	Sync(ctx context.Context, opts ...SyncOption) (*SyncReport, error)
+}
+
+// ExpiryLookup returns the end of a user's active subscription, or the zero time when
+// there is none. payment.Payment satisfies it.
+type ExpiryLookup interface {
+	GetActiveExpiry(userID string) (time.Time, error)
+}
+
+type entitlement struct {
+	payments    ExpiryLookup
+	userService user.User
+	users       *users.Users
+	label       string
+	ttl         time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedExpiry
+}
+
+type cachedExpiry struct {
+	activeUntil time.Time
+	loadedAt    time.Time
+}
+
+func NewEntitlementWithConfig(cfg *config.Config, opts ...Option) Entitlement {
+	adminClient := utils.NewAdminClient(cfg.Appwrite.ApiKey, utils.WithProject(cfg.Appwrite.ProjectID), utils.WithEndpoint(cfg.Appwrite.Endpoint))
+	return NewEntitlement(adminClient, payment.NewPaymentWithConfig(cfg), opts...)
+}
+
+// NewEntitlement creates an entitlement service reading subscriptions through payments.
+// Answers are cached for one minute by default; call Invalidate when a payment of the
+// user changes to see it immediately, or pass the entitlement to payment.WithInvalidator
+// to have the payment service do so.
+func NewEntitlement(client *client.Client, payments ExpiryLookup, opts ...Option) Entitlement {
+	if client == nil {
+		panic("appwrite client is required")
+	}
+	if payments == nil {
+		panic("payments are required")
+	}
+	cfg := &Config{
+		userService: user.NewUser(client),
+		label:       "subscriber",
+		ttl:         time.Minute,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return &entitlement{
+		payments:    payments,
+		userService: cfg.userService,
+		users:       appwrite.NewUsers(*client),
+		label:       cfg.label,
+		ttl:         cfg.ttl,
+		cache:       make(map[string]cachedExpiry),
+	}
+}
+
+func (e *entitlement) IsActive(userID string) (bool, error) {
+	activeUntil, err := e.ActiveUntil(userID)
+	if err != nil {
+		return false, err
+	}
+	return activeUntil.After(time.Now()), nil
+}
+
+// ActiveUntil returns the end of the user's subscription, or the zero time when the
+// user has none.
+func (e *entitlement) ActiveUntil(userID string) (time.Time, error) {
+	if userID == "" {
+		return time.Time{}, fmt.Errorf("userID cannot be empty")
+	}
+	e.mu.Lock()
+	cached, ok := e.cache[userID]
+	e.mu.Unlock()
+	if ok && time.Since(cached.loadedAt) < e.ttl {
+		return cached.activeUntil, nil
+	}
+	return e.load(userID)
+}
+
+func (e *entitlement) Invalidate(userID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.cache, userID)
+}
+
+func (e *entitlement) load(userID string) (time.Time, error) {
+	activeUntil, err := e.payments.GetActiveExpiry(userID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not compute entitlement of user %s: %w", userID, err)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := time.Now()
+	for key, entry := range e.cache {
+		if now.Sub(entry.loadedAt) >= e.ttl {
+			delete(e.cache, key)
+		}
+	}
+	e.cache[userID] = cachedExpiry{activeUntil: activeUntil, loadedAt: now}
+	return activeUntil, nil
+}
+
+// SyncReport summarizes a Sync run.
+type SyncReport struct {
+	Checked int64 // users examined
+	Granted int64 // users the label was added to
+	Revoked int64 // users the label was removed from
+	Failed  int64 // users whose entitlement or label could not be updated
+}
+
+// Sync pages through all users and repairs the subscriber label: it is added to users
+// with an active subscription and removed from everybody else. Entitlements are
+// computed fresh and refresh the cache. With WithDryRun the report is produced without
+// changing any label.
+func (e *entitlement) Sync(ctx context.Context, opts ...SyncOption) (*SyncReport, error) {
+	options := &syncOptions{
+		limit: 100,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	report := &SyncReport{}
+	var cursor string
+	for {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		queries := []string{query.Limit(options.limit)}
+		if cursor != "" {
+			queries = append(queries, query.CursorAfter(cursor))
+		}
+		response, err := e.users.List(e.users.WithListQueries(queries))
+		if err != nil {
+			return report, fmt.Errorf("could not list users: %w", model.TranslateError(err))
+		}
+		if len(response.Users) == 0 {
+			break
+		}
+		for _, account := range response.Users {
+			report.Checked++
+			activeUntil, err := e.load(account.Id)
+			if err != nil {
+				log.Printf("Sync: %v", err)
+				report.Failed++
+				continue
+			}
+			active := activeUntil.After(time.Now())
+			labeled := slices.Contains(account.Labels, e.label)
+			switch {
+			case active && !labeled:
+				if !options.dryRun {
+					if _, err := e.userService.AddLabel(account.Id, e.label); err != nil {
+						log.Printf("Sync: Could not add label to user %s: %v", account.Id, err)
+						report.Failed++
+						continue
+					}
+				}
+				report.Granted++
+			case !active && labeled:
+				if !options.dryRun {
+					if _, err := e.userService.RemoveLabel(account.Id, e.label); err != nil {
+						log.Printf("Sync: Could not remove label from user %s: %v", account.Id, err)
+						report.Failed++
+						continue
+					}
+				}
+				report.Revoked++
+			}
+		}
+		cursor = response.Users[len(response.Users)-1].Id
+	}
+	return report, nil
+}
+
+type syncOptions struct {
+	limit  int
+	dryRun bool
+}
+
+type SyncOption func(*syncOptions)
+
+// WithSyncLimit sets the page size used when listing users.
+func WithSyncLimit(limit int) SyncOption {
+	return func(o *syncOptions) {
+		if limit > 0 {
+			o.limit = limit
+		}
+	}
+}
+
+// WithDryRun reports the labels Sync would change without changing them.
+func WithDryRun() SyncOption {
+	return func(o *syncOptions) {
+		o.dryRun = true
+	}
+}
+
+type Config struct {
+	userService user.User
+	label       string
+	ttl         time.Duration
+}
+
+type Option func(*Config)
+
+// WithUserService sets the service the labels are changed through.
+func WithUserService(userService user.User) Option {
+	return func(c *Config) {
+		c.userService = userService
+	}
+}
+
+// WithLabel sets the label that marks subscribers, "subscriber" by default.
+func WithLabel(label string) Option {
+	return func(c *Config) {
+		c.label = label
+	}
+}
+
+// WithCacheTTL sets how long an entitlement is cached. A TTL of 0 disables the cache.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(c *Config) {
+		c.ttl = ttl
+	}
+}
@@ -0,0 +1,49 @@
+package entitlement
+
+import (
+	"github.com/appwrite/sdk-for-go/client"
+	"testing"
+	"time"
+)
+
+type countingLookup struct {
+	calls       int
+	activeUntil time.Time
+}
+
+func (c *countingLookup) GetActiveExpiry(string) (time.Time, error) {
+	c.calls++
+	return c.activeUntil, nil
+}
+
+func TestEntitlementCache(t *testing.T) {
+	appwriteClient := client.New()
+	lookup := &countingLookup{activeUntil: time.Now().Add(24 * time.Hour)}
+	service := NewEntitlement(&appwriteClient, lookup, WithCacheTTL(time.Hour))
+
+	for i := 0; i < 3; i++ {
+		active, err := service.IsActive("user-1")
+		if err != nil || !active {
+			t.Fatalf("Expected user-1 to be active, got %v, %v", active, err)
+		}
+	}
+	if lookup.calls != 1 {
+		t.Errorf("Expected the entitlement to be cached, got %d lookups", lookup.calls)
+	}
+
+	lookup.activeUntil = time.Time{}
+	service.Invalidate("user-1")
+	if active, _ := service.IsActive("user-1"); active {
+		t.Errorf("Expected user-1 to be inactive after invalidation")
+	}
+
+	uncached := NewEntitlement(&appwriteClient, lookup, WithCacheTTL(0))
+	_, _ = uncached.ActiveUntil("user-2")
+	_, _ = uncached.ActiveUntil("user-2")
+	if lookup.calls != 4 {
+		t.Errorf("Expected every lookup to reach the payments with a zero TTL, got %d lookups", lookup.calls)
+	}
+	if _, err := service.ActiveUntil(""); err == nil {
+		t.Errorf("Expected an empty user ID to be rejected")
+	}
+}
@@ -46,7 +46,7 @@ func (h *heartbeat) GetActiveUsers(duration ...time.Duration) (*model.HeartbeatL
 	)
 	documents, err := h.database.ListDocuments(h.databaseID, h.collectionID, queries)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch documents: %v", err)
+		return nil, fmt.Errorf("failed to fetch documents: %w", model.TranslateError(err))
 	}
 	if len(documents.Documents) == 0 {
 		return &model.HeartbeatList{
@@ -56,7 +56,7 @@ func (h *heartbeat) GetActiveUsers(duration ...time.Duration) (*model.HeartbeatL
 	}
 	var heartbeatList model.HeartbeatList
 	if err := documents.Decode(&heartbeatList); err != nil {
-		return nil, fmt.Errorf("failed to decode documents: %v", err)
+		return nil, fmt.Errorf("failed to decode documents: %w", err)
 	}
 	return &heartbeatList, nil
 }
@@ -71,7 +71,7 @@ func (h *heartbeat) Upsert(userId, label string) (*model.Heartbeat, error) {
 	}
 	upsertDocumentList, err := h.database.UpsertDocuments(h.databaseID, h.collectionID, upsertData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to upsert document: %w", err)
+		return nil, fmt.Errorf("failed to upsert document: %w", model.TranslateError(err))
 	}
 	if len(upsertDocumentList.Documents) == 0 {
 		return nil, fmt.Errorf("upsert operation did not return any documents")
@@ -0,0 +1,39 @@
+package nowpayments
+
+import (
+	"context"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/internal/providerapi"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"net/http"
+	"time"
+)
+
+// tokenTTL is how long an auth token is reused; NowPayments expires them after 5 minutes.
+const tokenTTL = 4 * time.Minute
+
+// authToken returns the JWT for endpoints that need the account credentials, logging
+// in again once the cached token is about to expire.
+func (n *nowPayments) authToken(ctx context.Context) (string, error) {
+	if n.email == "" || n.password == "" {
+		return "", fmt.Errorf("no NowPayments credentials configured")
+	}
+	n.tokenMu.Lock()
+	defer n.tokenMu.Unlock()
+	if n.token != "" && time.Now().Before(n.tokenExpiry) {
+		return n.token, nil
+	}
+	resp, err := n.client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(map[string]string{"email": n.email, "password": n.password}).
+		SetResult(&model.NowPaymentsAuthResponse{}).
+		SetError(&model.NowPaymentsError{}).
+		Post("/auth")
+	if err := providerapi.CheckResponse("Authenticate", resp, err, http.StatusOK); err != nil {
+		return "", err
+	}
+	n.token = resp.Result().(*model.NowPaymentsAuthResponse).Token
+	n.tokenExpiry = time.Now().Add(tokenTTL)
+	return n.token, nil
+}
@@ -0,0 +1,152 @@
+package nowpayments
+
+import (
+	"context"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/config"
+	"github.com/antidote-recognize0663/comics-galore-library/internal/providerapi"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// currencyCatalog caches which coins can be used to pay and their minimum amounts, so
+// payment requests can be checked before they reach the API.
+type currencyCatalog struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	loadedAt   time.Time
+	currencies map[string]model.Currency
+	merchant   map[string]bool
+	minimums   map[string]cachedMinimum
+}
+
+type cachedMinimum struct {
+	minimum  *model.MinimumAmount
+	loadedAt time.Time
+}
+
+func newCurrencyCatalog(ttl time.Duration) *currencyCatalog {
+	return &currencyCatalog{
+		ttl:      ttl,
+		minimums: make(map[string]cachedMinimum),
+	}
+}
+
+func (n *nowPayments) GetMinimumAmount(ctx context.Context, currencyFrom, currencyTo string, fiatEquivalent string) (*model.MinimumAmount, error) {
+	params := map[string]string{
+		"currency_from": currencyFrom,
+		"currency_to":   currencyTo,
+	}
+	if fiatEquivalent != "" {
+		params["fiat_equivalent"] = fiatEquivalent
+	}
+	resp, err := n.client.R().
+		SetContext(ctx).
+		SetQueryParams(params).
+		SetResult(&model.MinimumAmount{}).
+		SetError(&model.NowPaymentsError{}).
+		Get("/min-amount")
+	if err := providerapi.CheckResponse("GetMinimumAmount", resp, err, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return resp.Result().(*model.MinimumAmount), nil
+}
+
+// ValidatePayment checks a payment request against the currency catalog: the plan at
+// priceIndex must exist, payCurrency must be enabled for the merchant and supported by
+// NowPayments, and the amount must not be below the minimum for that coin. When
+// payAmount is 0 the plan price is compared with the minimum's fiat equivalent.
+func (n *nowPayments) ValidatePayment(ctx context.Context, priceIndex int, payCurrency string, payAmount float64) error {
+	if priceIndex < 0 || priceIndex >= len(n.subscriptionPlans) {
+		return &ValidationError{Err: ErrUnknownPlan, Plan: strconv.Itoa(priceIndex)}
+	}
+	return n.validateCurrency(ctx, n.subscriptionPlans[priceIndex].GetPrice(), config.BaseCurrency, payCurrency, payAmount)
+}
+
+func (n *nowPayments) validateCurrency(ctx context.Context, priceAmount float64, priceCurrency string, payCurrency string, payAmount float64) error {
+	payCurrency = strings.ToLower(payCurrency)
+	if err := n.loadCatalog(ctx); err != nil {
+		return err
+	}
+	n.catalog.mu.Lock()
+	_, supported := n.catalog.currencies[payCurrency]
+	enabled := n.catalog.merchant[payCurrency]
+	n.catalog.mu.Unlock()
+	if !supported || !enabled {
+		return &ValidationError{Err: ErrUnsupportedCurrency, Currency: payCurrency}
+	}
+	minimum, err := n.minimumFor(ctx, payCurrency, priceCurrency)
+	if err != nil {
+		return err
+	}
+	if payAmount > 0 {
+		if payAmount < minimum.MinAmount {
+			return &ValidationError{Err: ErrAmountBelowMinimum, Currency: payCurrency, Amount: payAmount, Minimum: minimum.MinAmount}
+		}
+		return nil
+	}
+	if minimum.FiatEquivalent > 0 && priceAmount < minimum.FiatEquivalent {
+		return &ValidationError{Err: ErrAmountBelowMinimum, Currency: priceCurrency, Amount: priceAmount, Minimum: minimum.FiatEquivalent}
+	}
+	return nil
+}
+
+func (n *nowPayments) loadCatalog(ctx context.Context) error {
+	n.catalog.mu.Lock()
+	fresh := n.catalog.currencies != nil && time.Since(n.catalog.loadedAt) < n.catalog.ttl
+	n.catalog.mu.Unlock()
+	if fresh {
+		return nil
+	}
+	currencies, err := n.GetAvailableCurrencies(ctx)
+	if err != nil {
+		return fmt.Errorf("could not load currency catalog: %w", err)
+	}
+	coins, err := n.GetMerchantCoins(ctx)
+	if err != nil {
+		return fmt.Errorf("could not load currency catalog: %w", err)
+	}
+	byCode := make(map[string]model.Currency, len(currencies.Currencies))
+	for _, currency := range currencies.Currencies {
+		byCode[strings.ToLower(currency.Currency)] = currency
+	}
+	merchant := make(map[string]bool, len(coins.SelectedCurrencies))
+	for _, coin := range coins.SelectedCurrencies {
+		merchant[strings.ToLower(coin)] = true
+	}
+	n.catalog.mu.Lock()
+	n.catalog.currencies = byCode
+	n.catalog.merchant = merchant
+	n.catalog.loadedAt = time.Now()
+	n.catalog.mu.Unlock()
+	return nil
+}
+
+func (n *nowPayments) minimumFor(ctx context.Context, payCurrency, fiatCurrency string) (*model.MinimumAmount, error) {
+	key := payCurrency + "/" + fiatCurrency
+	n.catalog.mu.Lock()
+	cached, ok := n.catalog.minimums[key]
+	n.catalog.mu.Unlock()
+	if ok && time.Since(cached.loadedAt) < n.catalog.ttl {
+		return cached.minimum, nil
+	}
+	minimum, err := n.GetMinimumAmount(ctx, payCurrency, payCurrency, fiatCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("could not load minimum amount for %s: %w", payCurrency, err)
+	}
+	n.catalog.mu.Lock()
+	n.catalog.minimums[key] = cachedMinimum{minimum: minimum, loadedAt: time.Now()}
+	n.catalog.mu.Unlock()
+	return minimum, nil
+}
+
+// WithCatalogTTL sets how long the currency catalog and minimum amounts are cached.
+func WithCatalogTTL(ttl time.Duration) Option {
+	return func(c *Config) {
+		c.catalogTTL = ttl
+	}
+}
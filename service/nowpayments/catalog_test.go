@@ -0,0 +1,55 @@
+package nowpayments_test
+
+import (
+	"context"
+	"errors"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/antidote-recognize0663/comics-galore-library/service/nowpayments"
+	"github.com/antidote-recognize0663/comics-galore-library/service/nowpayments/nowpaymentstest"
+	"testing"
+)
+
+func TestPaymentValidation(t *testing.T) {
+	server := nowpaymentstest.NewServer(nowpaymentstest.WithMerchantCoins("btc", "eth"))
+	defer server.Close()
+	service := nowpayments.NewNowPayments(nowpayments.WithEndpoint(server.URL()))
+	defer func() { _ = service.Close() }()
+	ctx := context.Background()
+
+	testCases := []struct {
+		name        string
+		priceIndex  int
+		payCurrency string
+		payAmount   float64
+		expectErr   error
+	}{
+		{name: "Valid Payment", priceIndex: 0, payCurrency: "BTC"},
+		{name: "Unknown Plan", priceIndex: 42, payCurrency: "btc", expectErr: nowpayments.ErrUnknownPlan},
+		{name: "Negative Plan Index", priceIndex: -1, payCurrency: "btc", expectErr: nowpayments.ErrUnknownPlan},
+		{name: "Coin Not Enabled For Merchant", priceIndex: 0, payCurrency: "ltc", expectErr: nowpayments.ErrUnsupportedCurrency},
+		{name: "Unknown Coin", priceIndex: 0, payCurrency: "doge", expectErr: nowpayments.ErrUnsupportedCurrency},
+		{name: "Pay Amount Below Minimum", priceIndex: 0, payCurrency: "eth", payAmount: 0.0001, expectErr: nowpayments.ErrAmountBelowMinimum},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := service.ValidatePayment(ctx, tc.priceIndex, tc.payCurrency, tc.payAmount)
+			if !errors.Is(err, tc.expectErr) {
+				t.Errorf("Expected %v, got %v", tc.expectErr, err)
+			}
+		})
+	}
+
+	// A 0.001 btc minimum is worth 60 USD, more than the 10 USD monthly plan.
+	strict := nowpaymentstest.NewServer(nowpaymentstest.WithCurrencies(model.Currency{Currency: "btc", MinAmount: 0.001, MaxAmount: 10}))
+	defer strict.Close()
+	strictService := nowpayments.NewNowPayments(nowpayments.WithEndpoint(strict.URL()))
+	defer func() { _ = strictService.Close() }()
+	_, err := strictService.CreateNowPayment(ctx, 0, "btc", 0, "https://example.com")
+	var validationErr *nowpayments.ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Err != nowpayments.ErrAmountBelowMinimum || validationErr.Minimum != 60 {
+		t.Errorf("Expected a 60 USD minimum to be enforced, got %v", err)
+	}
+	if _, ok := strict.Payment("5000000001"); ok {
+		t.Errorf("Expected no payment to be created")
+	}
+}
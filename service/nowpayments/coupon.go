@@ -0,0 +1,67 @@
+package nowpayments
+
+import (
+	"errors"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/config"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"math"
+)
+
+type paymentOptions struct {
+	couponCode    string
+	userID        string
+	priceCurrency string
+	gift          *model.Gift
+}
+
+type PaymentOption func(*paymentOptions)
+
+// WithCoupon applies the discount of the coupon the user entered. The coupon is
+// validated for the user and plan and redeemed with the coupon service set with
+// WithCoupons, and given back when the payment cannot be created. The response
+// carries the code and discount to store on the payment entry.
+func WithCoupon(code, userID string) PaymentOption {
+	return func(o *paymentOptions) {
+		o.couponCode = code
+		o.userID = userID
+	}
+}
+
+// discountedPrice validates the coupon for the user and plan and applies it to price.
+func (n *nowPayments) discountedPrice(code, userID string, plan config.SubscriptionPlan, price float64, currency string) (*model.Coupon, float64, error) {
+	if n.coupons == nil {
+		return nil, 0, fmt.Errorf("no coupon service configured")
+	}
+	found, err := n.coupons.Validate(code, userID, plan.GetID())
+	if err != nil {
+		return nil, 0, fmt.Errorf("coupon %s cannot be used for plan %s: %w", code, plan.GetID(), err)
+	}
+	if err := found.CheckCurrency(currency); err != nil {
+		return nil, 0, err
+	}
+	return found, found.Apply(price), nil
+}
+
+// redeemAround redeems the coupon, if there is one, before calling create, and gives
+// the redemption back when create fails.
+func (n *nowPayments) redeemAround(found *model.Coupon, userID, planID string, create func() error) error {
+	if found == nil {
+		return create()
+	}
+	if _, err := n.coupons.Redeem(found.Code, userID, planID); err != nil {
+		return fmt.Errorf("could not redeem coupon %s: %w", found.Code, err)
+	}
+	if err := create(); err != nil {
+		if releaseErr := n.coupons.Release(found.Code); releaseErr != nil {
+			return errors.Join(err, releaseErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// discountAmount is what a coupon took off the price, rounded to cents like Apply.
+func discountAmount(price, discounted float64) float64 {
+	return math.Round((price-discounted)*100) / 100
+}
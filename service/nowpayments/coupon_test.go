@@ -0,0 +1,65 @@
+package nowpayments_test
+
+import (
+	"context"
+	"errors"
+	"github.com/antidote-recognize0663/comics-galore-library/internal/appwritetest"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/antidote-recognize0663/comics-galore-library/service/coupon"
+	"github.com/antidote-recognize0663/comics-galore-library/service/nowpayments"
+	"github.com/antidote-recognize0663/comics-galore-library/service/nowpayments/nowpaymentstest"
+	"testing"
+)
+
+func TestCouponPricing(t *testing.T) {
+	server := nowpaymentstest.NewServer()
+	defer server.Close()
+	appwrite := appwritetest.NewServer()
+	defer appwrite.Close()
+	appwrite.Put("db", "coupons", "YEARLY20", map[string]interface{}{
+		"code": "YEARLY20", "discount_type": "percentage", "value": 20, "plan_ids": []string{"4"}, "redemptions": 0,
+	})
+	appwrite.Put("db", "coupons", "FIVEOFF", map[string]interface{}{
+		"code": "FIVEOFF", "discount_type": "fixed", "value": 5, "currency": "EUR", "redemptions": 0,
+	})
+	coupons := coupon.NewCoupon(appwrite.Client(), coupon.WithDatabaseID("db"),
+		coupon.WithCollectionID("coupons"), coupon.WithPaymentsCollectionID("payments"))
+	service := nowpayments.NewNowPayments(nowpayments.WithEndpoint(server.URL()), nowpayments.WithCoupons(coupons))
+	defer func() { _ = service.Close() }()
+	ctx := context.Background()
+
+	discounted, err := service.CreateNowPayment(ctx, 3, "btc", 0, "https://example.com", nowpayments.WithCoupon("yearly20", "user-1"))
+	if err != nil {
+		t.Fatalf("CreateNowPayment with coupon failed: %v", err)
+	}
+	if discounted.PriceAmount != 76.8 {
+		t.Errorf("Expected the coupon to reduce the 96 USD yearly plan to 76.8 USD, got %v", discounted.PriceAmount)
+	}
+	if discounted.CouponCode != "YEARLY20" || discounted.DiscountAmount != 19.2 {
+		t.Errorf("Expected the redemption of YEARLY20 for 19.2 USD, got %s for %v", discounted.CouponCode, discounted.DiscountAmount)
+	}
+	if _, err := service.CreateNowPayment(ctx, 0, "btc", 0, "https://example.com", nowpayments.WithCoupon("YEARLY20", "user-1")); !errors.Is(err, model.ErrCouponNotApplicable) {
+		t.Errorf("Expected the coupon to be rejected for the monthly plan, got %v", err)
+	}
+	if _, err := service.CreateNowPayment(ctx, 3, "doge", 0, "https://example.com", nowpayments.WithCoupon("YEARLY20", "user-1")); err == nil {
+		t.Errorf("Expected an unsupported coin to be rejected")
+	}
+	if stored, _ := appwrite.Document("db", "coupons", "YEARLY20"); stored["redemptions"] != float64(1) {
+		t.Errorf("Expected only the created payment to redeem the coupon, got %v redemptions", stored["redemptions"])
+	}
+
+	fixed, err := service.CreateNowPayment(ctx, 1, "btc", 0, "https://example.com",
+		nowpayments.WithCoupon("FIVEOFF", "user-1"), nowpayments.WithPriceCurrency("eur"))
+	if err != nil {
+		t.Fatalf("CreateNowPayment with a fixed coupon failed: %v", err)
+	}
+	if fixed.PriceAmount != 22 {
+		t.Errorf("Expected the coupon to reduce the 27 EUR quarterly plan to 22 EUR, got %v", fixed.PriceAmount)
+	}
+	if _, err := service.CreateNowPayment(ctx, 1, "btc", 0, "https://example.com", nowpayments.WithCoupon("FIVEOFF", "user-1")); !errors.Is(err, model.ErrCouponCurrency) {
+		t.Errorf("Expected the EUR coupon to be rejected for a USD price, got %v", err)
+	}
+	if _, err := service.CreateNowPayment(ctx, 3, "btc", 0, "https://example.com", nowpayments.WithCoupon("UNKNOWN", "user-1")); !errors.Is(err, model.ErrCouponNotFound) {
+		t.Errorf("Expected an unknown coupon to be rejected, got %v", err)
+	}
+}
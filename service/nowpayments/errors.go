@@ -0,0 +1,48 @@
+package nowpayments
+
+import (
+	"errors"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/internal/providerapi"
+)
+
+// APIError is returned when NowPayments answers with an unexpected HTTP status.
+type APIError = providerapi.APIError
+
+// IsStatus reports whether err is an *APIError with the given HTTP status code.
+func IsStatus(err error, statusCode int) bool {
+	return providerapi.IsStatus(err, statusCode)
+}
+
+var (
+	ErrUnknownPlan              = errors.New("unknown subscription plan")
+	ErrUnsupportedCurrency      = errors.New("unsupported pay currency")
+	ErrUnsupportedPriceCurrency = errors.New("unsupported price currency")
+	ErrAmountBelowMinimum       = errors.New("amount below minimum")
+)
+
+// ValidationError is returned when a payment request is rejected before it is sent
+// to NowPayments. It matches ErrUnknownPlan, ErrUnsupportedCurrency,
+// ErrUnsupportedPriceCurrency or ErrAmountBelowMinimum with errors.Is.
+type ValidationError struct {
+	Err      error
+	Plan     string
+	Currency string
+	Amount   float64
+	Minimum  float64
+}
+
+func (e *ValidationError) Error() string {
+	switch e.Err {
+	case ErrUnknownPlan:
+		return fmt.Sprintf("%v: %s", e.Err, e.Plan)
+	case ErrAmountBelowMinimum:
+		return fmt.Sprintf("%v: %g %s is less than %g %s", e.Err, e.Amount, e.Currency, e.Minimum, e.Currency)
+	default:
+		return fmt.Sprintf("%v: %s", e.Err, e.Currency)
+	}
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,23 @@
+package nowpayments
+
+import (
+	"github.com/antidote-recognize0663/comics-galore-library/config"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+)
+
+// WithBeneficiary buys the plan as a gift for the beneficiary of a gift created with
+// model.NewGift. The order description is marked as a gift; record the gift on the
+// payment entry with model.WithGift so that the beneficiary is entitled once the
+// payment finishes.
+func WithBeneficiary(gift *model.Gift) PaymentOption {
+	return func(o *paymentOptions) {
+		o.gift = gift
+	}
+}
+
+func orderDescription(plan config.SubscriptionPlan, gift *model.Gift) string {
+	if gift == nil {
+		return plan.GetName()
+	}
+	return plan.GetName() + " (gift)"
+}
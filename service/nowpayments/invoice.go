@@ -0,0 +1,184 @@
+package nowpayments
+
+import (
+	"context"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/config"
+	"github.com/antidote-recognize0663/comics-galore-library/internal/providerapi"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"net/http"
+)
+
+// CreateInvoice creates a hosted-checkout invoice for the subscription plan with the
+// given ID. The buyer pays on the returned InvoiceURL, so no pay address or QR code
+// has to be rendered by the caller. Like CreateNowPayment, it is never retried.
+func (n *nowPayments) CreateInvoice(ctx context.Context, planID string, baseUrl string, opts ...InvoiceOption) (*model.InvoiceResponse, error) {
+	plan, ok := n.findPlan(planID)
+	if !ok {
+		return nil, &ValidationError{Err: ErrUnknownPlan, Plan: planID}
+	}
+	options := &invoiceOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	price, priceCurrency, err := n.planPrice(ctx, plan, options.priceCurrency)
+	if err != nil {
+		return nil, err
+	}
+	priceAmount := price
+	var found *model.Coupon
+	if options.couponCode != "" {
+		found, priceAmount, err = n.discountedPrice(options.couponCode, options.userID, plan, price, priceCurrency)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if options.payCurrency != "" {
+		if err := n.validateCurrency(ctx, priceAmount, priceCurrency, options.payCurrency, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	request := model.NewInvoiceRequest(
+		priceAmount,
+		priceCurrency,
+		model.NewOrderID(plan.GetID()),
+		orderDescription(plan, options.gift),
+		baseUrl+n.ipnPath)
+	if options.payCurrency != "" {
+		request.PayCurrency = &options.payCurrency
+	}
+	if options.successURL != "" {
+		request.SuccessURL = &options.successURL
+	}
+	if options.cancelURL != "" {
+		request.CancelURL = &options.cancelURL
+	}
+
+	var created *model.InvoiceResponse
+	err = n.redeemAround(found, options.userID, plan.GetID(), func() error {
+		created, err = n.createInvoice(ctx, "CreateInvoice", request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found != nil {
+		created.CouponCode = found.Code
+		created.DiscountAmount = discountAmount(price, priceAmount)
+	}
+	return created, nil
+}
+
+func (n *nowPayments) createInvoice(ctx context.Context, operation string, request *model.InvoiceRequest) (*model.InvoiceResponse, error) {
+	resp, err := n.client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(request).
+		SetResult(&model.InvoiceResponse{}).
+		SetError(&model.NowPaymentsError{}).
+		Post("/invoice")
+	if err := providerapi.CheckResponse(operation, resp, err, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return resp.Result().(*model.InvoiceResponse), nil
+}
+
+func (n *nowPayments) GetInvoice(ctx context.Context, invoiceID string) (*model.InvoiceResponse, error) {
+	if invoiceID == "" {
+		return nil, fmt.Errorf("invoiceID cannot be empty")
+	}
+	resp, err := n.client.R().
+		SetContext(ctx).
+		SetPathParam("invoiceID", invoiceID).
+		SetResult(&model.InvoiceResponse{}).
+		SetError(&model.NowPaymentsError{}).
+		Get("/invoice/{invoiceID}")
+	if err := providerapi.CheckResponse("GetInvoice", resp, err, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return resp.Result().(*model.InvoiceResponse), nil
+}
+
+func (n *nowPayments) findPlan(planID string) (config.SubscriptionPlan, bool) {
+	for _, plan := range n.subscriptionPlans {
+		if plan.GetID() == planID {
+			return plan, true
+		}
+	}
+	return nil, false
+}
+
+type invoiceOptions struct {
+	payCurrency   string
+	successURL    string
+	cancelURL     string
+	couponCode    string
+	userID        string
+	priceCurrency string
+	gift          *model.Gift
+}
+
+type InvoiceOption func(*invoiceOptions)
+
+// WithInvoicePayCurrency preselects the coin on the invoice page.
+func WithInvoicePayCurrency(payCurrency string) InvoiceOption {
+	return func(o *invoiceOptions) {
+		o.payCurrency = payCurrency
+	}
+}
+
+func WithSuccessURL(successURL string) InvoiceOption {
+	return func(o *invoiceOptions) {
+		o.successURL = successURL
+	}
+}
+
+func WithCancelURL(cancelURL string) InvoiceOption {
+	return func(o *invoiceOptions) {
+		o.cancelURL = cancelURL
+	}
+}
+
+// WithInvoiceBeneficiary buys the plan as a gift, see WithBeneficiary.
+func WithInvoiceBeneficiary(gift *model.Gift) InvoiceOption {
+	return func(o *invoiceOptions) {
+		o.gift = gift
+	}
+}
+
+// WithInvoiceCoupon applies the discount of a coupon to the invoice, see WithCoupon.
+func WithInvoiceCoupon(code, userID string) InvoiceOption {
+	return func(o *invoiceOptions) {
+		o.couponCode = code
+		o.userID = userID
+	}
+}
+
+// ListInvoicePayments returns the payments made on an invoice, most recent first. The
+// endpoint needs the account credentials, see WithCredentials.
+func (n *nowPayments) ListInvoicePayments(ctx context.Context, invoiceID string) ([]model.NowPaymentsIPN, error) {
+	if invoiceID == "" {
+		return nil, fmt.Errorf("invoiceID cannot be empty")
+	}
+	token, err := n.authToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := n.client.R().
+		SetContext(ctx).
+		SetAuthToken(token).
+		SetQueryParams(map[string]string{
+			"invoiceId": invoiceID,
+			"limit":     "100",
+			"sortBy":    "created_at",
+			"orderBy":   "desc",
+		}).
+		SetResult(&model.NowPaymentsPaymentList{}).
+		SetError(&model.NowPaymentsError{}).
+		Get("/payment/")
+	if err := providerapi.CheckResponse("ListInvoicePayments", resp, err, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return resp.Result().(*model.NowPaymentsPaymentList).Data, nil
+}
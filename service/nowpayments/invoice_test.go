@@ -0,0 +1,90 @@
+package nowpayments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/antidote-recognize0663/comics-galore-library/internal/appwritetest"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/antidote-recognize0663/comics-galore-library/service/coupon"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCreateInvoice(t *testing.T) {
+	var requests []model.InvoiceRequest
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /invoice", func(w http.ResponseWriter, r *http.Request) {
+		var request model.InvoiceRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, request)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":             "5077125051",
+			"order_id":       *request.OrderID,
+			"price_amount":   request.PriceAmount,
+			"price_currency": request.PriceCurrency,
+			"invoice_url":    "https://nowpayments.io/payment/?iid=5077125051",
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	appwrite := appwritetest.NewServer()
+	defer appwrite.Close()
+	appwrite.Put("db", "coupons", "FIVEOFF", map[string]interface{}{
+		"code": "FIVEOFF", "discount_type": "fixed", "value": 5, "currency": "EUR", "redemptions": 0,
+	})
+	coupons := coupon.NewCoupon(appwrite.Client(), coupon.WithDatabaseID("db"),
+		coupon.WithCollectionID("coupons"), coupon.WithPaymentsCollectionID("payments"))
+	service := NewNowPayments(WithEndpoint(server.URL), WithCoupons(coupons))
+	defer func() { _ = service.Close() }()
+
+	testCases := []struct {
+		name          string
+		planID        string
+		opts          []InvoiceOption
+		expectAmount  float64
+		expectPricing string
+		expectErr     error
+	}{
+		{name: "Base Currency", planID: "1", expectAmount: 10, expectPricing: "usd"},
+		{name: "Plan Price Currency", planID: "2", opts: []InvoiceOption{WithInvoicePriceCurrency("EUR"), WithSuccessURL("https://example.com/thanks")}, expectAmount: 27, expectPricing: "eur"},
+		{name: "Coupon", planID: "2", opts: []InvoiceOption{WithInvoicePriceCurrency("eur"), WithInvoiceCoupon("FIVEOFF", "user-1")}, expectAmount: 22, expectPricing: "eur"},
+		{name: "Unknown Coupon", planID: "2", opts: []InvoiceOption{WithInvoiceCoupon("UNKNOWN", "user-1")}, expectErr: model.ErrCouponNotFound},
+		{name: "Unknown Plan", planID: "9", expectErr: ErrUnknownPlan},
+		{name: "Unsupported Price Currency", planID: "1", opts: []InvoiceOption{WithInvoicePriceCurrency("chf")}, expectErr: ErrUnsupportedPriceCurrency},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests = nil
+			invoice, err := service.CreateInvoice(context.Background(), tc.planID, "https://example.com", tc.opts...)
+			if tc.expectErr != nil {
+				if !errors.Is(err, tc.expectErr) || len(requests) != 0 {
+					t.Errorf("Expected %v without a request, got %v after %d requests", tc.expectErr, err, len(requests))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateInvoice failed: %v", err)
+			}
+			if len(requests) != 1 {
+				t.Fatalf("Expected one invoice request, got %d", len(requests))
+			}
+			request := requests[0]
+			if request.PriceAmount != tc.expectAmount || request.PriceCurrency != tc.expectPricing ||
+				*request.IPNCallbackURL != "https://example.com"+IPNPath || model.PlanIDFromOrderID(*request.OrderID) != tc.planID {
+				t.Errorf("Unexpected invoice request: %+v", request)
+			}
+			if invoice.InvoiceURL == "" || invoice.OrderID != *request.OrderID {
+				t.Errorf("Unexpected invoice: %+v", invoice)
+			}
+			if discount := 27 - tc.expectAmount; tc.planID == "2" && invoice.DiscountAmount != discount {
+				t.Errorf("Expected a discount of %v, got %v", discount, invoice.DiscountAmount)
+			}
+		})
+	}
+}
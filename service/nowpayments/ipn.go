@@ -0,0 +1,80 @@
+package nowpayments
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/config"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/antidote-recognize0663/comics-galore-library/service/payment"
+	"net/http"
+)
+
+// SignatureHeader is the header NowPayments uses to deliver the HMAC of an IPN payload.
+const SignatureHeader = "x-nowpayments-sig"
+
+// IPNCallback is invoked after an IPN is stored; an error is answered with a 500.
+type IPNCallback func(r *http.Request, ipn *model.NowPaymentsIPN, payment *model.Payment) error
+
+// NewIPNHandler serves the NowPayments IPN endpoint; redeliveries skip the callback.
+// Example of usage:
+//
+//	handler := nowpayments.NewIPNHandler(cfg.NowPayments.IPNSecret, paymentService,
+//		nowpayments.WithIPNCallback(func(r *http.Request, ipn *model.NowPaymentsIPN, p *model.Payment) error {
+//			if ipn.PaymentStatus != model.StatusFinished {
+//				return nil
+//			}
+//			_, err := userService.AddLabel(p.UserID, "subscriber")
+//			return err
+//		}))
+//	mux.Handle("POST /nowpayments/ipn", handler)
+func NewIPNHandler(ipnSecret string, paymentService payment.Payment, opts ...IPNOption) http.Handler {
+	if paymentService == nil {
+		panic("payment service is required")
+	}
+	cfg := &ipnOptions{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	webhookOpts := []payment.WebhookOption{payment.WithWebhookMaxBodySize(cfg.maxBodySize)}
+	if cfg.callback != nil {
+		webhookOpts = append(webhookOpts, payment.WithWebhookCallback(ipnCallback(cfg.callback)))
+	}
+	return payment.NewWebhookHandler(&ipnVerifier{ipnSecret: ipnSecret}, paymentService, webhookOpts...)
+}
+
+func NewIPNHandlerWithConfig(cfg *config.Config, paymentService payment.Payment, opts ...IPNOption) http.Handler {
+	return NewIPNHandler(cfg.NowPayments.IPNSecret, paymentService, opts...)
+}
+
+// ipnCallback adapts an IPNCallback to the webhook handler.
+func ipnCallback(callback IPNCallback) payment.WebhookCallback {
+	return func(r *http.Request, update *model.PaymentUpdate, savedPayment *model.Payment) error {
+		var ipn model.NowPaymentsIPN
+		if err := json.Unmarshal(update.Raw, &ipn); err != nil {
+			return fmt.Errorf("could not decode IPN for order %s: %w", update.OrderID, err)
+		}
+		ipn.Raw = update.Raw
+		return callback(r, &ipn, savedPayment)
+	}
+}
+
+type ipnOptions struct {
+	maxBodySize int64
+	callback    IPNCallback
+}
+
+type IPNOption func(*ipnOptions)
+
+func WithIPNCallback(callback IPNCallback) IPNOption {
+	return func(o *ipnOptions) {
+		o.callback = callback
+	}
+}
+
+func WithMaxBodySize(size int64) IPNOption {
+	return func(o *ipnOptions) {
+		if size > 0 {
+			o.maxBodySize = size
+		}
+	}
+}
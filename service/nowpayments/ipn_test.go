@@ -0,0 +1,143 @@
+package nowpayments
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/antidote-recognize0663/comics-galore-library/service/payment"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakePaymentService records ApplyUpdate calls and rejects updates it saved already;
+// every other method panics if used.
+type fakePaymentService struct {
+	payment.Payment
+	saved []*model.PaymentUpdate
+	err   error
+}
+
+func (f *fakePaymentService) ApplyUpdate(data *model.PaymentUpdate) (*model.Payment, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	for _, saved := range f.saved {
+		if saved.Status == data.Status && bytes.Equal(saved.Raw, data.Raw) {
+			return nil, model.ErrDuplicateUpdate
+		}
+	}
+	f.saved = append(f.saved, data)
+	return &model.Payment{PaymentData: &model.PaymentData{UserID: "user-1", OrderID: data.OrderID, PaymentStatus: data.Status}}, nil
+}
+
+// sign computes the signature of a payload whose keys are already sorted, which is
+// exactly what NowPayments signs.
+func sign(payload, secret string) string {
+	h := hmac.New(sha512.New, []byte(secret))
+	h.Write([]byte(payload))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func TestIPNHandler(t *testing.T) {
+	const ipnSecret = "my-super-secret-ipn-key"
+	const validPayload = `{"order_id":"my-order-987","pay_amount":0.00025,"pay_currency":"btc","payment_id":12345678,"payment_status":"finished","price_amount":10.50,"price_currency":"usd"}`
+	const tamperedPayload = `{"order_id":"my-order-987","pay_amount":0.00025,"pay_currency":"btc","payment_id":12345678,"payment_status":"finished","price_amount":1.50,"price_currency":"usd"}`
+	validSignature := sign(validPayload, ipnSecret)
+
+	testCases := []struct {
+		name           string
+		deliveries     []string
+		signature      string
+		saveErr        error
+		expectStatus   []int
+		expectSaved    int
+		expectCallback int
+	}{
+		{
+			name:           "Valid Delivery",
+			deliveries:     []string{validPayload},
+			signature:      validSignature,
+			expectStatus:   []int{http.StatusOK},
+			expectSaved:    1,
+			expectCallback: 1,
+		},
+		{
+			name:           "Tampered Payload",
+			deliveries:     []string{tamperedPayload},
+			signature:      validSignature,
+			expectStatus:   []int{http.StatusUnauthorized},
+			expectSaved:    0,
+			expectCallback: 0,
+		},
+		{
+			name:           "Missing Signature",
+			deliveries:     []string{validPayload},
+			signature:      "",
+			expectStatus:   []int{http.StatusUnauthorized},
+			expectSaved:    0,
+			expectCallback: 0,
+		},
+		{
+			name:           "Out Of Order Delivery",
+			deliveries:     []string{validPayload},
+			signature:      validSignature,
+			saveErr:        &model.TransitionError{From: model.StatusRefunded, To: model.StatusFinished},
+			expectStatus:   []int{http.StatusOK},
+			expectSaved:    0,
+			expectCallback: 0,
+		},
+		{
+			name:           "Duplicate Delivery",
+			deliveries:     []string{validPayload, validPayload},
+			signature:      validSignature,
+			expectStatus:   []int{http.StatusOK, http.StatusOK},
+			expectSaved:    1,
+			expectCallback: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			paymentService := &fakePaymentService{err: tc.saveErr}
+			callbacks := 0
+			handler := NewIPNHandler(ipnSecret, paymentService, WithIPNCallback(
+				func(r *http.Request, ipn *model.NowPaymentsIPN, p *model.Payment) error {
+					callbacks++
+					if p.UserID != "user-1" || ipn.PaymentStatus != model.StatusFinished {
+						t.Errorf("Unexpected callback arguments: %+v %+v", ipn, p.PaymentData)
+					}
+					return nil
+				}))
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			for i, payload := range tc.deliveries {
+				req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(payload))
+				if err != nil {
+					t.Fatalf("Failed to create request: %v", err)
+				}
+				if tc.signature != "" {
+					req.Header.Set(SignatureHeader, tc.signature)
+				}
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatalf("Request failed: %v", err)
+				}
+				_ = resp.Body.Close()
+				if resp.StatusCode != tc.expectStatus[i] {
+					t.Errorf("Delivery %d: expected status %d, got %d", i, tc.expectStatus[i], resp.StatusCode)
+				}
+			}
+			if len(paymentService.saved) != tc.expectSaved {
+				t.Errorf("Expected %d saved payments, got %d", tc.expectSaved, len(paymentService.saved))
+			}
+			if callbacks != tc.expectCallback {
+				t.Errorf("Expected %d callbacks, got %d", tc.expectCallback, callbacks)
+			}
+		})
+	}
+}
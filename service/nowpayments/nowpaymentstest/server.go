@@ -0,0 +1,507 @@
+// Package nowpaymentstest provides an in-process fake of the NowPayments API for tests.
+//
+// The fake keeps payments in memory, lets a test push a payment through its statuses
+// and delivers signed IPN callbacks exactly like NowPayments does:
+//
+//	server := nowpaymentstest.NewServer(nowpaymentstest.WithIPNSecret("secret"))
+//	defer server.Close()
+//	service := nowpayments.NewNowPayments(nowpayments.WithEndpoint(server.URL()))
+//	created, _ := service.CreateNowPayment(ctx, 0, "btc", 0, ipnBaseURL)
+//	err := server.Advance(created.PaymentID, model.StatusConfirming, model.StatusFinished)
+package nowpaymentstest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/antidote-recognize0663/comics-galore-library/service/nowpayments"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is a fake NowPayments API backed by an httptest.Server.
+type Server struct {
+	server        *httptest.Server
+	client        *http.Client
+	apiKey        string
+	email         string
+	password      string
+	ipnSecret     string
+	ipnURL        string
+	currencies    []model.Currency
+	merchantCoins []string
+	rates         map[string]float64
+
+	mu       sync.Mutex
+	nextID   int64
+	payments map[string]*model.NowPaymentsIPN
+	ipnURLs  map[string]string
+	tokens   map[string]bool
+}
+
+// NewServer starts a fake NowPayments API. Without options it accepts any API key,
+// supports btc, eth and ltc and signs IPNs with an empty secret (see WithIPNSecret).
+func NewServer(opts ...Option) *Server {
+	cfg := &Config{
+		currencies: []model.Currency{
+			{Currency: "btc", MinAmount: 0.0001, MaxAmount: 10},
+			{Currency: "eth", MinAmount: 0.001, MaxAmount: 100},
+			{Currency: "ltc", MinAmount: 0.01, MaxAmount: 1000},
+		},
+		rates: map[string]float64{
+			"btc": 60000,
+			"eth": 3000,
+			"ltc": 80,
+		},
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.merchantCoins == nil {
+		for _, currency := range cfg.currencies {
+			cfg.merchantCoins = append(cfg.merchantCoins, currency.Currency)
+		}
+	}
+	s := &Server{
+		client:        &http.Client{Timeout: 10 * time.Second},
+		apiKey:        cfg.apiKey,
+		email:         cfg.email,
+		password:      cfg.password,
+		ipnSecret:     cfg.ipnSecret,
+		ipnURL:        cfg.ipnURL,
+		currencies:    cfg.currencies,
+		merchantCoins: cfg.merchantCoins,
+		rates:         cfg.rates,
+		nextID:        5000000000,
+		payments:      make(map[string]*model.NowPaymentsIPN),
+		ipnURLs:       make(map[string]string),
+		tokens:        make(map[string]bool),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", s.handleStatus)
+	mux.HandleFunc("GET /currencies", s.authenticated(s.handleCurrencies))
+	mux.HandleFunc("GET /merchant/coins", s.authenticated(s.handleMerchantCoins))
+	mux.HandleFunc("GET /estimate", s.authenticated(s.handleEstimate))
+	mux.HandleFunc("GET /min-amount", s.authenticated(s.handleMinAmount))
+	mux.HandleFunc("POST /payment", s.authenticated(s.handleCreatePayment))
+	mux.HandleFunc("GET /payment/{id}", s.authenticated(s.handleGetPayment))
+	mux.HandleFunc("POST /auth", s.handleAuth)
+	mux.HandleFunc("GET /payment/{$}", s.authenticated(s.loggedIn(s.handleListPayments)))
+	s.server = httptest.NewServer(mux)
+	return s
+}
+
+// URL returns the endpoint to configure the NowPayments client with.
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Payment returns a copy of the stored state of a payment.
+func (s *Server) Payment(paymentID string) (*model.NowPaymentsIPN, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.payments[paymentID]
+	if !ok {
+		return nil, false
+	}
+	copied := *stored
+	return &copied, true
+}
+
+// SetStatus moves a payment to the given status and delivers the resulting IPN.
+// A finished payment is considered fully paid.
+func (s *Server) SetStatus(paymentID string, status model.PaymentStatusEnum) error {
+	if err := status.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	stored, ok := s.payments[paymentID]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("unknown payment %s", paymentID)
+	}
+	stored.PaymentStatus = status
+	stored.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	switch status {
+	case model.StatusFinished, model.StatusConfirmed, model.StatusSending:
+		stored.ActuallyPaid = stored.PayAmount
+		stored.OutcomeAmount = stored.PayAmount
+		stored.OutcomeCurrency = stored.PayCurrency
+	case model.StatusPartiallyPaid:
+		stored.ActuallyPaid = roundAmount(stored.PayAmount / 2)
+	}
+	s.mu.Unlock()
+	return s.SendIPN(paymentID)
+}
+
+// SetInvoiceID makes a payment one of the payments of the invoice with the given
+// numeric ID, as if the buyer had paid it on the hosted checkout.
+func (s *Server) SetInvoiceID(paymentID, invoiceID string) error {
+	if _, err := strconv.ParseInt(invoiceID, 10, 64); err != nil {
+		return fmt.Errorf("invalid invoice ID %s: %w", invoiceID, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.payments[paymentID]
+	if !ok {
+		return fmt.Errorf("unknown payment %s", paymentID)
+	}
+	stored.InvoiceID = json.Number(invoiceID)
+	return nil
+}
+
+// Advance moves a payment through the given statuses in order, delivering an IPN for
+// each of them.
+func (s *Server) Advance(paymentID string, statuses ...model.PaymentStatusEnum) error {
+	for _, status := range statuses {
+		if err := s.SetStatus(paymentID, status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendIPN delivers the current state of a payment to its IPN URL, signed with the
+// configured IPN secret. Calling it twice simulates a duplicate delivery. Nothing is
+// sent when the payment has no IPN URL.
+func (s *Server) SendIPN(paymentID string) error {
+	s.mu.Lock()
+	stored, ok := s.payments[paymentID]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("unknown payment %s", paymentID)
+	}
+	payload, err := json.Marshal(stored)
+	target := s.ipnURLs[paymentID]
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("could not encode IPN: %w", err)
+	}
+	if target == "" {
+		return nil
+	}
+	signature, err := model.SignPayload(payload, s.ipnSecret)
+	if err != nil {
+		return fmt.Errorf("could not sign IPN: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("could not create IPN request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(nowpayments.SignatureHeader, signature)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("IPN delivery to %s failed: %w", target, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("IPN delivery to %s returned status %d", target, resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *Server) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.apiKey != "" && r.Header.Get("x-api-key") != s.apiKey {
+			writeError(w, http.StatusForbidden, "INVALID_API_KEY", "Invalid api key")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// loggedIn rejects requests without a Bearer token issued by POST /auth.
+func (s *Server) loggedIn(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		s.mu.Lock()
+		ok := s.tokens[token]
+		s.mu.Unlock()
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "AUTH_REQUIRED", "Authorization header is empty (Bearer JWTtoken is required)")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, model.StatusResponse{Message: "OK"})
+}
+
+func (s *Server) handleCurrencies(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, model.CurrenciesResponse{Currencies: s.currencies})
+}
+
+func (s *Server) handleMerchantCoins(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string][]string{"selectedCurrencies": s.merchantCoins})
+}
+
+func (s *Server) handleEstimate(w http.ResponseWriter, r *http.Request) {
+	amount, err := strconv.ParseFloat(r.URL.Query().Get("amount"), 64)
+	if err != nil || amount <= 0 {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", "amount must be a positive number")
+		return
+	}
+	from, to := r.URL.Query().Get("currency_from"), r.URL.Query().Get("currency_to")
+	estimated, ok := s.convert(amount, from, to)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", fmt.Sprintf("cannot estimate %s to %s", from, to))
+		return
+	}
+	writeJSON(w, http.StatusOK, model.EstimatedPrice{
+		AmountFrom:      amount,
+		CurrencyFrom:    from,
+		CurrencyTo:      to,
+		EstimatedAmount: strconv.FormatFloat(estimated, 'f', -1, 64),
+	})
+}
+
+func (s *Server) handleMinAmount(w http.ResponseWriter, r *http.Request) {
+	from, to := r.URL.Query().Get("currency_from"), r.URL.Query().Get("currency_to")
+	for _, currency := range s.currencies {
+		if currency.Currency != from {
+			continue
+		}
+		minimum := model.MinimumAmount{CurrencyFrom: from, CurrencyTo: to, MinAmount: currency.MinAmount}
+		if fiat := r.URL.Query().Get("fiat_equivalent"); fiat != "" {
+			minimum.FiatEquivalent, _ = s.convert(currency.MinAmount, from, fiat)
+		}
+		writeJSON(w, http.StatusOK, minimum)
+		return
+	}
+	writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", fmt.Sprintf("currency %s is not supported", from))
+}
+
+func (s *Server) handleCreatePayment(w http.ResponseWriter, r *http.Request) {
+	var request model.PaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", "invalid JSON body")
+		return
+	}
+	if request.PriceAmount <= 0 || request.PriceCurrency == "" || request.PayCurrency == "" {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", "price_amount, price_currency and pay_currency are required")
+		return
+	}
+	payAmount, ok := s.convert(request.PriceAmount, request.PriceCurrency, request.PayCurrency)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", fmt.Sprintf("pay_currency %s is not supported", request.PayCurrency))
+		return
+	}
+	if request.PayAmount != nil && *request.PayAmount > 0 {
+		payAmount = *request.PayAmount
+	}
+
+	s.mu.Lock()
+	s.nextID++
+	paymentID := strconv.FormatInt(s.nextID, 10)
+	now := time.Now().UTC().Format(time.RFC3339)
+	stored := &model.NowPaymentsIPN{
+		PaymentID:       s.nextID,
+		PaymentStatus:   model.StatusWaiting,
+		PayAddress:      fmt.Sprintf("fake-%s-address-%s", request.PayCurrency, paymentID),
+		PriceAmount:     request.PriceAmount,
+		PriceCurrency:   request.PriceCurrency,
+		PayAmount:       payAmount,
+		PayCurrency:     request.PayCurrency,
+		PurchaseID:      s.nextID + 1,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		Type:            "crypto2crypto",
+		PaymentExtraIDs: []int64{},
+	}
+	if request.OrderID != nil {
+		stored.OrderID = *request.OrderID
+	}
+	if request.OrderDescription != nil {
+		stored.OrderDescription = *request.OrderDescription
+	}
+	s.payments[paymentID] = stored
+	switch {
+	case s.ipnURL != "":
+		s.ipnURLs[paymentID] = s.ipnURL
+	case request.IPNCallbackURL != nil:
+		s.ipnURLs[paymentID] = *request.IPNCallbackURL
+	}
+	response := model.PaymentResponse{
+		PaymentID:        paymentID,
+		PaymentStatus:    stored.PaymentStatus,
+		PayAddress:       stored.PayAddress,
+		PriceAmount:      stored.PriceAmount,
+		PriceCurrency:    stored.PriceCurrency,
+		PayAmount:        stored.PayAmount,
+		PayCurrency:      stored.PayCurrency,
+		OrderID:          stored.OrderID,
+		OrderDescription: stored.OrderDescription,
+		IPNCallbackURL:   s.ipnURLs[paymentID],
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusCreated, response)
+}
+
+func (s *Server) handleGetPayment(w http.ResponseWriter, r *http.Request) {
+	stored, ok := s.Payment(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "PAYMENT_NOT_FOUND", "Payment not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, stored)
+}
+
+func (s *Server) handleAuth(w http.ResponseWriter, r *http.Request) {
+	var credentials struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST_PARAMS", err.Error())
+		return
+	}
+	if s.email != "" && (credentials.Email != s.email || credentials.Password != s.password) {
+		writeError(w, http.StatusUnauthorized, "INVALID_CREDENTIALS", "Invalid email or password")
+		return
+	}
+	s.mu.Lock()
+	s.nextID++
+	token := "token-" + strconv.FormatInt(s.nextID, 10)
+	s.tokens[token] = true
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, model.NowPaymentsAuthResponse{Token: token})
+}
+
+// handleListPayments lists the payments of the invoice given by the invoiceId query
+// parameter, most recent first. Other filters and paging are not supported.
+func (s *Server) handleListPayments(w http.ResponseWriter, r *http.Request) {
+	invoiceID := r.URL.Query().Get("invoiceId")
+	list := model.NowPaymentsPaymentList{Data: []model.NowPaymentsIPN{}, PagesCount: 1}
+	s.mu.Lock()
+	for _, stored := range s.payments {
+		if invoiceID == "" || stored.InvoiceID.String() == invoiceID {
+			list.Data = append(list.Data, *stored)
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(list.Data, func(i, j int) bool {
+		return list.Data[i].PaymentID > list.Data[j].PaymentID
+	})
+	list.Limit = len(list.Data)
+	list.Total = len(list.Data)
+	writeJSON(w, http.StatusOK, list)
+}
+
+// convert converts between currencies using the configured USD rates. Fiat currencies
+// other than USD are treated as being at par with it.
+func (s *Server) convert(amount float64, from, to string) (float64, bool) {
+	fromRate, ok := s.usdRate(from)
+	if !ok {
+		return 0, false
+	}
+	toRate, ok := s.usdRate(to)
+	if !ok {
+		return 0, false
+	}
+	return roundAmount(amount * fromRate / toRate), true
+}
+
+func (s *Server) usdRate(currency string) (float64, bool) {
+	switch currency {
+	case "usd", "eur", "gbp":
+		return 1, true
+	}
+	rate, ok := s.rates[currency]
+	return rate, ok && rate > 0
+}
+
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*1e8) / 1e8
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, model.NowPaymentsError{
+		Status:     false,
+		StatusCode: status,
+		Code:       code,
+		Message:    message,
+	})
+}
+
+type Config struct {
+	apiKey        string
+	email         string
+	password      string
+	ipnSecret     string
+	ipnURL        string
+	currencies    []model.Currency
+	merchantCoins []string
+	rates         map[string]float64
+}
+
+type Option func(*Config)
+
+// WithApiKey makes the fake reject requests that do not carry the given API key.
+func WithApiKey(apiKey string) Option {
+	return func(c *Config) {
+		c.apiKey = apiKey
+	}
+}
+
+// WithCredentials makes POST /auth reject any other login; any login is accepted by default.
+func WithCredentials(email, password string) Option {
+	return func(c *Config) {
+		c.email = email
+		c.password = password
+	}
+}
+
+// WithIPNSecret sets the secret IPN callbacks are signed with.
+func WithIPNSecret(ipnSecret string) Option {
+	return func(c *Config) {
+		c.ipnSecret = ipnSecret
+	}
+}
+
+// WithIPNURL sends every IPN to the given URL instead of the ipn_callback_url of the payment.
+func WithIPNURL(ipnURL string) Option {
+	return func(c *Config) {
+		c.ipnURL = ipnURL
+	}
+}
+
+// WithCurrencies replaces the supported currencies and their min/max amounts.
+func WithCurrencies(currencies ...model.Currency) Option {
+	return func(c *Config) {
+		c.currencies = currencies
+	}
+}
+
+// WithMerchantCoins sets the coins the merchant accepts; defaults to all currencies.
+func WithMerchantCoins(coins ...string) Option {
+	return func(c *Config) {
+		c.merchantCoins = coins
+	}
+}
+
+// WithRate sets the USD value of one unit of the given coin.
+func WithRate(currency string, usd float64) Option {
+	return func(c *Config) {
+		c.rates[currency] = usd
+	}
+}
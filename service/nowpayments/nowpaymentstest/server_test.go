@@ -0,0 +1,83 @@
+package nowpaymentstest
+
+import (
+	"context"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/antidote-recognize0663/comics-galore-library/service/nowpayments"
+	"github.com/antidote-recognize0663/comics-galore-library/service/payment"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type recordingPaymentService struct {
+	payment.Payment
+	mu       sync.Mutex
+	statuses []model.PaymentStatusEnum
+}
+
+func (r *recordingPaymentService) ApplyUpdate(data *model.PaymentUpdate) (*model.Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statuses = append(r.statuses, data.Status)
+	return &model.Payment{PaymentData: &model.PaymentData{OrderID: data.OrderID, PaymentStatus: data.Status}}, nil
+}
+
+func TestCheckoutFlow(t *testing.T) {
+	const ipnSecret = "fake-ipn-secret"
+	paymentService := &recordingPaymentService{}
+	ipnServer := httptest.NewServer(nowpayments.NewIPNHandler(ipnSecret, paymentService))
+	defer ipnServer.Close()
+
+	server := NewServer(WithApiKey("fake-api-key"), WithIPNSecret(ipnSecret), WithIPNURL(ipnServer.URL))
+	defer server.Close()
+	service := nowpayments.NewNowPayments(nowpayments.WithEndpoint(server.URL()), nowpayments.WithApiKey("fake-api-key"))
+	defer func() { _ = service.Close() }()
+	ctx := context.Background()
+
+	if _, err := service.GetApiStatus(ctx); err != nil {
+		t.Fatalf("GetApiStatus failed: %v", err)
+	}
+	coins, err := service.GetMerchantCoins(ctx)
+	if err != nil || len(coins.SelectedCurrencies) != 3 {
+		t.Fatalf("GetMerchantCoins returned %+v, %v", coins, err)
+	}
+
+	created, err := service.CreateNowPayment(ctx, 0, "btc", 0, "https://example.com")
+	if err != nil {
+		t.Fatalf("CreateNowPayment failed: %v", err)
+	}
+	if created.PaymentStatus != model.StatusWaiting || created.PayAmount <= 0 || model.PlanIDFromOrderID(created.OrderID) != "1" {
+		t.Errorf("Unexpected created payment: %+v", created)
+	}
+
+	if err := server.Advance(created.PaymentID, model.StatusConfirming, model.StatusFinished); err != nil {
+		t.Fatalf("Advance failed: %v", err)
+	}
+	if err := server.SendIPN(created.PaymentID); err != nil {
+		t.Errorf("Expected the duplicate IPN to be acknowledged, got: %v", err)
+	}
+
+	status, err := service.GetPaymentStatus(ctx, created.PaymentID)
+	if err != nil {
+		t.Fatalf("GetPaymentStatus failed: %v", err)
+	}
+	if status.PaymentStatus != model.StatusFinished || status.ActuallyPaid != created.PayAmount {
+		t.Errorf("Unexpected payment status: %+v", status)
+	}
+	expected := []model.PaymentStatusEnum{model.StatusConfirming, model.StatusFinished, model.StatusFinished}
+	if len(paymentService.statuses) != len(expected) {
+		t.Fatalf("Expected IPNs %v, got %v", expected, paymentService.statuses)
+	}
+	for i := range expected {
+		if paymentService.statuses[i] != expected[i] {
+			t.Errorf("IPN %d: expected %s, got %s", i, expected[i], paymentService.statuses[i])
+		}
+	}
+
+	unauthorized := nowpayments.NewNowPayments(nowpayments.WithEndpoint(server.URL()), nowpayments.WithApiKey("wrong"), nowpayments.WithRetry(0, 0, 0))
+	defer func() { _ = unauthorized.Close() }()
+	if _, err := unauthorized.GetPaymentStatus(ctx, created.PaymentID); !nowpayments.IsStatus(err, 403) {
+		t.Errorf("Expected a 403 APIError for a wrong API key, got: %v", err)
+	}
+}
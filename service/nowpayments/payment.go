@@ -1,152 +1,268 @@
 package nowpayments
 
 import (
+	"context"
 	"fmt"
 	"github.com/antidote-recognize0663/comics-galore-library/config"
+	"github.com/antidote-recognize0663/comics-galore-library/internal/providerapi"
 	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/antidote-recognize0663/comics-galore-library/service/coupon"
+	"net/http"
 	"resty.dev/v3"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+const (
+	defaultEndpoint = "https://api.nowpayments.io/v1"
+	// IPNPath is the default path the IPN handler is mounted at, appended to the base
+	// URL passed to CreateNowPayment and CreateInvoice.
+	IPNPath = "/nowpayments/ipn"
+)
+
 type NowPayments interface {
-	GetAvailableCurrencies() (*model.CurrenciesResponse, error)
-	GetMerchantCoins() (*model.MerchantCoins, error)
-	GetApiStatus() (*model.StatusResponse, error)
-	CreateNowPayment(priceIndex int, priceCurrency string, payAmount float64, baseUrl string) (*model.PaymentResponse, error)
-	GetEstimatedPrice(amount float64, currencyFrom, currencyTo string) (*model.EstimatedPrice, error)
-	GetPaymentStatus(paymentID string) (*model.NowPaymentsIPN, error)
+	GetAvailableCurrencies(ctx context.Context) (*model.CurrenciesResponse, error)
+	GetMerchantCoins(ctx context.Context) (*model.MerchantCoins, error)
+	GetApiStatus(ctx context.Context) (*model.StatusResponse, error)
+	CreateNowPayment(ctx context.Context, priceIndex int, payCurrency string, payAmount float64, baseUrl string, opts ...PaymentOption) (*model.PaymentResponse, error)
+	GetEstimatedPrice(ctx context.Context, amount float64, currencyFrom, currencyTo string) (*model.EstimatedPrice, error)
+	GetPaymentStatus(ctx context.Context, paymentID string) (*model.NowPaymentsIPN, error)
+	GetMinimumAmount(ctx context.Context, currencyFrom, currencyTo string, fiatEquivalent string) (*model.MinimumAmount, error)
+	ValidatePayment(ctx context.Context, priceIndex int, payCurrency string, payAmount float64) error
+	CreateInvoice(ctx context.Context, planID string, baseUrl string, opts ...InvoiceOption) (*model.InvoiceResponse, error)
+	GetInvoice(ctx context.Context, invoiceID string) (*model.InvoiceResponse, error)
+	ListInvoicePayments(ctx context.Context, invoiceID string) ([]model.NowPaymentsIPN, error)
+	Close() error
 }
 
 type nowPayments struct {
-	apiKey            string
-	endpoint          string
+	client            *resty.Client
+	catalog           *currencyCatalog
+	priceConversion   bool
+	ipnPath           string
 	subscriptionPlans []config.SubscriptionPlan
+	email             string
+	password          string
+	coupons           coupon.Coupon
+
+	tokenMu     sync.Mutex
+	token       string
+	tokenExpiry time.Time
+
+	closeOnce sync.Once
+	closeErr  error
 }
 
-func NewNowPaymentsWithConfig(cfg *config.Config) NowPayments {
-	return &nowPayments{
-		apiKey:            cfg.Appwrite.ApiKey,
-		endpoint:          cfg.Appwrite.Endpoint,
-		subscriptionPlans: *cfg.Application.GetSubscriptionPlans(),
+func NewNowPaymentsWithConfig(cfg *config.Config, opts ...Option) NowPayments {
+	options := []Option{
+		WithApiKey(cfg.NowPayments.ApiKey),
+		WithSubscriptionPlans(*cfg.Application.GetSubscriptionPlans()),
+		WithCoupons(coupon.NewCouponWithConfig(cfg)),
+	}
+	if cfg.NowPayments.Endpoint != "" {
+		options = append(options, WithEndpoint(cfg.NowPayments.Endpoint))
 	}
+	if cfg.NowPayments.Email != "" {
+		options = append(options, WithCredentials(cfg.NowPayments.Email, cfg.NowPayments.Password))
+	}
+	return NewNowPayments(append(options, opts...)...)
 }
 
+// NewNowPayments creates a NowPayments service backed by a single pooled HTTP client.
+// Requests time out after 15 seconds by default, and idempotent (GET) requests are
+// retried up to 3 times with exponential backoff when the API answers 429 or 5xx.
+// The service must be closed with Close once it is no longer needed.
 func NewNowPayments(opts ...Option) NowPayments {
 	cfg := &Config{
-		endpoint:          "https://api.nowpayments.io/v1",
+		endpoint:          defaultEndpoint,
+		timeout:           15 * time.Second,
+		retryCount:        3,
+		retryWaitTime:     500 * time.Millisecond,
+		retryMaxWaitTime:  5 * time.Second,
+		catalogTTL:        10 * time.Minute,
+		ipnPath:           IPNPath,
 		subscriptionPlans: *config.NewSubscriptionPlans(),
 	}
 	for _, opt := range opts {
 		opt(cfg)
 	}
+	var client *resty.Client
+	if cfg.httpClient != nil {
+		client = resty.NewWithClient(cfg.httpClient)
+	} else {
+		client = resty.New()
+	}
+	client.
+		SetBaseURL(cfg.endpoint).
+		SetTimeout(cfg.timeout).
+		SetRetryCount(cfg.retryCount).
+		SetRetryWaitTime(cfg.retryWaitTime).
+		SetRetryMaxWaitTime(cfg.retryMaxWaitTime).
+		SetHeader("x-api-key", cfg.apiKey).
+		SetHeader("Accept", "application/json")
 	return &nowPayments{
-		apiKey:            cfg.apiKey,
-		endpoint:          cfg.endpoint,
+		client:            client,
+		catalog:           newCurrencyCatalog(cfg.catalogTTL),
+		priceConversion:   cfg.priceConversion,
+		ipnPath:           cfg.ipnPath,
 		subscriptionPlans: cfg.subscriptionPlans,
+		email:             cfg.email,
+		password:          cfg.password,
+		coupons:           cfg.coupons,
 	}
 }
 
-func (n *nowPayments) GetAvailableCurrencies() (*model.CurrenciesResponse, error) {
-	client := resty.New()
-	resp, err := client.R().
-		SetHeader("x-nowPayments-key", n.apiKey).
+// Close releases the HTTP client. It is safe to call more than once.
+func (n *nowPayments) Close() error {
+	n.closeOnce.Do(func() {
+		n.closeErr = n.client.Close()
+	})
+	return n.closeErr
+}
+
+func (n *nowPayments) GetAvailableCurrencies(ctx context.Context) (*model.CurrenciesResponse, error) {
+	resp, err := n.client.R().
+		SetContext(ctx).
+		SetQueryParam("fixed_rate", "true").
 		SetResult(&model.CurrenciesResponse{}).
-		Get(n.endpoint + "/currencies?fixed_rate=true")
-	if err != nil {
-		return nil, fmt.Errorf("GetAvailableCurrencies request failed: %w", err)
+		SetError(&model.NowPaymentsError{}).
+		Get("/currencies")
+	if err := providerapi.CheckResponse("GetAvailableCurrencies", resp, err, http.StatusOK); err != nil {
+		return nil, err
 	}
 	currenciesResponse := resp.Result().(*model.CurrenciesResponse)
-	if currenciesResponse.Currencies == nil || len(currenciesResponse.Currencies) == 0 {
+	if len(currenciesResponse.Currencies) == 0 {
 		return nil, fmt.Errorf("received an empty or missing currencies list")
 	}
 	return currenciesResponse, nil
 }
 
-func (n *nowPayments) GetMerchantCoins() (*model.MerchantCoins, error) {
-	client := resty.New()
-	resp, err := client.R().
-		SetHeader("x-nowPayments-key", n.apiKey).
+func (n *nowPayments) GetMerchantCoins(ctx context.Context) (*model.MerchantCoins, error) {
+	resp, err := n.client.R().
+		SetContext(ctx).
 		SetResult(&model.MerchantCoins{}).
-		Get(n.endpoint + "/merchant/coins")
-	if err != nil {
-		return nil, fmt.Errorf("GetMerchantCoins request failed: %w", err)
+		SetError(&model.NowPaymentsError{}).
+		Get("/merchant/coins")
+	if err := providerapi.CheckResponse("GetMerchantCoins", resp, err, http.StatusOK); err != nil {
+		return nil, err
 	}
 	currenciesResponse := resp.Result().(*model.MerchantCoins)
-	if currenciesResponse.SelectedCurrencies == nil || len(currenciesResponse.SelectedCurrencies) == 0 {
+	if len(currenciesResponse.SelectedCurrencies) == 0 {
 		return nil, fmt.Errorf("received an empty or missing currencies list")
 	}
 	return currenciesResponse, nil
 }
 
-func (n *nowPayments) GetApiStatus() (*model.StatusResponse, error) {
-	client := resty.New()
-	resp, err := client.R().SetResult(&model.StatusResponse{}).Get(n.endpoint + "/status")
-	if err != nil {
-		return nil, fmt.Errorf("GetApiStatus request failed: %w", err)
+func (n *nowPayments) GetApiStatus(ctx context.Context) (*model.StatusResponse, error) {
+	resp, err := n.client.R().
+		SetContext(ctx).
+		SetResult(&model.StatusResponse{}).
+		SetError(&model.NowPaymentsError{}).
+		Get("/status")
+	if err := providerapi.CheckResponse("GetApiStatus", resp, err, http.StatusOK); err != nil {
+		return nil, err
 	}
 	return resp.Result().(*model.StatusResponse), nil
 }
 
-func (n *nowPayments) CreateNowPayment(priceIndex int, priceCurrency string, payAmount float64, baseUrl string) (*model.PaymentResponse, error) {
+// CreateNowPayment creates a payment for the plan at priceIndex. The request is first
+// checked against the currency catalog, so an unknown plan, an unsupported coin or an
+// amount below the minimum is reported as a *ValidationError. The plan is priced in
+// USD unless another fiat currency is chosen with WithPriceCurrency, and WithCoupon
+// redeems a coupon for it. WithBeneficiary buys the plan as a gift. Payment creation
+// is not idempotent, so it is never retried.
+func (n *nowPayments) CreateNowPayment(ctx context.Context, priceIndex int, payCurrency string, payAmount float64, baseUrl string, opts ...PaymentOption) (*model.PaymentResponse, error) {
+	if priceIndex < 0 || priceIndex >= len(n.subscriptionPlans) {
+		return nil, &ValidationError{Err: ErrUnknownPlan, Plan: strconv.Itoa(priceIndex)}
+	}
+	options := &paymentOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	plan := n.subscriptionPlans[priceIndex]
+	price, priceCurrency, err := n.planPrice(ctx, plan, options.priceCurrency)
+	if err != nil {
+		return nil, err
+	}
+	priceAmount := price
+	var found *model.Coupon
+	if options.couponCode != "" {
+		found, priceAmount, err = n.discountedPrice(options.couponCode, options.userID, plan, price, priceCurrency)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := n.validateCurrency(ctx, priceAmount, priceCurrency, payCurrency, payAmount); err != nil {
+		return nil, err
+	}
 
-	description := n.subscriptionPlans[priceIndex].GetName()
-	priceAmount := n.subscriptionPlans[priceIndex].GetPrice()
+	ipnCallbackURL := baseUrl + n.ipnPath
 
-	ipnCallbackURL := fmt.Sprintf("%s/nowpayments/ipn", baseUrl)
+	request := model.NewPaymentRequest(priceAmount, priceCurrency, payAmount, strings.ToLower(payCurrency), ipnCallbackURL, orderDescription(plan, options.gift))
+	orderID := model.NewOrderID(plan.GetID())
+	request.OrderID = &orderID
 
-	request := model.NewPaymentRequest(priceAmount, "usd", payAmount, priceCurrency, ipnCallbackURL, description)
+	var created *model.PaymentResponse
+	err = n.redeemAround(found, options.userID, plan.GetID(), func() error {
+		created, err = n.createPayment(ctx, "CreateNowPayment", request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found != nil {
+		created.CouponCode = found.Code
+		created.DiscountAmount = discountAmount(price, priceAmount)
+	}
+	return created, nil
+}
 
-	client := resty.New()
-	resp, err := client.R().
-		SetHeader("x-nowPayments-key", n.apiKey).
+func (n *nowPayments) createPayment(ctx context.Context, operation string, request *model.PaymentRequest) (*model.PaymentResponse, error) {
+	resp, err := n.client.R().
+		SetContext(ctx).
 		SetHeader("Content-Type", "application/json").
 		SetBody(request).
 		SetResult(&model.PaymentResponse{}).
-		Post(n.endpoint + "/payment")
-	if err != nil {
-		return nil, fmt.Errorf("CreateNowPayment request failed: %w", err)
-	}
-	if resp.StatusCode() != 201 {
-		return nil, fmt.Errorf("CreateNowPayment returned a non-201 status code: %d", resp.StatusCode())
+		SetError(&model.NowPaymentsError{}).
+		Post("/payment")
+	if err := providerapi.CheckResponse(operation, resp, err, http.StatusCreated); err != nil {
+		return nil, err
 	}
 	return resp.Result().(*model.PaymentResponse), nil
 }
 
-func (n *nowPayments) GetEstimatedPrice(amount float64, currencyFrom, currencyTo string) (*model.EstimatedPrice, error) {
-	client := resty.New()
-	resp, err := client.R().
-		SetHeader("x-nowPayments-key", n.apiKey).
+func (n *nowPayments) GetEstimatedPrice(ctx context.Context, amount float64, currencyFrom, currencyTo string) (*model.EstimatedPrice, error) {
+	resp, err := n.client.R().
+		SetContext(ctx).
 		SetQueryParams(map[string]string{
 			"amount":        fmt.Sprintf("%.4f", amount),
 			"currency_from": currencyFrom,
 			"currency_to":   currencyTo,
 		}).
 		SetResult(&model.EstimatedPrice{}).
-		Get(n.endpoint + "/estimate")
-	if err != nil {
-		return nil, fmt.Errorf("GetEstimatedPrice request failed: %w", err)
-	}
-	if resp.StatusCode() != 200 {
-		return nil, fmt.Errorf("GetEstimatedPrice returned a non-200 status code: %d", resp.StatusCode())
+		SetError(&model.NowPaymentsError{}).
+		Get("/estimate")
+	if err := providerapi.CheckResponse("GetEstimatedPrice", resp, err, http.StatusOK); err != nil {
+		return nil, err
 	}
 	return resp.Result().(*model.EstimatedPrice), nil
 }
 
 // GetPaymentStatus fetches the payment status from the NowPayments API
-func (n *nowPayments) GetPaymentStatus(paymentID string) (*model.NowPaymentsIPN, error) {
+func (n *nowPayments) GetPaymentStatus(ctx context.Context, paymentID string) (*model.NowPaymentsIPN, error) {
 	if paymentID == "" {
 		return nil, fmt.Errorf("paymentID cannot be empty")
 	}
-	client := resty.New().SetTimeout(10 * time.Second)
-	resp, err := client.R().
-		SetHeader("x-nowPayments-key", n.apiKey).
+	resp, err := n.client.R().
+		SetContext(ctx).
+		SetPathParam("paymentID", paymentID).
 		SetResult(&model.NowPaymentsIPN{}).
-		Get(fmt.Sprintf("%s/payment/%s", n.endpoint, paymentID))
-	if err != nil {
-		return nil, fmt.Errorf("GetPaymentStatus request to NowPayments failed: %w", err)
-	}
-	if resp.StatusCode() != 200 {
-		return nil, fmt.Errorf("GetPaymentStatus returned a non-200 status code: %d", resp.StatusCode())
+		SetError(&model.NowPaymentsError{}).
+		Get("/payment/{paymentID}")
+	if err := providerapi.CheckResponse("GetPaymentStatus", resp, err, http.StatusOK); err != nil {
+		return nil, err
 	}
 	result, ok := resp.Result().(*model.NowPaymentsIPN)
 	if !ok || result == nil {
@@ -167,10 +283,73 @@ func WithEndpoint(endpoint string) Option {
 	}
 }
 
+// WithCoupons sets the coupon service WithCoupon and WithInvoiceCoupon redeem with.
+func WithCoupons(coupons coupon.Coupon) Option {
+	return func(c *Config) {
+		c.coupons = coupons
+	}
+}
+
+// WithCredentials sets the account login that ListInvoicePayments authenticates with.
+func WithCredentials(email, password string) Option {
+	return func(c *Config) {
+		c.email = email
+		c.password = password
+	}
+}
+
+// WithTimeout sets the timeout applied to every request, retries included.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetry configures how often idempotent requests are retried and the bounds of
+// the exponential backoff between attempts. A count of 0 disables retries.
+func WithRetry(count int, waitTime, maxWaitTime time.Duration) Option {
+	return func(c *Config) {
+		c.retryCount = count
+		c.retryWaitTime = waitTime
+		c.retryMaxWaitTime = maxWaitTime
+	}
+}
+
+// WithHTTPClient makes the service use the given client and its connection pool.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Config) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithIPNPath sets the path of the IPN handler, see IPNPath.
+func WithIPNPath(path string) Option {
+	return func(c *Config) {
+		c.ipnPath = path
+	}
+}
+
+func WithSubscriptionPlans(plans []config.SubscriptionPlan) Option {
+	return func(c *Config) {
+		c.subscriptionPlans = plans
+	}
+}
+
 type Config struct {
 	apiKey            string
 	endpoint          string
+	timeout           time.Duration
+	retryCount        int
+	retryWaitTime     time.Duration
+	retryMaxWaitTime  time.Duration
+	catalogTTL        time.Duration
+	priceConversion   bool
+	ipnPath           string
+	httpClient        *http.Client
 	subscriptionPlans []config.SubscriptionPlan
+	email             string
+	password          string
+	coupons           coupon.Coupon
 }
 
 type Option func(*Config)
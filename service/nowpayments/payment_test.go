@@ -0,0 +1,86 @@
+package nowpayments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryAndTypedErrors(t *testing.T) {
+	var statusCalls, paymentCalls atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		if statusCalls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"message":"OK"}`))
+	})
+	mux.HandleFunc("GET /currencies", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"currencies":[{"currency":"btc","min_amount":0.0001,"max_amount":10}]}`))
+	})
+	mux.HandleFunc("GET /merchant/coins", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"selectedCurrencies":["btc"]}`))
+	})
+	mux.HandleFunc("GET /min-amount", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"currency_from":"btc","currency_to":"btc","min_amount":0.0001,"fiat_equivalent":6}`))
+	})
+	mux.HandleFunc("POST /payment", func(w http.ResponseWriter, r *http.Request) {
+		paymentCalls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"status":false,"statusCode":400,"code":"INVALID_REQUEST_PARAMS","message":"pay_currency is invalid"}`))
+	})
+	mux.HandleFunc("GET /payment/{id}", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	service := NewNowPayments(WithEndpoint(server.URL), WithRetry(3, time.Millisecond, 5*time.Millisecond))
+	defer func() { _ = service.Close() }()
+
+	status, err := service.GetApiStatus(context.Background())
+	if err != nil {
+		t.Fatalf("Expected GetApiStatus to succeed after retries, got: %v", err)
+	}
+	if status.Message != "OK" || statusCalls.Load() != 3 {
+		t.Errorf("Expected 3 attempts and message OK, got %d attempts and %q", statusCalls.Load(), status.Message)
+	}
+
+	_, err = service.CreateNowPayment(context.Background(), 0, "btc", 0, "https://example.com")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected an *APIError, got: %v", err)
+	}
+	if apiErr.StatusCode != http.StatusBadRequest || apiErr.Code != "INVALID_REQUEST_PARAMS" {
+		t.Errorf("Unexpected APIError: %+v", apiErr)
+	}
+	if paymentCalls.Load() != 1 {
+		t.Errorf("Expected CreateNowPayment not to be retried, got %d attempts", paymentCalls.Load())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := service.GetPaymentStatus(ctx, "42"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context deadline to abort GetPaymentStatus, got: %v", err)
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	service := NewNowPayments()
+	if err := service.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := service.Close(); err != nil {
+		t.Errorf("Expected a second Close to be a no-op, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package nowpayments
+
+import (
+	"context"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/config"
+	"strconv"
+	"strings"
+)
+
+// planPrice returns the price to charge for the plan in the given fiat currency,
+// together with the normalized currency. The plan's price
+// list is used first. For a currency the plan has no price for, the base price is
+// converted with GetEstimatedPrice and rounded with the plan's rounding rule when the
+// service was created with WithPriceConversion; otherwise the currency is rejected
+// with ErrUnsupportedPriceCurrency.
+func (n *nowPayments) planPrice(ctx context.Context, plan config.SubscriptionPlan, currency string) (float64, string, error) {
+	currency = strings.ToLower(currency)
+	if currency == "" {
+		currency = config.BaseCurrency
+	}
+	price, ok := plan.GetPriceIn(currency)
+	if !ok {
+		if !n.priceConversion {
+			return 0, "", &ValidationError{Err: ErrUnsupportedPriceCurrency, Currency: currency}
+		}
+		converted, err := n.convertPrice(ctx, plan.GetPrice(), currency)
+		if err != nil {
+			return 0, "", err
+		}
+		price = plan.GetRounding().Round(converted)
+	}
+	return price, currency, nil
+}
+
+func (n *nowPayments) convertPrice(ctx context.Context, amount float64, currency string) (float64, error) {
+	estimate, err := n.GetEstimatedPrice(ctx, amount, config.BaseCurrency, currency)
+	if err != nil {
+		return 0, fmt.Errorf("could not convert %g %s to %s: %w", amount, config.BaseCurrency, currency, err)
+	}
+	converted, err := strconv.ParseFloat(estimate.EstimatedAmount, 64)
+	if err != nil || converted <= 0 {
+		return 0, fmt.Errorf("invalid estimate %q for %g %s in %s", estimate.EstimatedAmount, amount, config.BaseCurrency, currency)
+	}
+	return converted, nil
+}
+
+// WithPriceCurrency sets the fiat currency the plan is priced in. It defaults to
+// config.BaseCurrency.
+func WithPriceCurrency(currency string) PaymentOption {
+	return func(o *paymentOptions) {
+		o.priceCurrency = currency
+	}
+}
+
+// WithInvoicePriceCurrency sets the fiat currency of the invoice, see WithPriceCurrency.
+func WithInvoicePriceCurrency(currency string) InvoiceOption {
+	return func(o *invoiceOptions) {
+		o.priceCurrency = currency
+	}
+}
+
+// WithPriceConversion lets plans be priced in fiat currencies they have no list price
+// for, by converting the base price with GetEstimatedPrice.
+func WithPriceConversion() Option {
+	return func(c *Config) {
+		c.priceConversion = true
+	}
+}
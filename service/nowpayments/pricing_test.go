@@ -0,0 +1,39 @@
+package nowpayments_test
+
+import (
+	"context"
+	"errors"
+	"github.com/antidote-recognize0663/comics-galore-library/service/nowpayments"
+	"github.com/antidote-recognize0663/comics-galore-library/service/nowpayments/nowpaymentstest"
+	"testing"
+)
+
+func TestFiatPricing(t *testing.T) {
+	server := nowpaymentstest.NewServer(nowpaymentstest.WithRate("chf", 1.1))
+	defer server.Close()
+	ctx := context.Background()
+
+	service := nowpayments.NewNowPayments(nowpayments.WithEndpoint(server.URL()))
+	defer func() { _ = service.Close() }()
+	listed, err := service.CreateNowPayment(ctx, 2, "eth", 0, "https://example.com", nowpayments.WithPriceCurrency("EUR"))
+	if err != nil {
+		t.Fatalf("CreateNowPayment in EUR failed: %v", err)
+	}
+	if listed.PriceCurrency != "eur" || listed.PriceAmount != 48.6 {
+		t.Errorf("Expected the discounted 48.6 EUR list price, got %v %s", listed.PriceAmount, listed.PriceCurrency)
+	}
+	if _, err := service.CreateNowPayment(ctx, 0, "eth", 0, "https://example.com", nowpayments.WithPriceCurrency("chf")); !errors.Is(err, nowpayments.ErrUnsupportedPriceCurrency) {
+		t.Errorf("Expected CHF to be rejected without price conversion, got %v", err)
+	}
+
+	converting := nowpayments.NewNowPayments(nowpayments.WithEndpoint(server.URL()), nowpayments.WithPriceConversion())
+	defer func() { _ = converting.Close() }()
+	converted, err := converting.CreateNowPayment(ctx, 0, "eth", 0, "https://example.com", nowpayments.WithPriceCurrency("chf"))
+	if err != nil {
+		t.Fatalf("CreateNowPayment in CHF failed: %v", err)
+	}
+	// 10 USD is 9.09 CHF, rounded up to 9.99 by the plan's charm rounding.
+	if converted.PriceCurrency != "chf" || converted.PriceAmount != 9.99 {
+		t.Errorf("Expected a converted price of 9.99 CHF, got %v %s", converted.PriceAmount, converted.PriceCurrency)
+	}
+}
@@ -0,0 +1,163 @@
+package nowpayments
+
+import (
+	"context"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/config"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/antidote-recognize0663/comics-galore-library/service/payment"
+	"net/http"
+	"strings"
+)
+
+type provider struct {
+	*nowPayments
+	ipnSecret string
+}
+
+// NewProvider returns NowPayments as a payment.PaymentProvider verifying IPNs with ipnSecret.
+func NewProvider(ipnSecret string, opts ...Option) payment.PaymentProvider {
+	return &provider{
+		nowPayments: NewNowPayments(opts...).(*nowPayments),
+		ipnSecret:   ipnSecret,
+	}
+}
+
+func NewProviderWithConfig(cfg *config.Config, opts ...Option) payment.PaymentProvider {
+	return &provider{
+		nowPayments: NewNowPaymentsWithConfig(cfg, opts...).(*nowPayments),
+		ipnSecret:   cfg.NowPayments.IPNSecret,
+	}
+}
+
+func (p *provider) Name() string {
+	return model.ProviderNowPayments
+}
+
+func (p *provider) WebhookPath() string {
+	return p.ipnPath
+}
+
+func (p *provider) CreateCheckout(ctx context.Context, request *model.CheckoutRequest) (*model.Checkout, error) {
+	if request == nil {
+		return nil, fmt.Errorf("a checkout request is required")
+	}
+	priceCurrency := strings.ToLower(request.PriceCurrency)
+	if request.PayCurrency == "" {
+		invoiceRequest := model.NewInvoiceRequest(request.PriceAmount, priceCurrency, request.OrderID, request.Description, request.NotificationURL)
+		if request.RedirectURL != "" {
+			invoiceRequest.SuccessURL = &request.RedirectURL
+		}
+		invoice, err := p.createInvoice(ctx, "CreateCheckout", invoiceRequest)
+		if err != nil {
+			return nil, err
+		}
+		return &model.Checkout{
+			Provider:      model.ProviderNowPayments,
+			OrderID:       request.OrderID,
+			InvoiceID:     invoice.ID,
+			Status:        model.StatusWaiting,
+			Description:   request.Description,
+			PriceAmount:   request.PriceAmount,
+			PriceCurrency: priceCurrency,
+			CheckoutURL:   invoice.InvoiceURL,
+		}, nil
+	}
+
+	payCurrency := strings.ToLower(request.PayCurrency)
+	if err := p.validateCurrency(ctx, request.PriceAmount, priceCurrency, payCurrency, 0); err != nil {
+		return nil, err
+	}
+	paymentRequest := model.NewPaymentRequest(request.PriceAmount, priceCurrency, 0, payCurrency, request.NotificationURL, request.Description)
+	paymentRequest.OrderID = &request.OrderID
+	created, err := p.createPayment(ctx, "CreateCheckout", paymentRequest)
+	if err != nil {
+		return nil, err
+	}
+	return &model.Checkout{
+		Provider:      model.ProviderNowPayments,
+		OrderID:       request.OrderID,
+		PaymentID:     created.PaymentID,
+		Status:        created.PaymentStatus,
+		Description:   request.Description,
+		PriceAmount:   created.PriceAmount,
+		PriceCurrency: created.PriceCurrency,
+		PayAmount:     created.PayAmount,
+		PayCurrency:   created.PayCurrency,
+		PayAddress:    created.PayAddress,
+	}, nil
+}
+
+func (p *provider) GetStatus(ctx context.Context, paymentID string) (*model.PaymentUpdate, error) {
+	status, err := p.GetPaymentStatus(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	return status.ToPaymentUpdate(), nil
+}
+
+// GetOrderStatus looks up an invoice that has no payment ID yet. It reports the
+// finished payment of the invoice if there is one, and otherwise the most recent one.
+func (p *provider) GetOrderStatus(ctx context.Context, orderID, invoiceID string) (*model.PaymentUpdate, error) {
+	if invoiceID == "" {
+		return nil, fmt.Errorf("order %s has no invoice ID", orderID)
+	}
+	payments, err := p.ListInvoicePayments(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(payments) == 0 {
+		return nil, nil
+	}
+	for i := range payments {
+		if payments[i].PaymentStatus == model.StatusFinished {
+			return payments[i].ToPaymentUpdate(), nil
+		}
+	}
+	return payments[0].ToPaymentUpdate(), nil
+}
+
+// VerifyWebhook checks the IPN signature.
+func (p *provider) VerifyWebhook(r *http.Request, body []byte) (*model.PaymentUpdate, error) {
+	return verifyIPN(p.ipnSecret, r, body)
+}
+
+// ipnVerifier is the part of the provider that webhooks need, without an API client.
+type ipnVerifier struct {
+	ipnSecret string
+}
+
+func (v *ipnVerifier) Name() string {
+	return model.ProviderNowPayments
+}
+
+func (v *ipnVerifier) WebhookPath() string {
+	return IPNPath
+}
+
+func (v *ipnVerifier) CreateCheckout(context.Context, *model.CheckoutRequest) (*model.Checkout, error) {
+	return nil, fmt.Errorf("the IPN verifier cannot create checkouts")
+}
+
+func (v *ipnVerifier) GetStatus(context.Context, string) (*model.PaymentUpdate, error) {
+	return nil, fmt.Errorf("the IPN verifier cannot look up payments")
+}
+
+func (v *ipnVerifier) VerifyWebhook(r *http.Request, body []byte) (*model.PaymentUpdate, error) {
+	return verifyIPN(v.ipnSecret, r, body)
+}
+
+func verifyIPN(ipnSecret string, r *http.Request, body []byte) (*model.PaymentUpdate, error) {
+	if ipnSecret == "" {
+		return nil, fmt.Errorf("no IPN secret configured")
+	}
+	signature := r.Header.Get(SignatureHeader)
+	if signature == "" {
+		return nil, fmt.Errorf("%w: missing %s header", payment.ErrWebhookSignature, SignatureHeader)
+	}
+	ok, reason, ipn := model.ValidatePayload(signature, body, ipnSecret)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", payment.ErrWebhookSignature, reason)
+	}
+	return ipn.ToPaymentUpdate(), nil
+}
@@ -0,0 +1,37 @@
+package nowpayments_test
+
+import (
+	"context"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/antidote-recognize0663/comics-galore-library/service/nowpayments"
+	"github.com/antidote-recognize0663/comics-galore-library/service/nowpayments/nowpaymentstest"
+	"github.com/antidote-recognize0663/comics-galore-library/service/payment"
+	"testing"
+)
+
+func TestProvider(t *testing.T) {
+	server := nowpaymentstest.NewServer()
+	defer server.Close()
+	ctx := context.Background()
+
+	provider := nowpayments.NewProvider("fake-ipn-secret", nowpayments.WithEndpoint(server.URL()))
+	providers := payment.NewProviders(provider)
+	request := &model.CheckoutRequest{OrderID: "1-abc", PlanID: "1", Description: "Monthly Plan", PriceAmount: 10, PriceCurrency: "usd", PayCurrency: "btc"}
+	checkout, err := providers.CreateCheckout(ctx, request)
+	if err != nil {
+		t.Fatalf("CreateCheckout failed: %v", err)
+	}
+	if checkout.Provider != model.ProviderNowPayments || checkout.PaymentID == "" || checkout.PayAddress == "" {
+		t.Errorf("Unexpected checkout: %+v", checkout)
+	}
+	if err := server.SetStatus(checkout.PaymentID, model.StatusFinished); err != nil {
+		t.Fatalf("SetStatus failed: %v", err)
+	}
+	update, err := provider.GetStatus(ctx, checkout.PaymentID)
+	if err != nil {
+		t.Fatalf("GetStatus failed: %v", err)
+	}
+	if update.OrderID != "1-abc" || update.Status != model.StatusFinished || update.PaymentID != checkout.PaymentID {
+		t.Errorf("Unexpected update: %+v", update)
+	}
+}
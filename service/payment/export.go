@@ -0,0 +1,234 @@
+package payment
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/appwrite/sdk-for-go/query"
+	"io"
+	"strconv"
+	"time"
+)
+
+type ExportFormat string
+
+const (
+	ExportCSV   ExportFormat = "csv"
+	ExportJSONL ExportFormat = "jsonl"
+)
+
+// ExportRecord is one payment in an accounting export. Amounts are in the currency
+// named next to them: the fiat price, the crypto amount asked and actually paid, and
+// the amount received after the provider's fees.
+type ExportRecord struct {
+	OrderID         string  `json:"order_id"`
+	CreatedAt       string  `json:"created_at"`
+	UpdatedAt       string  `json:"updated_at"`
+	Provider        string  `json:"provider"`
+	PaymentID       string  `json:"payment_id"`
+	UserID          string  `json:"user_id"`
+	PlanID          string  `json:"plan_id"`
+	Plan            string  `json:"plan"`
+	Status          string  `json:"status"`
+	PriceAmount     float64 `json:"price_amount"`
+	PriceCurrency   string  `json:"price_currency"`
+	DiscountAmount  float64 `json:"discount_amount"`
+	CouponCode      string  `json:"coupon_code"`
+	PayAmount       float64 `json:"pay_amount"`
+	PayCurrency     string  `json:"pay_currency"`
+	ActuallyPaid    float64 `json:"actually_paid"`
+	OutcomeAmount   float64 `json:"outcome_amount"`
+	OutcomeCurrency string  `json:"outcome_currency"`
+	PayinHash       string  `json:"payin_hash"`
+	PayoutHash      string  `json:"payout_hash"`
+}
+
+var exportHeader = []string{
+	"order_id", "created_at", "updated_at", "provider", "payment_id", "user_id", "plan_id", "plan", "status",
+	"price_amount", "price_currency", "discount_amount", "coupon_code", "pay_amount", "pay_currency",
+	"actually_paid", "outcome_amount", "outcome_currency", "payin_hash", "payout_hash",
+}
+
+// exportAttributes are the attributes selected for an export, so that the potentially
+// large status history is not loaded.
+var exportAttributes = []string{
+	"$id", "$createdAt", "$updatedAt", "order_id", "provider", "payment_id", "user_id", "plan_id",
+	"order_description", "payment_status", "price_amount", "price_currency", "discount_amount",
+	"coupon_code", "pay_amount", "pay_currency", "actually_paid", "outcome_amount", "outcome_currency",
+	"payin_hash", "payout_hash",
+}
+
+func newExportRecord(exported *model.Payment) *ExportRecord {
+	orderID := exported.OrderID
+	if orderID == "" {
+		orderID = exported.Document.Id
+	}
+	return &ExportRecord{
+		OrderID:         orderID,
+		CreatedAt:       exported.CreatedAt,
+		UpdatedAt:       exported.UpdatedAt,
+		Provider:        exported.ProviderName(),
+		PaymentID:       exported.PaymentID,
+		UserID:          exported.UserID,
+		PlanID:          exported.PlanID,
+		Plan:            planLabel(exported.PaymentData),
+		Status:          string(exported.PaymentStatus),
+		PriceAmount:     exported.PriceAmount,
+		PriceCurrency:   exported.PriceCurrency,
+		DiscountAmount:  exported.DiscountAmount,
+		CouponCode:      exported.CouponCode,
+		PayAmount:       exported.PayAmount,
+		PayCurrency:     exported.PayCurrency,
+		ActuallyPaid:    exported.ActuallyPaid,
+		OutcomeAmount:   exported.OutcomeAmount,
+		OutcomeCurrency: exported.OutcomeCurrency,
+		PayinHash:       exported.PayinHash,
+		PayoutHash:      exported.PayoutHash,
+	}
+}
+
+// Export writes every payment created in [from, to) to w, oldest first, as CSV with a
+// header row or as JSON Lines. Payments are read page by page with cursor pagination
+// and each page is written out before the next one is loaded, so the size of the range
+// does not matter. It returns the number of payments written.
+// Example of usage:
+//
+//	from := time.Date(2025, time.May, 1, 0, 0, 0, 0, time.UTC)
+//	written, err := paymentService.Export(ctx, file, from, from.AddDate(0, 1, 0), payment.ExportCSV)
+func (p *payment) Export(ctx context.Context, w io.Writer, from, to time.Time, format ExportFormat, opts ...ExportOption) (int64, error) {
+	if !from.Before(to) {
+		return 0, fmt.Errorf("export range start %s must be before its end %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	options := &exportOptions{limit: 100}
+	for _, opt := range opts {
+		opt(options)
+	}
+	writer, err := newExportWriter(w, format)
+	if err != nil {
+		return 0, err
+	}
+	var written int64
+	var cursor string
+	for {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+		queries := []string{
+			query.GreaterThanEqual("$createdAt", from.UTC().Format(time.RFC3339)),
+			query.LessThan("$createdAt", to.UTC().Format(time.RFC3339)),
+			query.OrderAsc("$createdAt"),
+			query.Limit(options.limit),
+			query.Select(exportAttributes),
+		}
+		if cursor != "" {
+			queries = append(queries, query.CursorAfter(cursor))
+		}
+		response, err := p.database.ListDocuments(p.databaseID, p.collectionID, p.database.WithListDocumentsQueries(queries))
+		if err != nil {
+			return written, fmt.Errorf("could not list payments to export: %w", model.TranslateError(err))
+		}
+		if len(response.Documents) == 0 {
+			break
+		}
+		exported, err := model.NewPayments(response)
+		if err != nil {
+			return written, fmt.Errorf("could not decode payments to export: %w", err)
+		}
+		for i := range exported {
+			if err := writer.Write(newExportRecord(&exported[i])); err != nil {
+				return written, fmt.Errorf("could not write payment %s: %w", exported[i].Document.Id, err)
+			}
+			written++
+		}
+		if err := writer.Flush(); err != nil {
+			return written, fmt.Errorf("could not write export: %w", err)
+		}
+		cursor = response.Documents[len(response.Documents)-1].Id
+	}
+	if err := writer.Flush(); err != nil {
+		return written, fmt.Errorf("could not write export: %w", err)
+	}
+	return written, nil
+}
+
+type exportWriter interface {
+	Write(record *ExportRecord) error
+	Flush() error
+}
+
+func newExportWriter(w io.Writer, format ExportFormat) (exportWriter, error) {
+	switch format {
+	case ExportCSV:
+		return &csvExportWriter{writer: csv.NewWriter(w)}, nil
+	case ExportJSONL:
+		return &jsonlExportWriter{encoder: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+type csvExportWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (c *csvExportWriter) Write(record *ExportRecord) error {
+	if !c.headerWritten {
+		if err := c.writer.Write(exportHeader); err != nil {
+			return err
+		}
+		c.headerWritten = true
+	}
+	return c.writer.Write([]string{
+		record.OrderID, record.CreatedAt, record.UpdatedAt, record.Provider, record.PaymentID, record.UserID,
+		record.PlanID, record.Plan, record.Status,
+		formatAmount(record.PriceAmount), record.PriceCurrency, formatAmount(record.DiscountAmount), record.CouponCode,
+		formatAmount(record.PayAmount), record.PayCurrency, formatAmount(record.ActuallyPaid),
+		formatAmount(record.OutcomeAmount), record.OutcomeCurrency, record.PayinHash, record.PayoutHash,
+	})
+}
+
+// Flush writes the header of an empty export, so that it is still a valid CSV file.
+func (c *csvExportWriter) Flush() error {
+	if !c.headerWritten {
+		if err := c.writer.Write(exportHeader); err != nil {
+			return err
+		}
+		c.headerWritten = true
+	}
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+type jsonlExportWriter struct {
+	encoder *json.Encoder
+}
+
+func (j *jsonlExportWriter) Write(record *ExportRecord) error {
+	return j.encoder.Encode(record)
+}
+
+func (j *jsonlExportWriter) Flush() error {
+	return nil
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', -1, 64)
+}
+
+type exportOptions struct {
+	limit int
+}
+
+type ExportOption func(*exportOptions)
+
+// WithExportPageSize sets how many payments are loaded per page, 100 by default.
+func WithExportPageSize(limit int) ExportOption {
+	return func(o *exportOptions) {
+		if limit > 0 {
+			o.limit = limit
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/antidote-recognize0663/comics-galore-library/internal/appwritetest"
+	"github.com/appwrite/sdk-for-go/query"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportWriters(t *testing.T) {
+	record := &ExportRecord{
+		OrderID:         "1-abc",
+		Provider:        "nowpayments",
+		Plan:            "Monthly Plan, renewal",
+		Status:          "finished",
+		PriceAmount:     9.99,
+		PriceCurrency:   "usd",
+		PayAmount:       0.00015,
+		PayCurrency:     "btc",
+		ActuallyPaid:    0.00015,
+		OutcomeAmount:   0.000148,
+		OutcomeCurrency: "btc",
+		PayinHash:       "0xabc",
+	}
+
+	var csvOut bytes.Buffer
+	csvWriter, err := newExportWriter(&csvOut, ExportCSV)
+	if err != nil {
+		t.Fatalf("newExportWriter failed: %v", err)
+	}
+	if err := csvWriter.Write(record); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := csvWriter.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(csvOut.String()), "\n")
+	if len(lines) != 2 || lines[0] != strings.Join(exportHeader, ",") {
+		t.Fatalf("Expected a header and one row, got %q", csvOut.String())
+	}
+	if !strings.Contains(lines[1], `"Monthly Plan, renewal",finished,9.99,usd,0,,0.00015,btc,0.00015,0.000148,btc,0xabc,`) {
+		t.Errorf("Unexpected row: %q", lines[1])
+	}
+
+	var emptyOut bytes.Buffer
+	emptyWriter, _ := newExportWriter(&emptyOut, ExportCSV)
+	if err := emptyWriter.Flush(); err != nil || strings.TrimSpace(emptyOut.String()) != strings.Join(exportHeader, ",") {
+		t.Errorf("Expected an empty export to hold the header only, got %q, %v", emptyOut.String(), err)
+	}
+
+	var jsonlOut bytes.Buffer
+	jsonlWriter, _ := newExportWriter(&jsonlOut, ExportJSONL)
+	for i := 0; i < 2; i++ {
+		if err := jsonlWriter.Write(record); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	jsonLines := strings.Split(strings.TrimSpace(jsonlOut.String()), "\n")
+	var decoded ExportRecord
+	if len(jsonLines) != 2 || json.Unmarshal([]byte(jsonLines[1]), &decoded) != nil || decoded != *record {
+		t.Errorf("Unexpected JSON Lines export: %q", jsonlOut.String())
+	}
+
+	if _, err := newExportWriter(&jsonlOut, "xlsx"); err == nil {
+		t.Errorf("Expected an unsupported format to be rejected")
+	}
+}
+
+func TestExport(t *testing.T) {
+	server := appwritetest.NewServer()
+	defer server.Close()
+	from := time.Date(2025, time.May, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	createdAt := map[string]time.Time{
+		"before":     from.Add(-time.Second),
+		"first":      from,
+		"second":     from.Add(time.Hour),
+		"third":      from.AddDate(0, 0, 10),
+		"fourth":     from.AddDate(0, 0, 20),
+		"last":       to.Add(-time.Second),
+		"after":      to,
+		"much-later": to.AddDate(0, 1, 0),
+	}
+	for _, orderID := range []string{"before", "first", "second", "third", "fourth", "last", "after", "much-later"} {
+		server.Put(testDatabaseID, testCollectionID, orderID, map[string]interface{}{
+			"$createdAt": createdAt[orderID].Format(time.RFC3339), "order_id": orderID, "user_id": "user-1",
+			"plan_id": "1", "payment_status": "finished", "price_amount": 9.99, "price_currency": "usd",
+			"status_history": []string{"waiting", "finished"},
+		})
+	}
+	service := newTestPayment(server)
+
+	var out bytes.Buffer
+	written, err := service.Export(context.Background(), &out, from, to, ExportCSV, WithExportPageSize(2))
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV export: %v", err)
+	}
+	var orderIDs []string
+	for _, row := range rows[1:] {
+		orderIDs = append(orderIDs, row[0])
+	}
+	expected := []string{"first", "second", "third", "fourth", "last"}
+	if written != int64(len(expected)) || !slices.Equal(orderIDs, expected) {
+		t.Fatalf("Expected %v to be exported, got %d rows %v", expected, written, orderIDs)
+	}
+	if rows[1][1] != createdAt["first"].Format(time.RFC3339) || rows[1][9] != "9.99" {
+		t.Errorf("Unexpected row: %v", rows[1])
+	}
+
+	// Three full or partial pages are read, then an empty one ends the export.
+	requests := server.Queries(testDatabaseID, testCollectionID)
+	if len(requests) != 4 {
+		t.Fatalf("Expected 4 list requests, got %d", len(requests))
+	}
+	for i, queries := range requests {
+		for _, expectedQuery := range []string{
+			query.GreaterThanEqual("$createdAt", from.Format(time.RFC3339)),
+			query.LessThan("$createdAt", to.Format(time.RFC3339)),
+			query.Select(exportAttributes),
+		} {
+			if !slices.Contains(queries, expectedQuery) {
+				t.Errorf("Expected request %d to contain %s, got %v", i, expectedQuery, queries)
+			}
+		}
+		hasCursor := slices.ContainsFunc(queries, func(q string) bool { return strings.Contains(q, "cursorAfter") })
+		if hasCursor != (i > 0) {
+			t.Errorf("Unexpected cursor in request %d: %v", i, queries)
+		}
+	}
+	for i, cursor := range []string{"second", "fourth", "last"} {
+		if !slices.Contains(requests[i+1], query.CursorAfter(cursor)) {
+			t.Errorf("Expected request %d to start after %s, got %v", i+1, cursor, requests[i+1])
+		}
+	}
+}
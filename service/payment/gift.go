@@ -0,0 +1,94 @@
+package payment
+
+import (
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/config"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/appwrite/sdk-for-go/query"
+	"log"
+	"time"
+)
+
+// RedeemGift assigns the paid gift with the given code to the signed-in account. A gift
+// sent to an email address can only be redeemed by an account with that verified
+// address. The subscription starts on redemption, or extends the account's active
+// subscription, and the account gets the subscriber label. The gift is only assigned
+// while it has no user, so of two concurrent redemptions one fails with
+// model.ErrGiftAlreadyRedeemed.
+func (p *payment) RedeemGift(code string, account *model.Account) (*model.Payment, error) {
+	if account == nil || account.User == nil {
+		return nil, fmt.Errorf("an account is required to redeem a gift")
+	}
+	code = model.NormalizeGiftCode(code)
+	if code == "" {
+		return nil, model.ErrGiftNotFound
+	}
+	response, err := p.database.ListDocuments(p.databaseID, p.collectionID, p.database.WithListDocumentsQueries([]string{
+		query.Equal("gift_code", code),
+		query.Limit(1),
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("could not look up gift: %w", model.TranslateError(err))
+	}
+	if len(response.Documents) == 0 {
+		return nil, model.ErrGiftNotFound
+	}
+	gifts, err := model.NewPayments(response)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode gift: %w", err)
+	}
+	gift := &gifts[0]
+	if gift.BeneficiaryEmail != "" && !account.EmailVerification {
+		return nil, model.ErrGiftEmailNotVerified
+	}
+	plan, ok := p.findPlan(gift.PlanID)
+	if !ok {
+		return nil, fmt.Errorf("gift on order %s is for unknown plan %q", gift.Document.Id, gift.PlanID)
+	}
+	activeUntil, err := p.GetActiveExpiry(account.Id)
+	if err != nil {
+		return nil, err
+	}
+	if err := gift.RedeemGift(account.Id, account.Email, plan, activeUntil, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	response, err = p.database.UpdateDocuments(p.databaseID, p.collectionID,
+		p.database.WithUpdateDocumentsData(map[string]interface{}{
+			"user_id":     gift.UserID,
+			"expires_at":  gift.ExpiresAt,
+			"redeemed_at": gift.RedeemedAt,
+		}),
+		p.database.WithUpdateDocumentsQueries([]string{
+			query.Equal("$id", gift.Document.Id),
+			query.Or([]string{query.IsNull("user_id"), query.Equal("user_id", "")}),
+		}))
+	if err != nil {
+		return nil, fmt.Errorf("could not redeem gift on order %s: %w", gift.Document.Id, model.TranslateError(err))
+	}
+	redeemed, err := model.NewPayments(response)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode gift on order %s: %w", gift.Document.Id, err)
+	}
+	if len(redeemed) == 0 {
+		return nil, model.ErrGiftAlreadyRedeemed
+	}
+	p.invalidate(account.Id)
+	if p.userService != nil {
+		// The redemption is stored, so a failure is left for entitlement.Sync to repair
+		// rather than reported as a failed redemption.
+		if _, err := p.userService.AddLabel(account.Id, subscriberLabel); err != nil {
+			log.Printf("Could not grant access to user %s for gift on order %s: %v", account.Id, gift.Document.Id, err)
+		}
+	}
+	log.Printf("Gift on order %s redeemed by user %s until %s", gift.Document.Id, account.Id, gift.ExpiresAt)
+	return &redeemed[0], nil
+}
+
+func (p *payment) findPlan(planID string) (config.SubscriptionPlan, bool) {
+	for _, plan := range p.plans {
+		if plan.GetID() == planID {
+			return plan, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,83 @@
+package payment
+
+import (
+	"errors"
+	"github.com/antidote-recognize0663/comics-galore-library/internal/appwritetest"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/antidote-recognize0663/comics-galore-library/service/user"
+	"github.com/appwrite/sdk-for-go/models"
+	"testing"
+	"time"
+)
+
+// labelRecorder records the labels added through it; every other method panics if used.
+type labelRecorder struct {
+	user.User
+	added []string
+}
+
+func (l *labelRecorder) AddLabel(userID, label string) (*model.Account, error) {
+	l.added = append(l.added, userID+":"+label)
+	return &model.Account{User: &models.User{Id: userID}}, nil
+}
+
+func TestRedeemGift(t *testing.T) {
+	redeemBy := time.Now().UTC().AddDate(0, 1, 0).Format(time.RFC3339)
+	testCases := []struct {
+		name       string
+		gift       map[string]interface{}
+		concurrent bool
+		expectErr  error
+	}{
+		{name: "Redeemed", gift: map[string]interface{}{}},
+		{name: "Already Redeemed", gift: map[string]interface{}{"user_id": "someone-else"}, expectErr: model.ErrGiftAlreadyRedeemed},
+		{name: "Not Paid", gift: map[string]interface{}{"payment_status": "waiting"}, expectErr: model.ErrGiftNotPaid},
+		// Another account redeems the gift between reading and assigning it.
+		{name: "Concurrent Redemption", gift: map[string]interface{}{}, concurrent: true, expectErr: model.ErrGiftAlreadyRedeemed},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := appwritetest.NewServer()
+			defer server.Close()
+			gift := map[string]interface{}{
+				"order_id": "order-1", "plan_id": "1", "payment_status": "finished", "purchaser_id": "buyer",
+				"beneficiary_email": "friend@example.com", "gift_code": "GIFT-CODE", "gift_redeem_by": redeemBy,
+			}
+			for key, value := range tc.gift {
+				gift[key] = value
+			}
+			server.Put(testDatabaseID, testCollectionID, "order-1", gift)
+			if tc.concurrent {
+				server.OnWrite(func(databaseID, collectionID, documentID string) {
+					server.Update(databaseID, collectionID, documentID, map[string]interface{}{"user_id": "someone-else"})
+				})
+			}
+			labels := &labelRecorder{}
+			invalidator := &recordingInvalidator{}
+			service := newTestPayment(server, WithInvalidator(invalidator))
+			service.userService = labels
+			account := &model.Account{User: &models.User{Id: "friend", Email: "friend@example.com", EmailVerification: true}}
+
+			redeemed, err := service.RedeemGift(" gift-code ", account)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("Expected error %v, got %v", tc.expectErr, err)
+			}
+			stored, _ := server.Document(testDatabaseID, testCollectionID, "order-1")
+			if err != nil {
+				if stored["user_id"] == "friend" || len(labels.added) != 0 {
+					t.Errorf("Expected the gift not to be granted, got user %v and labels %v", stored["user_id"], labels.added)
+				}
+				return
+			}
+			if redeemed.UserID != "friend" || stored["user_id"] != "friend" || redeemed.ExpiresAt == "" {
+				t.Errorf("Expected the gift to be assigned to friend, got %+v", redeemed.PaymentData)
+			}
+			if len(labels.added) != 1 || labels.added[0] != "friend:"+subscriberLabel {
+				t.Errorf("Expected the subscriber label to be granted, got %v", labels.added)
+			}
+			if len(invalidator.users) != 1 || invalidator.users[0] != "friend" {
+				t.Errorf("Expected the entitlement of friend to be invalidated, got %v", invalidator.users)
+			}
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package payment
 
 import (
+	"context"
 	"fmt"
 	"github.com/antidote-recognize0663/comics-galore-library/config"
 	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/antidote-recognize0663/comics-galore-library/service/coupon"
+	"github.com/antidote-recognize0663/comics-galore-library/service/statistic"
 	"github.com/antidote-recognize0663/comics-galore-library/service/user"
 	"github.com/antidote-recognize0663/comics-galore-library/utils"
 	"github.com/appwrite/sdk-for-go/appwrite"
@@ -12,6 +15,7 @@ import (
 	"github.com/appwrite/sdk-for-go/id"
 	"github.com/appwrite/sdk-for-go/models"
 	"github.com/appwrite/sdk-for-go/query"
+	"io"
 	"log"
 	"time"
 )
@@ -22,15 +26,29 @@ type Payment interface {
 	Create(data *model.PaymentData) (*model.Payment, error)
 	Update(data *model.NowPaymentsIPN) (*model.Payment, error)
 	SaveOrUpdate(data *model.NowPaymentsIPN) (*model.Payment, error)
+	ApplyUpdate(data *model.PaymentUpdate) (*model.Payment, error)
 	WithQueryStatusNotEqual(status string) func([]string) []string
 	WithQueryOrderBy(field string, ascending bool) func([]string) []string
 	FetchList(secret, userID string, limit int, offset int, opts ...func([]string) []string) (*model.PaymentList, error)
 	ManageSubscribers(limit int, label ...string) (int64, error)
+	GetActiveExpiry(userID string) (time.Time, error)
+	Reconcile(ctx context.Context, providers *Providers, opts ...ReconcileOption) (*ReconcileReport, error)
+	SendReminders(ctx context.Context, notifier Notifier, opts ...ReminderOption) (*ReminderReport, error)
+	RedeemGift(code string, account *model.Account) (*model.Payment, error)
+	QuoteUpgrade(userID, planID, currency string) (*model.UpgradeQuote, error)
+	CreateUpgrade(ctx context.Context, providers *Providers, quote *model.UpgradeQuote, opts ...UpgradeOption) (*model.Payment, error)
+	Export(ctx context.Context, w io.Writer, from, to time.Time, format ExportFormat, opts ...ExportOption) (int64, error)
 }
 
 type payment struct {
 	database     *databases.Databases
 	userService  user.User
+	chart        statistic.Chart
+	counter      statistic.Counter
+	coupons      coupon.Coupon
+	invalidator  Invalidator
+	baseURL      string
+	plans        []config.SubscriptionPlan
 	endpoint     string
 	projectID    string
 	databaseID   string
@@ -61,22 +79,27 @@ func (p *payment) ManageSubscribers(limit int, label ...string) (int64, error) {
 		}
 		response, err := p.database.ListDocuments(p.databaseID, p.collectionID, p.database.WithListDocumentsQueries(queries))
 		if err != nil {
-			return totalProcessed, fmt.Errorf("could not list expired payments: %w", err)
+			return totalProcessed, fmt.Errorf("could not list expired payments: %w", model.TranslateError(err))
 		}
 		if response.Total == 0 {
 			break
 		}
+		expiredPayments, err := model.NewPayments(response)
+		if err != nil {
+			return totalProcessed, fmt.Errorf("could not decode expired payments: %w", err)
+		}
 		var idsToUpdate []models.Document
-		for _, doc := range response.Documents {
-			expiredPayment, err := model.NewPayment(&doc)
-			if err != nil {
-				log.Printf("ManageSubscribers: Could not create payment struct: %w", err)
+		for _, expiredPayment := range expiredPayments {
+			// Another payment may still keep the user subscribed.
+			if activeUntil, err := p.GetActiveExpiry(expiredPayment.UserID); err == nil && !activeUntil.IsZero() {
+				idsToUpdate = append(idsToUpdate, *expiredPayment.Document)
+				continue
 			}
 			if _, err := p.userService.RemoveLabel(expiredPayment.UserID, label[0]); err != nil {
 				log.Printf("ManageSubscribers: Could not remove label from user %s, skipping: %v", expiredPayment.UserID, err)
 				continue
 			}
-			idsToUpdate = append(idsToUpdate, doc)
+			idsToUpdate = append(idsToUpdate, *expiredPayment.Document)
 		}
 		// Update the batch of payments to mark them as expired
 		if len(idsToUpdate) > 0 {
@@ -86,7 +109,7 @@ func (p *payment) ManageSubscribers(limit int, label ...string) (int64, error) {
 					return BulkUpdate{Id: document.Id, Expired: true}
 				})))
 			if err != nil {
-				log.Printf("Could not update users: " + err.Error())
+				log.Printf("Could not update users: %v", err)
 			}
 		}
 		totalProcessed += int64(len(response.Documents))
@@ -95,6 +118,40 @@ func (p *payment) ManageSubscribers(limit int, label ...string) (int64, error) {
 	return totalProcessed, nil
 }
 
+// GetActiveExpiry returns the latest expires_at among the user's finished, unexpired
+// payments, or the zero time when the user has no active subscription. It is meant to
+// be passed to model.NewPaymentData so that a renewal extends the current subscription.
+func (p *payment) GetActiveExpiry(userID string) (time.Time, error) {
+	if userID == "" {
+		return time.Time{}, fmt.Errorf("userID cannot be empty")
+	}
+	response, err := p.database.ListDocuments(p.databaseID, p.collectionID, p.database.WithListDocumentsQueries([]string{
+		query.Equal("user_id", userID),
+		query.Equal("payment_status", string(model.StatusFinished)),
+		query.Equal("expired", false),
+		query.GreaterThan("expires_at", time.Now().UTC().Format(time.RFC3339)),
+		query.OrderDesc("expires_at"),
+		query.Limit(1),
+		query.Select([]string{"$id", "expires_at"}),
+	}))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not list active payments for user %s: %w", userID, model.TranslateError(err))
+	}
+	if len(response.Documents) == 0 {
+		return time.Time{}, nil
+	}
+	activePayments, err := model.NewPayments(response)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not decode active payment for user %s: %w", userID, err)
+	}
+	activePayment := activePayments[0]
+	expiresAt, err := time.Parse(time.RFC3339, activePayment.ExpiresAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid expires_at %q for user %s: %w", activePayment.ExpiresAt, userID, err)
+	}
+	return expiresAt, nil
+}
+
 func (p *payment) WithQueryStatusNotEqual(status string) func([]string) []string {
 	return func(queries []string) []string {
 		if status != "" {
@@ -104,12 +161,17 @@ func (p *payment) WithQueryStatusNotEqual(status string) func([]string) []string
 	}
 }
 
+// FetchList lists the payments of the user, including the gifts they bought for, or
+// redeemed from, another account.
 func (p *payment) FetchList(secret, userID string, limit int, offset int, opts ...func([]string) []string) (*model.PaymentList, error) {
 	database := p.getDatabases(secret)
 	queries := []string{
 		query.Limit(limit),
 		query.Offset(offset),
-		query.Equal("user_id", userID),
+		query.Or([]string{
+			query.Equal("user_id", userID),
+			query.Equal("purchaser_id", userID),
+		}),
 		query.OrderDesc("$updatedAt"),
 	}
 	for _, opt := range opts {
@@ -121,62 +183,67 @@ func (p *payment) FetchList(secret, userID string, limit int, offset int, opts .
 		database.WithListDocumentsQueries(queries),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("FetchList error: %v", err)
+		return nil, fmt.Errorf("FetchList error: %w", model.TranslateError(err))
 	}
 	var paymentList model.PaymentList
 	if err := documentList.Decode(&paymentList); err != nil {
-		return nil, fmt.Errorf("FetchList decode error: %v", err)
+		return nil, fmt.Errorf("FetchList decode error: %w", err)
 	}
 	return &paymentList, nil
 }
 
+// SaveOrUpdate applies a NowPayments IPN, see ApplyUpdate.
 func (p *payment) SaveOrUpdate(data *model.NowPaymentsIPN) (*model.Payment, error) {
 	if data == nil {
 		return nil, fmt.Errorf("data is required to update payment")
 	}
-	//var saveOrUpdateData := &model.PaymentData{}
+	return p.ApplyUpdate(data.ToPaymentUpdate())
+}
+
+// ApplyUpdate applies a provider's status update to the payment identified by its
+// order ID, creating the payment when it does not exist yet. Status changes go through
+// the payment state machine: an illegal transition is rejected with a
+// *model.TransitionError, and every accepted one is appended to the payment's status
+// history together with the raw provider payload. A payload that is in the history
+// already returns model.ErrDuplicateUpdate.
+func (p *payment) ApplyUpdate(data *model.PaymentUpdate) (*model.Payment, error) {
+	if data == nil {
+		return nil, fmt.Errorf("data is required to update payment")
+	}
 	//TODO: order_id must be unique in appwrite also (index)
 	if data.OrderID == "" {
 		data.OrderID = id.Unique()
 	}
-	document, err := p.database.UpsertDocument(
-		p.databaseID,
-		p.collectionID,
-		data.OrderID,
-		data)
-	if err != nil {
-		return nil, fmt.Errorf("saveOrUpdate error : %v", err)
-	}
-	var saveOrUpdatePayment model.Payment
-	if err := document.Decode(&saveOrUpdatePayment); err != nil {
-		return nil, fmt.Errorf("saveOrUpdate decode error : %v", err)
-	}
-	return &saveOrUpdatePayment, nil
+	return p.applyUpdate(data, true)
 }
 
+// Update applies an IPN to an existing payment, see SaveOrUpdate.
 func (p *payment) Update(data *model.NowPaymentsIPN) (*model.Payment, error) {
 	if data == nil {
 		return nil, fmt.Errorf("data is required to update payment")
 	}
-	document, err := p.database.UpdateDocument(
-		p.databaseID,
-		p.collectionID,
-		data.OrderID, p.database.WithUpdateDocumentData(data))
-	if err != nil {
-		return nil, fmt.Errorf("saveOrUpdate error : %v", err)
-	}
-	var saveOrUpdatePayment model.Payment
-	if err := document.Decode(&saveOrUpdatePayment); err != nil {
-		return nil, fmt.Errorf("saveOrUpdate decode error : %v", err)
+	if data.OrderID == "" {
+		return nil, fmt.Errorf("order_id is required to update payment")
 	}
-	return &saveOrUpdatePayment, nil
+	return p.applyUpdate(data.ToPaymentUpdate(), false)
 }
 
 func (p *payment) Create(data *model.PaymentData) (*model.Payment, error) {
 	if data == nil {
 		return nil, fmt.Errorf("data is required to create a new payment entry")
 	}
-	data.OrderID = id.Unique()
+	// The order ID is the document ID, so the IPNs NowPayments sends for the order
+	// update this very document.
+	if data.OrderID == "" {
+		data.OrderID = id.Unique()
+	}
+	if len(data.StatusHistory) == 0 && data.PaymentStatus != "" {
+		entry, err := model.NewStatusTransition("", data.PaymentStatus, nil)
+		if err != nil {
+			return nil, err
+		}
+		data.StatusHistory = []string{entry}
+	}
 	document, err := p.database.CreateDocument(
 		p.databaseID,
 		p.collectionID,
@@ -184,11 +251,11 @@ func (p *payment) Create(data *model.PaymentData) (*model.Payment, error) {
 		data,
 	)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("could not create payment %s: %w", data.OrderID, model.TranslateError(err))
 	}
 	var createPayment model.Payment
 	if err := document.Decode(&createPayment); err != nil {
-		return nil, fmt.Errorf("create decode error for documentID '%s': %v", data.OrderID, err)
+		return nil, fmt.Errorf("create decode error for documentID '%s': %w", data.OrderID, err)
 	}
 	return &createPayment, nil
 }
@@ -196,11 +263,11 @@ func (p *payment) Create(data *model.PaymentData) (*model.Payment, error) {
 func (p *payment) GetById(documentId string) (*model.Payment, error) {
 	documents, err := p.database.GetDocument(p.databaseID, p.collectionID, documentId)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get document with fileId '%s': %v", documentId, err)
+		return nil, fmt.Errorf("failed to get document with fileId '%s': %w", documentId, model.TranslateError(err))
 	}
 	var getPayment model.Payment
 	if err := documents.Decode(&getPayment); err != nil {
-		return nil, fmt.Errorf("failed to decode document with fileId '%s': %v", documentId, err)
+		return nil, fmt.Errorf("failed to decode document with fileId '%s': %w", documentId, err)
 	}
 	return &getPayment, nil
 }
@@ -208,7 +275,7 @@ func (p *payment) GetById(documentId string) (*model.Payment, error) {
 func (p *payment) Delete(documentId string) error {
 	_, err := p.database.DeleteDocument(p.databaseID, p.collectionID, documentId)
 	if err != nil {
-		return fmt.Errorf("failed to delete document with fileId '%s': %v", documentId, err)
+		return fmt.Errorf("failed to delete document with fileId '%s': %w", documentId, model.TranslateError(err))
 	}
 	return nil
 }
@@ -224,6 +291,7 @@ func NewPayment(client *client.Client, userService user.User, opts ...Option) Pa
 		projectID:    "6510a59f633f9d57fba2",
 		databaseID:   "6510add9771bcf260b40",
 		collectionID: "67806dd1003557f3794e",
+		plans:        *config.NewSubscriptionPlans(),
 	}
 	for _, opt := range opts {
 		opt(cfg)
@@ -231,6 +299,12 @@ func NewPayment(client *client.Client, userService user.User, opts ...Option) Pa
 	return &payment{
 		database:     cfg.database,
 		userService:  cfg.userService,
+		chart:        cfg.chart,
+		counter:      cfg.counter,
+		coupons:      cfg.coupons,
+		invalidator:  cfg.invalidator,
+		baseURL:      cfg.baseURL,
+		plans:        cfg.plans,
 		endpoint:     cfg.endpoint,
 		projectID:    cfg.projectID,
 		databaseID:   cfg.databaseID,
@@ -238,16 +312,97 @@ func NewPayment(client *client.Client, userService user.User, opts ...Option) Pa
 	}
 }
 
-func NewPaymentWithConfig(cfg *config.Config) Payment {
+// NewPaymentWithConfig creates the payment service from the application config. Options
+// are applied on top, for instance WithInvalidator:
+//
+//	entitlements := entitlement.NewEntitlementWithConfig(cfg)
+//	paymentService := payment.NewPaymentWithConfig(cfg, payment.WithInvalidator(entitlements))
+func NewPaymentWithConfig(cfg *config.Config, opts ...Option) Payment {
 	adminClient := utils.NewAdminClient(cfg.Appwrite.ApiKey, utils.WithProject(cfg.Appwrite.ProjectID), utils.WithEndpoint(cfg.Appwrite.Endpoint))
-	return &payment{
+	c := &Config{
 		database:     appwrite.NewDatabases(*adminClient),
 		userService:  user.NewUser(adminClient),
+		chart:        statistic.NewChartWithConfig(cfg),
+		counter:      statistic.NewCounterWithConfig(cfg),
+		coupons:      coupon.NewCouponWithConfig(cfg),
+		baseURL:      cfg.Application.GetBaseUrl(),
+		plans:        *cfg.Application.GetSubscriptionPlans(),
 		endpoint:     cfg.Appwrite.Endpoint,
 		projectID:    cfg.Appwrite.ProjectID,
 		databaseID:   cfg.Appwrite.DatabaseID,
 		collectionID: cfg.Appwrite.CollectionIDPayments,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return &payment{
+		database:     c.database,
+		userService:  c.userService,
+		chart:        c.chart,
+		counter:      c.counter,
+		coupons:      c.coupons,
+		invalidator:  c.invalidator,
+		baseURL:      c.baseURL,
+		plans:        c.plans,
+		endpoint:     c.endpoint,
+		projectID:    c.projectID,
+		databaseID:   c.databaseID,
+		collectionID: c.collectionID,
+	}
+}
+
+// WithChart sets the chart that the revenue of finished payments, and its reversal for
+// refunded and charged back payments, is written to. Without it no revenue is charted.
+func WithChart(chart statistic.Chart) Option {
+	return func(config *Config) {
+		config.chart = chart
+	}
+}
+
+// WithCounter sets the counter whose total_payments attribute counts finished
+// payments. It is only incremented for payments charted with WithChart.
+func WithCounter(counter statistic.Counter) Option {
+	return func(config *Config) {
+		config.counter = counter
+	}
+}
+
+// WithCoupons sets the coupon service that gets back the redemption of a payment that
+// failed, expired or was refunded. Without it coupon redemptions are never released.
+func WithCoupons(coupons coupon.Coupon) Option {
+	return func(config *Config) {
+		config.coupons = coupons
+	}
+}
+
+// Invalidator is told when the subscription of a user may have changed, so that a
+// cached entitlement is computed again. entitlement.Entitlement satisfies it.
+type Invalidator interface {
+	Invalidate(userID string)
+}
+
+// WithInvalidator sets who is told about users whose payments changed: on every
+// applied update, revocation, upgrade and gift redemption.
+func WithInvalidator(invalidator Invalidator) Option {
+	return func(config *Config) {
+		config.invalidator = invalidator
+	}
+}
+
+// WithBaseURL sets the base URL of the links sent to users, such as the renewal link
+// of SendReminders.
+func WithBaseURL(baseURL string) Option {
+	return func(config *Config) {
+		config.baseURL = baseURL
+	}
+}
+
+// WithSubscriptionPlans sets the plans gifts are redeemed and upgrades are priced
+// against. NewPayment uses config.NewSubscriptionPlans by default.
+func WithSubscriptionPlans(plans []config.SubscriptionPlan) Option {
+	return func(config *Config) {
+		config.plans = plans
+	}
 }
 
 func WithEndpoint(endpoint string) Option {
@@ -290,6 +445,12 @@ func (p *payment) WithQueryOrderBy(field string, ascending bool) func([]string)
 type Config struct {
 	database     *databases.Databases
 	userService  user.User
+	chart        statistic.Chart
+	counter      statistic.Counter
+	coupons      coupon.Coupon
+	invalidator  Invalidator
+	baseURL      string
+	plans        []config.SubscriptionPlan
 	endpoint     string
 	projectID    string
 	databaseID   string
@@ -298,6 +459,13 @@ type Config struct {
 
 type Option func(*Config)
 
+// invalidate tells the invalidator that the subscription of the user may have changed.
+func (p *payment) invalidate(userID string) {
+	if p.invalidator != nil && userID != "" {
+		p.invalidator.Invalidate(userID)
+	}
+}
+
 func (p *payment) getDatabases(secret string) *databases.Databases {
 	sessionClient := utils.NewSessionClient(secret, utils.WithProject(p.projectID), utils.WithEndpoint(p.endpoint))
 	return appwrite.NewDatabases(*sessionClient)
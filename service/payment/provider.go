@@ -0,0 +1,68 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"net/http"
+)
+
+// PaymentProvider is a payment processor subscriptions can be paid with.
+type PaymentProvider interface {
+	// Name is stored in the provider attribute of the payments it creates.
+	Name() string
+	// WebhookPath is the path the provider's webhook handler is usually mounted at.
+	WebhookPath() string
+	CreateCheckout(ctx context.Context, request *model.CheckoutRequest) (*model.Checkout, error)
+	// GetStatus looks up the current state of a payment by its provider payment ID.
+	GetStatus(ctx context.Context, paymentID string) (*model.PaymentUpdate, error)
+	// VerifyWebhook returns a nil update for deliveries without a payment status.
+	VerifyWebhook(r *http.Request, body []byte) (*model.PaymentUpdate, error)
+}
+
+// OrderLookup looks up payments without a payment ID; a nil update means nothing was paid.
+type OrderLookup interface {
+	GetOrderStatus(ctx context.Context, orderID, invoiceID string) (*model.PaymentUpdate, error)
+}
+
+// ErrWebhookSignature is returned by VerifyWebhook for unauthenticated deliveries.
+var ErrWebhookSignature = errors.New("invalid webhook signature")
+
+// Providers is a set of payment providers, in order of preference.
+type Providers struct {
+	providers []PaymentProvider
+}
+
+func NewProviders(providers ...PaymentProvider) *Providers {
+	return &Providers{providers: providers}
+}
+
+// Get returns the provider with the given name.
+func (p *Providers) Get(name string) (PaymentProvider, bool) {
+	for _, provider := range p.providers {
+		if provider.Name() == name {
+			return provider, true
+		}
+	}
+	return nil, false
+}
+
+// CreateCheckout creates the checkout with the first provider that succeeds.
+func (p *Providers) CreateCheckout(ctx context.Context, request *model.CheckoutRequest) (*model.Checkout, error) {
+	if len(p.providers) == 0 {
+		return nil, fmt.Errorf("no payment provider configured")
+	}
+	var errs []error
+	for _, provider := range p.providers {
+		checkout, err := provider.CreateCheckout(ctx, request)
+		if err == nil {
+			return checkout, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
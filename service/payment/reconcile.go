@@ -0,0 +1,214 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/appwrite/sdk-for-go/query"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReconcileReport summarizes a Reconcile run.
+type ReconcileReport struct {
+	Checked   int64 // payments examined
+	Updated   int64 // payments whose status was brought in line with the provider
+	Expired   int64 // abandoned payments marked as expired
+	Unchanged int64 // payments the provider still reports in the stored status
+	Failed    int64 // payments that could not be looked up or saved
+}
+
+// Reconcile catches up on lost IPNs. It pages through payments that are still in a
+// non-terminal status and older than the configured minimum age, fetches their current
+// status from the provider that processes them and applies it through the same path as
+// ApplyUpdate. Payments that are still unsettled after the abandon period are marked as
+// expired.
+// Example of usage:
+//
+//	providers := payment.NewProviders(nowPaymentsProvider, btcPayProvider)
+//	report, err := paymentService.Reconcile(ctx, providers,
+//		payment.WithReconcileMinAge(30*time.Minute),
+//		payment.WithReconcileConcurrency(4))
+func (p *payment) Reconcile(ctx context.Context, providers *Providers, opts ...ReconcileOption) (*ReconcileReport, error) {
+	if providers == nil {
+		return nil, fmt.Errorf("payment providers are required")
+	}
+	options := &reconcileOptions{
+		minAge:       time.Hour,
+		abandonAfter: 7 * 24 * time.Hour,
+		concurrency:  5,
+		limit:        100,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	// query.Equal only treats []interface{} as a list of alternatives.
+	var statuses []interface{}
+	for _, status := range model.NonTerminalStatuses() {
+		statuses = append(statuses, string(status))
+	}
+	now := time.Now().UTC()
+	cutoff := now.Add(-options.minAge).Format(time.RFC3339)
+
+	report := &ReconcileReport{}
+	semaphore := make(chan struct{}, options.concurrency)
+	var wg sync.WaitGroup
+	var cursor string
+	for {
+		if err := ctx.Err(); err != nil {
+			wg.Wait()
+			return report, err
+		}
+		queries := []string{
+			query.Equal("payment_status", statuses),
+			query.LessThan("$createdAt", cutoff),
+			query.Limit(options.limit),
+			query.OrderAsc("$createdAt"),
+		}
+		if cursor != "" {
+			queries = append(queries, query.CursorAfter(cursor))
+		}
+		response, err := p.database.ListDocuments(p.databaseID, p.collectionID, p.database.WithListDocumentsQueries(queries))
+		if err != nil {
+			wg.Wait()
+			return report, fmt.Errorf("could not list pending payments: %w", model.TranslateError(err))
+		}
+		if len(response.Documents) == 0 {
+			break
+		}
+		stalePayments, err := model.NewPayments(response)
+		if err != nil {
+			wg.Wait()
+			return report, fmt.Errorf("could not decode pending payments: %w", err)
+		}
+		for i := range stalePayments {
+			select {
+			case semaphore <- struct{}{}:
+			case <-ctx.Done():
+				wg.Wait()
+				return report, ctx.Err()
+			}
+			atomic.AddInt64(&report.Checked, 1)
+			wg.Add(1)
+			go func(stalePayment *model.Payment) {
+				defer wg.Done()
+				defer func() { <-semaphore }()
+				p.reconcileOne(ctx, providers, stalePayment, now.Add(-options.abandonAfter), report)
+			}(&stalePayments[i])
+		}
+		cursor = response.Documents[len(response.Documents)-1].Id
+	}
+	wg.Wait()
+	return report, nil
+}
+
+func (p *payment) reconcileOne(ctx context.Context, providers *Providers, stale *model.Payment, abandonedBefore time.Time, report *ReconcileReport) {
+	orderID := stale.Document.Id
+	createdAt, err := time.Parse(time.RFC3339, stale.Document.CreatedAt)
+	abandoned := err == nil && createdAt.Before(abandonedBefore)
+
+	update, err := fetchStatus(ctx, providers, stale)
+	if err != nil {
+		log.Printf("Reconcile: Could not fetch status of order %s, skipping: %v", orderID, err)
+		atomic.AddInt64(&report.Failed, 1)
+		return
+	}
+	if update == nil {
+		update = &model.PaymentUpdate{Status: stale.PaymentStatus}
+	}
+	update.OrderID = orderID
+
+	if update.Status != stale.PaymentStatus {
+		_, err := p.ApplyUpdate(update)
+		switch {
+		case errors.Is(err, model.ErrIllegalTransition):
+			log.Printf("Reconcile: Ignoring status of order %s: %v", orderID, err)
+			atomic.AddInt64(&report.Unchanged, 1)
+		case err != nil:
+			log.Printf("Reconcile: Could not update order %s: %v", orderID, err)
+			atomic.AddInt64(&report.Failed, 1)
+		default:
+			atomic.AddInt64(&report.Updated, 1)
+		}
+		return
+	}
+
+	if !abandoned || !update.Status.CanTransitionTo(model.StatusExpired) {
+		atomic.AddInt64(&report.Unchanged, 1)
+		return
+	}
+	update.Status = model.StatusExpired
+	update.Raw = nil
+	if _, err := p.ApplyUpdate(update); err != nil {
+		log.Printf("Reconcile: Could not expire order %s: %v", orderID, err)
+		atomic.AddInt64(&report.Failed, 1)
+		return
+	}
+	atomic.AddInt64(&report.Expired, 1)
+}
+
+// fetchStatus asks the provider of a payment for its current status. NowPayments
+// invoices have no payment ID until the buyer picks a coin, so they are looked up by
+// their invoice ID instead. A nil update means the provider knows of no payment.
+func fetchStatus(ctx context.Context, providers *Providers, stale *model.Payment) (*model.PaymentUpdate, error) {
+	if stale.PaymentID == "" && stale.InvoiceID == "" {
+		return nil, nil
+	}
+	provider, ok := providers.Get(stale.ProviderName())
+	if !ok {
+		return nil, fmt.Errorf("no provider %s", stale.ProviderName())
+	}
+	if stale.PaymentID != "" {
+		return provider.GetStatus(ctx, stale.PaymentID)
+	}
+	lookup, ok := provider.(OrderLookup)
+	if !ok {
+		return nil, fmt.Errorf("provider %s cannot look up invoice %s", provider.Name(), stale.InvoiceID)
+	}
+	return lookup.GetOrderStatus(ctx, stale.Document.Id, stale.InvoiceID)
+}
+
+type reconcileOptions struct {
+	minAge       time.Duration
+	abandonAfter time.Duration
+	concurrency  int
+	limit        int
+}
+
+type ReconcileOption func(*reconcileOptions)
+
+// WithReconcileMinAge skips payments created less than minAge ago, giving their IPNs
+// time to arrive.
+func WithReconcileMinAge(minAge time.Duration) ReconcileOption {
+	return func(o *reconcileOptions) {
+		o.minAge = minAge
+	}
+}
+
+// WithReconcileAbandonAfter sets the age after which an unsettled payment is expired.
+func WithReconcileAbandonAfter(abandonAfter time.Duration) ReconcileOption {
+	return func(o *reconcileOptions) {
+		o.abandonAfter = abandonAfter
+	}
+}
+
+// WithReconcileConcurrency bounds the number of status lookups running at once.
+func WithReconcileConcurrency(concurrency int) ReconcileOption {
+	return func(o *reconcileOptions) {
+		if concurrency > 0 {
+			o.concurrency = concurrency
+		}
+	}
+}
+
+// WithReconcileLimit sets the page size used when listing pending payments.
+func WithReconcileLimit(limit int) ReconcileOption {
+	return func(o *reconcileOptions) {
+		if limit > 0 {
+			o.limit = limit
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package payment_test
+
+import (
+	"context"
+	"errors"
+	"github.com/antidote-recognize0663/comics-galore-library/internal/appwritetest"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/antidote-recognize0663/comics-galore-library/service/nowpayments"
+	"github.com/antidote-recognize0663/comics-galore-library/service/nowpayments/nowpaymentstest"
+	"github.com/antidote-recognize0663/comics-galore-library/service/payment"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReconcile(t *testing.T) {
+	// IPNs are acknowledged and dropped, as if they were lost on the way.
+	lost := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer lost.Close()
+	fake := nowpaymentstest.NewServer(nowpaymentstest.WithIPNURL(lost.URL), nowpaymentstest.WithCredentials("shop@example.com", "password"))
+	defer fake.Close()
+	client := nowpayments.NewNowPayments(nowpayments.WithEndpoint(fake.URL()))
+	defer func() { _ = client.Close() }()
+	providers := payment.NewProviders(nowpayments.NewProvider("secret",
+		nowpayments.WithEndpoint(fake.URL()), nowpayments.WithCredentials("shop@example.com", "password")))
+
+	old := time.Now().UTC().AddDate(0, 0, -8).Format(time.RFC3339)
+	recent := time.Now().UTC().Add(-2 * time.Hour).Format(time.RFC3339)
+	testCases := []struct {
+		name           string
+		providerStatus model.PaymentStatusEnum
+		invoiceID      string
+		document       map[string]interface{}
+		expectStatus   model.PaymentStatusEnum
+		expectReport   payment.ReconcileReport
+	}{
+		{
+			name:           "Lost IPN",
+			providerStatus: model.StatusFinished,
+			document:       map[string]interface{}{"payment_status": "waiting", "$createdAt": recent},
+			expectStatus:   model.StatusFinished,
+			expectReport:   payment.ReconcileReport{Checked: 1, Updated: 1},
+		},
+		{
+			name:         "Still Waiting",
+			document:     map[string]interface{}{"payment_status": "waiting", "$createdAt": recent},
+			expectStatus: model.StatusWaiting,
+			expectReport: payment.ReconcileReport{Checked: 1, Unchanged: 1},
+		},
+		{
+			name:         "Abandoned",
+			document:     map[string]interface{}{"payment_status": "waiting", "$createdAt": old},
+			expectStatus: model.StatusExpired,
+			expectReport: payment.ReconcileReport{Checked: 1, Expired: 1},
+		},
+		{
+			name:         "Abandoned Invoice",
+			document:     map[string]interface{}{"payment_status": "waiting", "payment_id": "", "invoice_id": "4000000001", "$createdAt": old},
+			expectStatus: model.StatusExpired,
+			expectReport: payment.ReconcileReport{Checked: 1, Expired: 1},
+		},
+		{
+			// The IPN of the payment made on the invoice was lost.
+			name:           "Paid Invoice",
+			providerStatus: model.StatusFinished,
+			invoiceID:      "4000000002",
+			document:       map[string]interface{}{"payment_status": "waiting", "payment_id": "", "invoice_id": "4000000002", "$createdAt": old},
+			expectStatus:   model.StatusFinished,
+			expectReport:   payment.ReconcileReport{Checked: 1, Updated: 1},
+		},
+		{
+			name:         "Too Recent",
+			document:     map[string]interface{}{"payment_status": "waiting"},
+			expectStatus: model.StatusWaiting,
+			expectReport: payment.ReconcileReport{},
+		},
+		{
+			name:         "Settled",
+			document:     map[string]interface{}{"payment_status": "finished", "$createdAt": old},
+			expectStatus: model.StatusFinished,
+			expectReport: payment.ReconcileReport{},
+		},
+		{
+			name:         "Unknown Payment",
+			document:     map[string]interface{}{"payment_status": "waiting", "payment_id": "999999", "$createdAt": recent},
+			expectStatus: model.StatusWaiting,
+			expectReport: payment.ReconcileReport{Checked: 1, Failed: 1},
+		},
+		{
+			name:         "Unknown Provider",
+			document:     map[string]interface{}{"payment_status": "waiting", "provider": "paypal", "$createdAt": recent},
+			expectStatus: model.StatusWaiting,
+			expectReport: payment.ReconcileReport{Checked: 1, Failed: 1},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			created, err := client.CreateNowPayment(ctx, 0, "btc", 0, "https://example.com")
+			if err != nil {
+				t.Fatalf("CreateNowPayment failed: %v", err)
+			}
+			if tc.invoiceID != "" {
+				if err := fake.SetInvoiceID(created.PaymentID, tc.invoiceID); err != nil {
+					t.Fatalf("SetInvoiceID failed: %v", err)
+				}
+			}
+			if tc.providerStatus != "" {
+				if err := fake.SetStatus(created.PaymentID, tc.providerStatus); err != nil {
+					t.Fatalf("SetStatus failed: %v", err)
+				}
+			}
+			document := map[string]interface{}{"order_id": created.OrderID, "payment_id": created.PaymentID}
+			for key, value := range tc.document {
+				document[key] = value
+			}
+			server := appwritetest.NewServer()
+			defer server.Close()
+			server.Put("db", "payments", created.OrderID, document)
+			service := payment.NewPayment(server.Client(), nil, payment.WithDatabaseID("db"), payment.WithCollectionID("payments"))
+
+			report, err := service.Reconcile(ctx, providers, payment.WithReconcileMinAge(time.Hour))
+			if err != nil {
+				t.Fatalf("Reconcile failed: %v", err)
+			}
+			if *report != tc.expectReport {
+				t.Errorf("Expected report %+v, got %+v", tc.expectReport, *report)
+			}
+			stored, _ := server.Document("db", "payments", created.OrderID)
+			if status := model.PaymentStatusEnum(stored["payment_status"].(string)); status != tc.expectStatus {
+				t.Errorf("Expected status %s, got %s", tc.expectStatus, status)
+			}
+		})
+	}
+}
+
+func TestReconcilePaging(t *testing.T) {
+	server := appwritetest.NewServer()
+	defer server.Close()
+	old := time.Now().UTC().AddDate(0, 0, -8).Format(time.RFC3339)
+	for _, orderID := range []string{"order-1", "order-2", "order-3", "order-4", "order-5"} {
+		server.Put("db", "payments", orderID, map[string]interface{}{"order_id": orderID, "payment_status": "waiting", "$createdAt": old})
+	}
+	service := payment.NewPayment(server.Client(), nil, payment.WithDatabaseID("db"), payment.WithCollectionID("payments"))
+
+	// Expired payments drop out of the listing while it is paged through.
+	report, err := service.Reconcile(context.Background(), payment.NewProviders(),
+		payment.WithReconcileLimit(2), payment.WithReconcileConcurrency(1))
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if report.Checked != 5 || report.Expired != 5 {
+		t.Errorf("Expected all 5 payments to be expired, got %+v", *report)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := service.Reconcile(ctx, payment.NewProviders()); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled context to stop Reconcile, got %v", err)
+	}
+}
@@ -0,0 +1,318 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/appwrite/sdk-for-go/query"
+	"log"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ReminderWindow is a point in the life of a subscription at which its owner is
+// reminded to renew. A payment is due for the reminder once its expiry is at most
+// Before away; a Before of 0 is due once the subscription has expired. Subject and
+// Body are text/template sources executed with the Reminder.
+type ReminderWindow struct {
+	Name    string
+	Before  time.Duration
+	Subject string
+	Body    string
+}
+
+// DefaultReminderWindows remind 7 days and 1 day before expiry, and on the day the
+// subscription expires.
+func DefaultReminderWindows() []ReminderWindow {
+	return []ReminderWindow{
+		{
+			Name:    "7-days",
+			Before:  7 * 24 * time.Hour,
+			Subject: "Your {{.PlanName}} expires in a week",
+			Body:    "Your {{.PlanName}} ends on {{.ExpiresAt.Format \"January 2, 2006\"}}. Renew now to keep reading without interruption: {{.RenewalURL}}",
+		},
+		{
+			Name:    "1-day",
+			Before:  24 * time.Hour,
+			Subject: "Your {{.PlanName}} expires tomorrow",
+			Body:    "Your {{.PlanName}} ends on {{.ExpiresAt.Format \"January 2, 2006\"}}. Renew today to keep your access: {{.RenewalURL}}",
+		},
+		{
+			Name:    "expired",
+			Subject: "Your {{.PlanName}} has expired",
+			Body:    "Your {{.PlanName}} ended on {{.ExpiresAt.Format \"January 2, 2006\"}}. Renew at any time to pick up where you left off: {{.RenewalURL}}",
+		},
+	}
+}
+
+// Reminder is a rendered renewal reminder.
+type Reminder struct {
+	Window     string
+	UserID     string
+	OrderID    string
+	PlanID     string
+	PlanName   string
+	ExpiresAt  time.Time
+	RenewalURL string
+	Subject    string
+	Body       string
+}
+
+// Notifier delivers reminders, by email or any other channel.
+type Notifier interface {
+	Notify(ctx context.Context, reminder *Reminder) error
+}
+
+// NotifierFunc adapts a function to a Notifier.
+type NotifierFunc func(ctx context.Context, reminder *Reminder) error
+
+func (f NotifierFunc) Notify(ctx context.Context, reminder *Reminder) error {
+	return f(ctx, reminder)
+}
+
+// ReminderReport summarizes a SendReminders run.
+type ReminderReport struct {
+	Checked int64 // payments examined
+	Sent    int64 // reminders delivered
+	Skipped int64 // payments already reminded, or renewed by a later payment
+	Failed  int64 // reminders that could not be rendered, recorded or delivered
+}
+
+// SendReminders reminds the owners of finished payments that expire soon, or expired
+// within the last day, to renew. Each payment gets the reminder of the most urgent
+// window it falls into, at most once per window: the windows sent are recorded in the
+// payment's reminders_sent attribute before the reminder is delivered. Users whose
+// subscription was already extended by a later payment are not reminded.
+// Example of usage:
+//
+//	report, err := paymentService.SendReminders(ctx, payment.NotifierFunc(
+//		func(ctx context.Context, r *payment.Reminder) error {
+//			return mailer.Send(ctx, r.UserID, r.Subject, r.Body)
+//		}))
+func (p *payment) SendReminders(ctx context.Context, notifier Notifier, opts ...ReminderOption) (*ReminderReport, error) {
+	if notifier == nil {
+		return nil, fmt.Errorf("a notifier is required")
+	}
+	options := &reminderOptions{
+		windows:     DefaultReminderWindows(),
+		baseURL:     p.baseURL,
+		renewalPath: "/subscribe",
+		limit:       100,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if len(options.windows) == 0 {
+		return nil, fmt.Errorf("at least one reminder window is required")
+	}
+	windows := slices.Clone(options.windows)
+	sort.Slice(windows, func(i, j int) bool { return windows[i].Before < windows[j].Before })
+	templates, err := parseReminderTemplates(windows)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	from := now.Add(-24 * time.Hour).Format(time.RFC3339)
+	until := now.Add(windows[len(windows)-1].Before).Format(time.RFC3339)
+	report := &ReminderReport{}
+	var cursor string
+	for {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		queries := []string{
+			query.Equal("payment_status", string(model.StatusFinished)),
+			query.GreaterThan("expires_at", from),
+			query.LessThanEqual("expires_at", until),
+			query.Limit(options.limit),
+			query.OrderAsc("$createdAt"),
+		}
+		if cursor != "" {
+			queries = append(queries, query.CursorAfter(cursor))
+		}
+		response, err := p.database.ListDocuments(p.databaseID, p.collectionID, p.database.WithListDocumentsQueries(queries))
+		if err != nil {
+			return report, fmt.Errorf("could not list expiring payments: %w", model.TranslateError(err))
+		}
+		if len(response.Documents) == 0 {
+			break
+		}
+		expiringPayments, err := model.NewPayments(response)
+		if err != nil {
+			return report, fmt.Errorf("could not decode expiring payments: %w", err)
+		}
+		for i := range expiringPayments {
+			report.Checked++
+			p.remindOne(ctx, notifier, &expiringPayments[i], windows, templates, options, now, report)
+		}
+		cursor = response.Documents[len(response.Documents)-1].Id
+	}
+	return report, nil
+}
+
+func (p *payment) remindOne(ctx context.Context, notifier Notifier, expiring *model.Payment, windows []ReminderWindow, templates []reminderTemplate, options *reminderOptions, now time.Time, report *ReminderReport) {
+	orderID := expiring.Document.Id
+	expiresAt, err := time.Parse(time.RFC3339, expiring.ExpiresAt)
+	if err != nil || expiring.UserID == "" {
+		log.Printf("SendReminders: Skipping order %s without user or expiry", orderID)
+		report.Failed++
+		return
+	}
+	index := dueWindow(windows, expiresAt, now)
+	if index < 0 || slices.Contains(expiring.RemindersSent, windows[index].Name) {
+		report.Skipped++
+		return
+	}
+	window := windows[index]
+	activeUntil, err := p.GetActiveExpiry(expiring.UserID)
+	if err != nil {
+		log.Printf("SendReminders: Could not check renewal of user %s: %v", expiring.UserID, err)
+		report.Failed++
+		return
+	}
+	if activeUntil.After(expiresAt) {
+		report.Skipped++
+		return
+	}
+
+	reminder := &Reminder{
+		Window:     window.Name,
+		UserID:     expiring.UserID,
+		OrderID:    orderID,
+		PlanID:     expiring.PlanID,
+		PlanName:   planLabel(expiring.PaymentData),
+		ExpiresAt:  expiresAt,
+		RenewalURL: renewalURL(options.baseURL, options.renewalPath, expiring.PlanID),
+	}
+	if err := templates[index].render(reminder); err != nil {
+		log.Printf("SendReminders: Could not render %s reminder for order %s: %v", window.Name, orderID, err)
+		report.Failed++
+		return
+	}
+
+	sent := append(slices.Clone(expiring.RemindersSent), window.Name)
+	if err := p.setRemindersSent(orderID, sent); err != nil {
+		log.Printf("SendReminders: Could not record %s reminder for order %s: %v", window.Name, orderID, err)
+		report.Failed++
+		return
+	}
+	if err := notifier.Notify(ctx, reminder); err != nil {
+		log.Printf("SendReminders: Could not deliver %s reminder for order %s: %v", window.Name, orderID, err)
+		if err := p.setRemindersSent(orderID, expiring.RemindersSent); err != nil {
+			log.Printf("SendReminders: Could not roll back %s reminder for order %s: %v", window.Name, orderID, err)
+		}
+		report.Failed++
+		return
+	}
+	report.Sent++
+}
+
+// dueWindow returns the index of the most urgent window that a subscription expiring
+// at expiresAt is due for, or -1.
+func dueWindow(windows []ReminderWindow, expiresAt, now time.Time) int {
+	index := -1
+	for i, window := range windows {
+		if expiresAt.Sub(now) <= window.Before && (index < 0 || window.Before < windows[index].Before) {
+			index = i
+		}
+	}
+	return index
+}
+
+func (p *payment) setRemindersSent(orderID string, sent []string) error {
+	if sent == nil {
+		sent = []string{}
+	}
+	_, err := p.database.UpdateDocument(p.databaseID, p.collectionID, orderID,
+		p.database.WithUpdateDocumentData(map[string]interface{}{"reminders_sent": sent}))
+	return model.TranslateError(err)
+}
+
+func renewalURL(baseURL, renewalPath, planID string) string {
+	link := strings.TrimSuffix(baseURL, "/") + renewalPath
+	if planID != "" {
+		link += "?plan=" + url.QueryEscape(planID)
+	}
+	return link
+}
+
+type reminderTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+func parseReminderTemplates(windows []ReminderWindow) ([]reminderTemplate, error) {
+	templates := make([]reminderTemplate, len(windows))
+	for i, window := range windows {
+		subject, err := template.New(window.Name + "-subject").Parse(window.Subject)
+		if err != nil {
+			return nil, fmt.Errorf("invalid subject template for reminder %s: %w", window.Name, err)
+		}
+		body, err := template.New(window.Name + "-body").Parse(window.Body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid body template for reminder %s: %w", window.Name, err)
+		}
+		templates[i] = reminderTemplate{subject: subject, body: body}
+	}
+	return templates, nil
+}
+
+func (t reminderTemplate) render(reminder *Reminder) error {
+	var subject, body bytes.Buffer
+	if err := t.subject.Execute(&subject, reminder); err != nil {
+		return err
+	}
+	if err := t.body.Execute(&body, reminder); err != nil {
+		return err
+	}
+	reminder.Subject = subject.String()
+	reminder.Body = body.String()
+	return nil
+}
+
+type reminderOptions struct {
+	windows     []ReminderWindow
+	baseURL     string
+	renewalPath string
+	limit       int
+}
+
+type ReminderOption func(*reminderOptions)
+
+// WithReminderWindows replaces DefaultReminderWindows.
+func WithReminderWindows(windows ...ReminderWindow) ReminderOption {
+	return func(o *reminderOptions) {
+		o.windows = windows
+	}
+}
+
+// WithReminderBaseURL overrides the base URL of renewal links set with WithBaseURL.
+// NewPaymentWithConfig uses ApplicationConfig.GetBaseUrl.
+func WithReminderBaseURL(baseURL string) ReminderOption {
+	return func(o *reminderOptions) {
+		o.baseURL = baseURL
+	}
+}
+
+// WithReminderRenewalPath sets the path of renewal links, "/subscribe" by default. The
+// plan ID is appended as the plan query parameter.
+func WithReminderRenewalPath(path string) ReminderOption {
+	return func(o *reminderOptions) {
+		o.renewalPath = path
+	}
+}
+
+// WithReminderLimit sets the page size used when listing expiring payments.
+func WithReminderLimit(limit int) ReminderOption {
+	return func(o *reminderOptions) {
+		if limit > 0 {
+			o.limit = limit
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"github.com/antidote-recognize0663/comics-galore-library/internal/appwritetest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDueWindow(t *testing.T) {
+	windows := DefaultReminderWindows()
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name      string
+		expiresAt time.Time
+		expected  string
+	}{
+		{name: "Far From Expiry", expiresAt: now.Add(30 * 24 * time.Hour)},
+		{name: "Within A Week", expiresAt: now.Add(6 * 24 * time.Hour), expected: "7-days"},
+		{name: "Exactly A Week", expiresAt: now.Add(7 * 24 * time.Hour), expected: "7-days"},
+		{name: "Within A Day", expiresAt: now.Add(3 * time.Hour), expected: "1-day"},
+		{name: "Expired", expiresAt: now.Add(-time.Hour), expected: "expired"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			index := dueWindow(windows, tc.expiresAt, now)
+			var name string
+			if index >= 0 {
+				name = windows[index].Name
+			}
+			if name != tc.expected {
+				t.Errorf("Expected window %q, got %q", tc.expected, name)
+			}
+		})
+	}
+}
+
+func TestRenderReminder(t *testing.T) {
+	templates, err := parseReminderTemplates(DefaultReminderWindows())
+	if err != nil {
+		t.Fatalf("parseReminderTemplates failed: %v", err)
+	}
+	reminder := &Reminder{
+		PlanName:   "Monthly Plan",
+		ExpiresAt:  time.Date(2025, 3, 8, 0, 0, 0, 0, time.UTC),
+		RenewalURL: renewalURL("https://comics-galore.co/", "/subscribe", "1"),
+	}
+	if err := templates[0].render(reminder); err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if reminder.Subject != "Your Monthly Plan expires in a week" {
+		t.Errorf("Unexpected subject: %q", reminder.Subject)
+	}
+	if !strings.Contains(reminder.Body, "March 8, 2025") || !strings.HasSuffix(reminder.Body, "https://comics-galore.co/subscribe?plan=1") {
+		t.Errorf("Unexpected body: %q", reminder.Body)
+	}
+	if _, err := parseReminderTemplates([]ReminderWindow{{Name: "broken", Subject: "{{.PlanName"}}); err == nil {
+		t.Errorf("Expected an invalid template to be rejected")
+	}
+}
+
+func TestSendReminders(t *testing.T) {
+	server := appwritetest.NewServer()
+	defer server.Close()
+	now := time.Now().UTC()
+	expiring := func(userID string, expiresAt time.Time, sent ...string) map[string]interface{} {
+		return map[string]interface{}{
+			"user_id": userID, "plan_id": "1", "payment_status": "finished", "expired": false,
+			"expires_at": expiresAt.Format(time.RFC3339), "reminders_sent": sent,
+		}
+	}
+	server.Put(testDatabaseID, testCollectionID, "due", expiring("user-1", now.Add(3*24*time.Hour)))
+	server.Put(testDatabaseID, testCollectionID, "reminded", expiring("user-2", now.Add(3*24*time.Hour), "7-days"))
+	server.Put(testDatabaseID, testCollectionID, "renewed", expiring("user-3", now.Add(3*24*time.Hour)))
+	server.Put(testDatabaseID, testCollectionID, "renewal", expiring("user-3", now.AddDate(0, 1, 3)))
+	server.Put(testDatabaseID, testCollectionID, "undeliverable", expiring("user-4", now.Add(12*time.Hour)))
+	service := newTestPayment(server, WithBaseURL("https://comics-galore.co"))
+
+	var delivered []*Reminder
+	notifier := NotifierFunc(func(ctx context.Context, reminder *Reminder) error {
+		if reminder.UserID == "user-4" {
+			return errors.New("mailbox unavailable")
+		}
+		delivered = append(delivered, reminder)
+		return nil
+	})
+	report, err := service.SendReminders(context.Background(), notifier, WithReminderLimit(2))
+	if err != nil {
+		t.Fatalf("SendReminders failed: %v", err)
+	}
+	// The renewal expires after every window, so it is not listed at all.
+	expected := ReminderReport{Checked: 4, Sent: 1, Skipped: 2, Failed: 1}
+	if *report != expected {
+		t.Errorf("Expected report %+v, got %+v", expected, *report)
+	}
+	if len(delivered) != 1 || delivered[0].OrderID != "due" || delivered[0].Window != "7-days" {
+		t.Fatalf("Expected the 7-days reminder for order due, got %+v", delivered)
+	}
+	if delivered[0].RenewalURL != "https://comics-galore.co/subscribe?plan=1" {
+		t.Errorf("Unexpected renewal URL %q", delivered[0].RenewalURL)
+	}
+	for orderID, expectSent := range map[string][]string{"due": {"7-days"}, "renewed": nil, "undeliverable": nil} {
+		stored, _ := server.Document(testDatabaseID, testCollectionID, orderID)
+		var sent []string
+		if values, ok := stored["reminders_sent"].([]interface{}); ok {
+			for _, value := range values {
+				sent = append(sent, value.(string))
+			}
+		}
+		if !slices.Equal(sent, expectSent) {
+			t.Errorf("Expected reminders_sent %v on order %s, got %v", expectSent, orderID, sent)
+		}
+	}
+
+	delivered = nil
+	report, err = service.SendReminders(context.Background(), notifier)
+	if err != nil {
+		t.Fatalf("SendReminders failed: %v", err)
+	}
+	if len(delivered) != 0 || report.Sent != 0 || report.Failed != 1 {
+		t.Errorf("Expected no reminder to be sent again, got %+v and %+v", delivered, *report)
+	}
+}
@@ -0,0 +1,70 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/antidote-recognize0663/comics-galore-library/service/statistic"
+	"net/http"
+)
+
+const totalPaymentsAttribute = "total_payments"
+
+// recordRevenue counts a finished payment in total_payments and writes its revenue
+// chart point. The point is stored under an ID derived from the payment ID, so a
+// redelivered IPN runs into a conflict. The counter is incremented before the point is
+// written and decremented again when the point already exists or cannot be written, so
+// a payment is counted exactly when its point exists and a failure is returned for the
+// IPN to be retried.
+func (p *payment) recordRevenue(finished *model.Payment) error {
+	if p.chart == nil || finished.PriceAmount == 0 {
+		return nil
+	}
+	if p.counter != nil {
+		if _, err := p.counter.Increment(totalPaymentsAttribute, 1); err != nil {
+			return fmt.Errorf("could not count finished payment %s in %s: %w", finished.OrderID, totalPaymentsAttribute, err)
+		}
+	}
+	created, err := p.addRevenuePoint(finished, finished.PriceAmount, "revenue-")
+	if created || p.counter == nil {
+		return err
+	}
+	if _, decrementErr := p.counter.Decrement(totalPaymentsAttribute, 1); decrementErr != nil {
+		return errors.Join(err, fmt.Errorf("could not uncount payment %s in %s: %w", finished.OrderID, totalPaymentsAttribute, decrementErr))
+	}
+	return err
+}
+
+// addRevenuePoint writes a revenue chart point for the payment under the document ID
+// prefix followed by the payment ID. It reports false when the point already exists.
+func (p *payment) addRevenuePoint(payment *model.Payment, value float64, prefix string) (bool, error) {
+	data, err := model.NewChartData(value, planLabel(payment.PaymentData), payment.UserID, string(model.CollectionRevenue))
+	if err != nil {
+		return false, err
+	}
+	data.Currency = payment.PriceCurrency
+	_, err = p.chart.AddData(data, statistic.WithChartDocumentID(prefix+chartKey(payment.PaymentData)))
+	if err != nil {
+		if isStatus(err, http.StatusConflict) {
+			return false, nil
+		}
+		return false, fmt.Errorf("could not chart revenue of payment %s: %w", payment.OrderID, err)
+	}
+	return true, nil
+}
+
+// chartKey identifies the payment in chart document IDs: its NowPayments payment ID,
+// or its order ID while that is not known yet.
+func chartKey(data *model.PaymentData) string {
+	if data.PaymentID != "" {
+		return data.PaymentID
+	}
+	return data.OrderID
+}
+
+func planLabel(data *model.PaymentData) string {
+	if data.OrderDescription != "" {
+		return data.OrderDescription
+	}
+	return data.PlanID
+}
@@ -0,0 +1,101 @@
+package payment
+
+import (
+	"errors"
+	"github.com/antidote-recognize0663/comics-galore-library/internal/appwritetest"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/antidote-recognize0663/comics-galore-library/service/statistic"
+	"testing"
+)
+
+// failingChart stands in for a chart collection that cannot be written to.
+type failingChart struct {
+	err error
+}
+
+func (c *failingChart) GetList(int, int, ...func([]string) []string) (*model.ChartList, error) {
+	return nil, c.err
+}
+
+func (c *failingChart) AddData(*model.ChartData, ...statistic.DocumentOption) (*model.Chart, error) {
+	return nil, c.err
+}
+
+type stubCounter struct {
+	value int64
+	err   error
+}
+
+func (c *stubCounter) Increment(_ string, value ...int64) (int64, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	c.value += value[0]
+	return c.value, nil
+}
+
+func (c *stubCounter) Decrement(_ string, value ...int64) (int64, error) {
+	c.value -= value[0]
+	return c.value, nil
+}
+
+func (c *stubCounter) GetValue(string) (int64, error) {
+	return c.value, nil
+}
+
+func TestRecordRevenue(t *testing.T) {
+	testCases := []struct {
+		name         string
+		existing     bool
+		deliveries   int
+		chartErr     error
+		counterErr   error
+		expectErr    bool
+		expectPoints int
+		expectCount  int64
+	}{
+		{name: "Existing Payment", existing: true, deliveries: 1, expectPoints: 1, expectCount: 1},
+		{name: "Created From Update", deliveries: 1, expectPoints: 1, expectCount: 1},
+		{name: "Redelivered", existing: true, deliveries: 3, expectPoints: 1, expectCount: 1},
+		{name: "Counter Failure", existing: true, deliveries: 1, counterErr: errors.New("unavailable"), expectErr: true},
+		{name: "Chart Failure", existing: true, deliveries: 1, chartErr: errors.New("unavailable"), expectErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := appwritetest.NewServer()
+			defer server.Close()
+			if tc.existing {
+				server.Put(testDatabaseID, testCollectionID, "order-1", map[string]interface{}{
+					"order_id": "order-1", "payment_status": "sending", "price_amount": 9.99,
+				})
+			}
+			var chart statistic.Chart = statistic.NewChart(server.Client(),
+				statistic.WithDatabaseID(testDatabaseID), statistic.WithCollectionID("charts"))
+			if tc.chartErr != nil {
+				chart = &failingChart{err: tc.chartErr}
+			}
+			counter := &stubCounter{err: tc.counterErr}
+			service := newTestPayment(server, WithChart(chart), WithCounter(counter))
+
+			var err error
+			for i := 0; i < tc.deliveries; i++ {
+				_, err = service.ApplyUpdate(&model.PaymentUpdate{
+					OrderID: "order-1", PaymentID: "42", Status: model.StatusFinished, PriceAmount: 9.99,
+				})
+				// Redeliveries are reported, but still run the revenue hook again.
+				if errors.Is(err, model.ErrDuplicateUpdate) {
+					err = nil
+				}
+			}
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("Expected error %v, got %v", tc.expectErr, err)
+			}
+			if points := len(server.Documents(testDatabaseID, "charts")); points != tc.expectPoints {
+				t.Errorf("Expected %d revenue points, got %d", tc.expectPoints, points)
+			}
+			if counter.value != tc.expectCount {
+				t.Errorf("Expected %s to be %d, got %d", totalPaymentsAttribute, tc.expectCount, counter.value)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package payment
+
+import (
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"log"
+	"time"
+)
+
+const subscriberLabel = "subscriber"
+
+// revokesAccess reports whether moving a payment to status takes back the access it
+// granted: a refund or a chargeback of a payment that was finished. A redelivered IPN
+// for a payment that is already refunded or failed revokes again, so that a revocation
+// interrupted by an error is completed when NowPayments retries the IPN.
+func revokesAccess(current *model.PaymentData, status model.PaymentStatusEnum) bool {
+	if status != model.StatusRefunded && status != model.StatusFailed {
+		return false
+	}
+	return current.WasFinished()
+}
+
+// revokeAccess is called once a refunded or charged back payment has been marked as
+// expired. The user's entitlement is recomputed from their remaining finished payments,
+// the subscriber label is removed when none is left, and the revenue of the payment is
+// reversed on the revenue chart. Each step is idempotent.
+func (p *payment) revokeAccess(revoked *model.Payment) error {
+	if revoked.UserID == "" {
+		log.Printf("Payment %s was %s but has no user, nothing to revoke", revoked.OrderID, revoked.PaymentStatus)
+		return nil
+	}
+	defer p.invalidate(revoked.UserID)
+	activeUntil, err := p.GetActiveExpiry(revoked.UserID)
+	if err != nil {
+		return fmt.Errorf("could not recompute entitlement of user %s: %w", revoked.UserID, err)
+	}
+	if activeUntil.IsZero() {
+		if _, err := p.userService.RemoveLabel(revoked.UserID, subscriberLabel); err != nil {
+			return fmt.Errorf("could not revoke access of user %s: %w", revoked.UserID, err)
+		}
+		log.Printf("Payment %s was %s, access of user %s revoked", revoked.OrderID, revoked.PaymentStatus, revoked.UserID)
+	} else {
+		log.Printf("Payment %s was %s, user %s stays subscribed until %s", revoked.OrderID, revoked.PaymentStatus, revoked.UserID, activeUntil.Format(time.RFC3339))
+	}
+	return p.reverseRevenue(revoked)
+}
+
+// reverseRevenue writes a negative revenue chart point for the payment, at most once.
+func (p *payment) reverseRevenue(revoked *model.Payment) error {
+	if p.chart == nil || revoked.PriceAmount == 0 {
+		return nil
+	}
+	_, err := p.addRevenuePoint(revoked, -revoked.PriceAmount, "reversal-")
+	return err
+}
@@ -0,0 +1,270 @@
+package payment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/appwrite/sdk-for-go/client"
+	"github.com/appwrite/sdk-for-go/query"
+	"net/http"
+)
+
+// maxUpdateAttempts is how often applyUpdate reads and writes a payment that is
+// changed concurrently before it gives up.
+const maxUpdateAttempts = 3
+
+// applyUpdate applies a provider's status update to the payment identified by its order
+// ID, see SaveOrUpdate. The status history is read, appended to and written back, so
+// the write is conditional on the payment not having changed since it was read;
+// otherwise the update is applied again to the payment as it is now.
+func (p *payment) applyUpdate(data *model.PaymentUpdate, createMissing bool) (*model.Payment, error) {
+	if err := data.Status.Validate(); err != nil {
+		return nil, fmt.Errorf("update for order %s: %w", data.OrderID, err)
+	}
+	raw := []byte(data.Raw)
+	if len(raw) == 0 {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return nil, fmt.Errorf("could not encode update for order %s: %w", data.OrderID, err)
+		}
+		raw = encoded
+	}
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		updated, err := p.tryUpdate(data, raw, createMissing)
+		if errors.Is(err, errPaymentChanged) {
+			continue
+		}
+		return updated, err
+	}
+	return nil, fmt.Errorf("could not update payment %s: %w", data.OrderID, errPaymentChanged)
+}
+
+// errPaymentChanged is returned by tryUpdate when the payment was written by someone
+// else between reading and updating it. It wraps model.ErrConflict.
+var errPaymentChanged = fmt.Errorf("%w: payment was changed concurrently", model.ErrConflict)
+
+func (p *payment) tryUpdate(data *model.PaymentUpdate, raw []byte, createMissing bool) (*model.Payment, error) {
+	var current *model.Payment
+	document, err := p.database.GetDocument(p.databaseID, p.collectionID, data.OrderID)
+	switch {
+	case err == nil:
+		current, err = model.NewPayment(document)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode payment %s: %w", data.OrderID, err)
+		}
+	case isNotFound(err) && createMissing:
+		// The payment is created from the update and goes through the same checks
+		// and hooks as one that existed, starting from an empty status.
+		current = &model.Payment{PaymentData: &model.PaymentData{}}
+	default:
+		return nil, fmt.Errorf("could not get payment %s: %w", data.OrderID, model.TranslateError(err))
+	}
+	if current.HasApplied(data.Status, raw) {
+		// Rerun the idempotent hooks in case they failed on the first delivery.
+		if err := p.afterUpdate(current, revokesAccess(current.PaymentData, data.Status), false); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("update for order %s: %w", data.OrderID, model.ErrDuplicateUpdate)
+	}
+	attributes := updateAttributes(data)
+	if data.Status == model.StatusFinished && current.PaymentStatus != model.StatusFinished {
+		expiresAt, err := p.renewedExpiry(current)
+		if err != nil {
+			return nil, err
+		}
+		if expiresAt != "" {
+			attributes["expires_at"] = expiresAt
+		}
+	}
+	revoke := revokesAccess(current.PaymentData, data.Status)
+	release := releasesCoupon(current.PaymentData, data.Status)
+	if revoke {
+		attributes["expired"] = true
+	}
+	if current.PaymentStatus != data.Status {
+		if current.PaymentStatus != "" && !current.PaymentStatus.CanTransitionTo(data.Status) {
+			return nil, &model.TransitionError{From: current.PaymentStatus, To: data.Status}
+		}
+		entry, err := model.NewStatusTransition(current.PaymentStatus, data.Status, raw)
+		if err != nil {
+			return nil, err
+		}
+		attributes["status_history"] = append(current.StatusHistory, entry)
+	}
+	var updatedPayment *model.Payment
+	if current.Document == nil {
+		updatedPayment, err = p.createMissing(data.OrderID, attributes)
+	} else {
+		updatedPayment, err = p.updateUnchanged(current, attributes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := p.afterUpdate(updatedPayment, revoke, release); err != nil {
+		return nil, err
+	}
+	return updatedPayment, nil
+}
+
+// afterUpdate runs the side effects of a payment that was just created or updated:
+// taking back access and giving back the coupon use as decided before the write, and
+// applying the upgrade and charting the revenue of a finished payment. The hooks are
+// idempotent where a retried IPN can run them again. The user's entitlement is
+// invalidated in any case, since the payment itself was written.
+func (p *payment) afterUpdate(updated *model.Payment, revoke, release bool) error {
+	defer p.invalidate(updated.UserID)
+	if revoke {
+		if err := p.revokeAccess(updated); err != nil {
+			return err
+		}
+	}
+	if release {
+		if err := p.releaseCoupon(updated); err != nil {
+			return err
+		}
+	}
+	if updated.PaymentStatus == model.StatusFinished {
+		if len(updated.UpgradeOf) > 0 {
+			if err := p.applyUpgrade(updated); err != nil {
+				return err
+			}
+		}
+		if err := p.recordRevenue(updated); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createMissing stores a payment that an update arrived for before it was created. A
+// payment created concurrently returns errPaymentChanged.
+func (p *payment) createMissing(orderID string, attributes map[string]interface{}) (*model.Payment, error) {
+	created, err := p.database.CreateDocument(p.databaseID, p.collectionID, orderID, attributes)
+	if err != nil {
+		if isStatus(err, http.StatusConflict) {
+			return nil, errPaymentChanged
+		}
+		return nil, fmt.Errorf("could not create payment %s: %w", orderID, model.TranslateError(err))
+	}
+	return model.NewPayment(created)
+}
+
+// updateUnchanged writes the attributes to the payment unless it was updated since
+// current was read, in which case it returns errPaymentChanged. The condition is a bulk
+// update filtered on $updatedAt, which needs Appwrite 1.7 or later and a payments
+// collection without relationship attributes. $updatedAt has millisecond precision, so
+// two writes within the same millisecond are not told apart.
+func (p *payment) updateUnchanged(current *model.Payment, attributes map[string]interface{}) (*model.Payment, error) {
+	response, err := p.database.UpdateDocuments(p.databaseID, p.collectionID,
+		p.database.WithUpdateDocumentsData(attributes),
+		p.database.WithUpdateDocumentsQueries([]string{
+			query.Equal("$id", current.Document.Id),
+			query.Equal("$updatedAt", current.Document.UpdatedAt),
+		}))
+	if err != nil {
+		return nil, fmt.Errorf("could not update payment %s: %w", current.Document.Id, model.TranslateError(err))
+	}
+	updated, err := model.NewPayments(response)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode payment %s: %w", current.Document.Id, err)
+	}
+	if len(updated) == 0 {
+		return nil, errPaymentChanged
+	}
+	return &updated[0], nil
+}
+
+// renewedExpiry recomputes the expiry of a payment that is about to finish, since the
+// user's active subscription may have changed since the payment was created. The
+// plan's period starts at the end of that subscription, or now. Upgrades are given
+// their expiry by applyUpgrade and unredeemed gifts by RedeemGift, so for those, and
+// for payments without a known plan, it returns an empty string.
+func (p *payment) renewedExpiry(current *model.Payment) (string, error) {
+	if current.UserID == "" || len(current.UpgradeOf) > 0 {
+		return "", nil
+	}
+	plan, ok := p.findPlan(current.PlanID)
+	if !ok {
+		return "", nil
+	}
+	activeUntil, err := p.GetActiveExpiry(current.UserID)
+	if err != nil {
+		return "", err
+	}
+	return model.ExpirationDate(plan, activeUntil)
+}
+
+// releasesCoupon reports whether moving a payment to status gives back the coupon use
+// it redeemed: the payment still counted as a redemption and now failed, expired or was
+// refunded. Unlike a revocation the release is tied to the transition, so that a
+// redelivered IPN does not give the use back twice.
+func releasesCoupon(current *model.PaymentData, status model.PaymentStatusEnum) bool {
+	if current.CouponCode == "" || current.PaymentStatus == status {
+		return false
+	}
+	if current.PaymentStatus.IsTerminal() && current.PaymentStatus != model.StatusFinished {
+		return false
+	}
+	return status == model.StatusFailed || status == model.StatusExpired || status == model.StatusRefunded
+}
+
+// releaseCoupon gives back the coupon redemption of a payment that failed, expired or
+// was refunded, see coupon.Coupon.Release.
+func (p *payment) releaseCoupon(released *model.Payment) error {
+	if p.coupons == nil {
+		return nil
+	}
+	if err := p.coupons.Release(released.CouponCode); err != nil {
+		return fmt.Errorf("could not release coupon of payment %s: %w", released.OrderID, err)
+	}
+	return nil
+}
+
+// updateAttributes maps an update onto the attributes of a payment document. Empty
+// values are left out so that an update never clears what was stored when the payment
+// was created.
+func updateAttributes(data *model.PaymentUpdate) map[string]interface{} {
+	attributes := map[string]interface{}{
+		"order_id":       data.OrderID,
+		"payment_status": data.Status,
+	}
+	amounts := map[string]float64{
+		"pay_amount":     data.PayAmount,
+		"price_amount":   data.PriceAmount,
+		"actually_paid":  data.ActuallyPaid,
+		"outcome_amount": data.OutcomeAmount,
+	}
+	for key, value := range amounts {
+		if value != 0 {
+			attributes[key] = value
+		}
+	}
+	optional := map[string]string{
+		"provider":          data.Provider,
+		"payment_id":        data.PaymentID,
+		"pay_address":       data.PayAddress,
+		"pay_currency":      data.PayCurrency,
+		"price_currency":    data.PriceCurrency,
+		"order_description": data.OrderDescription,
+		"payin_hash":        data.PayinHash,
+		"payout_hash":       data.PayoutHash,
+		"outcome_currency":  data.OutcomeCurrency,
+		"invoice_id":        data.InvoiceID,
+	}
+	for key, value := range optional {
+		if value != "" {
+			attributes[key] = value
+		}
+	}
+	return attributes
+}
+
+func isNotFound(err error) bool {
+	return isStatus(err, http.StatusNotFound)
+}
+
+func isStatus(err error, statusCode int) bool {
+	var appwriteErr *client.AppwriteError
+	return errors.As(err, &appwriteErr) && appwriteErr.GetStatusCode() == statusCode
+}
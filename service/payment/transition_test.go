@@ -0,0 +1,223 @@
+package payment
+
+import (
+	"errors"
+	"github.com/antidote-recognize0663/comics-galore-library/internal/appwritetest"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/antidote-recognize0663/comics-galore-library/service/coupon"
+	"testing"
+	"time"
+)
+
+const (
+	testDatabaseID   = "db"
+	testCollectionID = "payments"
+)
+
+// newTestPayment returns a payment service backed by the fake Appwrite server.
+func newTestPayment(server *appwritetest.Server, opts ...Option) *payment {
+	opts = append([]Option{WithDatabaseID(testDatabaseID), WithCollectionID(testCollectionID)}, opts...)
+	return NewPayment(server.Client(), nil, opts...).(*payment)
+}
+
+func TestFinishedPaymentExpiry(t *testing.T) {
+	now := time.Now().UTC()
+	activeUntil := now.AddDate(0, 0, 10).Truncate(time.Second)
+	testCases := []struct {
+		name        string
+		activeUntil time.Time
+		upgradeOf   []string
+		expectStart time.Time
+	}{
+		{name: "New Subscription", expectStart: now},
+		{name: "Renewal", activeUntil: activeUntil, expectStart: activeUntil},
+		// applyUpgrade starts an upgrade now instead of after the payments it replaces.
+		{name: "Upgrade", activeUntil: activeUntil, upgradeOf: []string{"active"}, expectStart: now},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := appwritetest.NewServer()
+			defer server.Close()
+			if !tc.activeUntil.IsZero() {
+				server.Put(testDatabaseID, testCollectionID, "active", map[string]interface{}{
+					"user_id": "user-1", "payment_status": "finished", "expired": false,
+					"expires_at": tc.activeUntil.Format(time.RFC3339),
+				})
+			}
+			// The expiry computed when the payment was created is outdated by now.
+			stale := now.AddDate(0, 0, -3).Format(time.RFC3339)
+			pending := map[string]interface{}{
+				"user_id": "user-1", "plan_id": "1", "order_id": "order-1", "payment_status": "waiting",
+				"expired": false, "expires_at": stale,
+			}
+			if tc.upgradeOf != nil {
+				pending["upgrade_of"] = tc.upgradeOf
+			}
+			server.Put(testDatabaseID, testCollectionID, "order-1", pending)
+			service := newTestPayment(server)
+
+			finished, err := service.ApplyUpdate(&model.PaymentUpdate{OrderID: "order-1", Status: model.StatusFinished})
+			if err != nil {
+				t.Fatalf("ApplyUpdate failed: %v", err)
+			}
+			expiresAt, err := time.Parse(time.RFC3339, finished.ExpiresAt)
+			if err != nil {
+				t.Fatalf("Invalid expires_at %q: %v", finished.ExpiresAt, err)
+			}
+			expected := tc.expectStart.AddDate(0, 1, 0)
+			if expiresAt.Before(expected.Add(-time.Minute)) || expiresAt.After(expected.Add(time.Minute)) {
+				t.Errorf("Expected expiry around %s, got %s", expected.Format(time.RFC3339), finished.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestConcurrentStatusUpdates(t *testing.T) {
+	server := appwritetest.NewServer()
+	defer server.Close()
+	waiting, _ := model.NewStatusTransition("", model.StatusWaiting, nil)
+	server.Put(testDatabaseID, testCollectionID, "order-1", map[string]interface{}{
+		"order_id": "order-1", "payment_status": "waiting", "status_history": []string{waiting},
+	})
+	confirming, _ := model.NewStatusTransition(model.StatusWaiting, model.StatusConfirming, nil)
+	interfered := false
+	server.OnWrite(func(databaseID, collectionID, documentID string) {
+		if interfered {
+			return
+		}
+		// Another delivery is applied between reading and writing the payment.
+		interfered = true
+		server.Update(databaseID, collectionID, documentID, map[string]interface{}{
+			"payment_status": "confirming", "status_history": []string{waiting, confirming},
+		})
+	})
+	service := newTestPayment(server)
+
+	const body = `{"payment_status":"confirmed","payment_id":42,"order_id":"order-1","price_amount":10.50}`
+	ok, reason, ipn := model.ValidatePayload(mustSign(t, body), []byte(body), "secret")
+	if !ok {
+		t.Fatalf("ValidatePayload failed: %s", reason)
+	}
+	updated, err := service.SaveOrUpdate(ipn)
+	if err != nil {
+		t.Fatalf("SaveOrUpdate failed: %v", err)
+	}
+	transitions, err := updated.Transitions()
+	if err != nil {
+		t.Fatalf("Transitions failed: %v", err)
+	}
+	if len(transitions) != 3 || transitions[2].From != model.StatusConfirming || transitions[2].To != model.StatusConfirmed {
+		t.Fatalf("Expected the concurrent transition to be kept, got %+v", transitions)
+	}
+	if string(transitions[2].IPN) != body {
+		t.Errorf("Expected the raw IPN body to be stored, got %s", transitions[2].IPN)
+	}
+
+	server.OnWrite(func(databaseID, collectionID, documentID string) {
+		server.Update(databaseID, collectionID, documentID, map[string]interface{}{"pay_address": "changed"})
+	})
+	if _, err := service.ApplyUpdate(&model.PaymentUpdate{OrderID: "order-1", Status: model.StatusSending}); !errors.Is(err, model.ErrConflict) {
+		t.Errorf("Expected ErrConflict for a payment that keeps changing, got %v", err)
+	}
+}
+
+func TestCouponRelease(t *testing.T) {
+	testCases := []struct {
+		name              string
+		statuses          []model.PaymentStatusEnum
+		expectRedemptions float64
+	}{
+		{name: "Finished", statuses: []model.PaymentStatusEnum{model.StatusFinished}, expectRedemptions: 1},
+		{name: "Failed", statuses: []model.PaymentStatusEnum{model.StatusFailed}},
+		{name: "Expired", statuses: []model.PaymentStatusEnum{model.StatusExpired}},
+		{name: "Refunded", statuses: []model.PaymentStatusEnum{model.StatusFinished, model.StatusRefunded}},
+		{name: "Redelivered", statuses: []model.PaymentStatusEnum{model.StatusFailed, model.StatusFailed}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := appwritetest.NewServer()
+			defer server.Close()
+			server.Put(testDatabaseID, "coupons", "SPRING20", map[string]interface{}{
+				"code": "SPRING20", "discount_type": "percentage", "value": 20, "redemptions": 2,
+			})
+			server.Put(testDatabaseID, testCollectionID, "order-1", map[string]interface{}{
+				"order_id": "order-1", "payment_status": "waiting", "coupon_code": "SPRING20",
+			})
+			coupons := coupon.NewCoupon(server.Client(), coupon.WithDatabaseID(testDatabaseID),
+				coupon.WithCollectionID("coupons"), coupon.WithPaymentsCollectionID(testCollectionID))
+			service := newTestPayment(server, WithCoupons(coupons))
+
+			for _, status := range tc.statuses {
+				if _, err := service.ApplyUpdate(&model.PaymentUpdate{OrderID: "order-1", Status: status}); err != nil && !errors.Is(err, model.ErrDuplicateUpdate) {
+					t.Fatalf("ApplyUpdate %s failed: %v", status, err)
+				}
+			}
+			stored, _ := server.Document(testDatabaseID, "coupons", "SPRING20")
+			if redemptions := stored["redemptions"].(float64) - 1; redemptions != tc.expectRedemptions {
+				t.Errorf("Expected the payment to hold %g redemptions, got %g", tc.expectRedemptions, redemptions)
+			}
+		})
+	}
+}
+
+type recordingInvalidator struct {
+	users []string
+}
+
+func (r *recordingInvalidator) Invalidate(userID string) {
+	r.users = append(r.users, userID)
+}
+
+func TestUpdateInvalidatesEntitlement(t *testing.T) {
+	server := appwritetest.NewServer()
+	defer server.Close()
+	server.Put(testDatabaseID, testCollectionID, "order-1", map[string]interface{}{
+		"user_id": "user-1", "order_id": "order-1", "payment_status": "sending",
+	})
+	invalidator := &recordingInvalidator{}
+	service := newTestPayment(server, WithInvalidator(invalidator))
+
+	if _, err := service.ApplyUpdate(&model.PaymentUpdate{OrderID: "order-1", Status: model.StatusFinished}); err != nil {
+		t.Fatalf("ApplyUpdate failed: %v", err)
+	}
+	if len(invalidator.users) == 0 || invalidator.users[0] != "user-1" {
+		t.Errorf("Expected the entitlement of user-1 to be invalidated, got %v", invalidator.users)
+	}
+}
+
+func TestDuplicateUpdate(t *testing.T) {
+	server := appwritetest.NewServer()
+	defer server.Close()
+	server.Put(testDatabaseID, testCollectionID, "order-1", map[string]interface{}{
+		"order_id": "order-1", "payment_status": "waiting",
+	})
+	service := newTestPayment(server)
+	partial := []byte(`{"order_id":"order-1","payment_status":"partially_paid","actually_paid":0.0001}`)
+	more := []byte(`{"order_id":"order-1","payment_status":"partially_paid","actually_paid":0.0002}`)
+
+	if _, err := service.ApplyUpdate(&model.PaymentUpdate{OrderID: "order-1", Status: model.StatusPartiallyPaid, Raw: partial}); err != nil {
+		t.Fatalf("ApplyUpdate failed: %v", err)
+	}
+	stored, _ := server.Document(testDatabaseID, testCollectionID, "order-1")
+	_, err := service.ApplyUpdate(&model.PaymentUpdate{OrderID: "order-1", Status: model.StatusPartiallyPaid, Raw: partial})
+	if !errors.Is(err, model.ErrDuplicateUpdate) {
+		t.Fatalf("Expected %v for a redelivery, got %v", model.ErrDuplicateUpdate, err)
+	}
+	redelivered, _ := server.Document(testDatabaseID, testCollectionID, "order-1")
+	if redelivered["$updatedAt"] != stored["$updatedAt"] {
+		t.Errorf("Expected a redelivery not to write the payment")
+	}
+	// Another payload with the same status is a new update.
+	if _, err := service.ApplyUpdate(&model.PaymentUpdate{OrderID: "order-1", Status: model.StatusPartiallyPaid, Raw: more}); err != nil {
+		t.Errorf("Expected a new payload to be applied, got %v", err)
+	}
+}
+
+func mustSign(t *testing.T, body string) string {
+	t.Helper()
+	signature, err := model.SignPayload([]byte(body), "secret")
+	if err != nil {
+		t.Fatalf("SignPayload failed: %v", err)
+	}
+	return signature
+}
@@ -0,0 +1,224 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"github.com/appwrite/sdk-for-go/query"
+	"log"
+	"time"
+)
+
+// QuoteUpgrade prices switching the user's active subscription to the plan with the
+// given ID, in the given fiat currency. The remaining value of the active payments is
+// credited against the target plan's discounted price, see model.NewUpgradeQuote.
+func (p *payment) QuoteUpgrade(userID, planID, currency string) (*model.UpgradeQuote, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("userID cannot be empty")
+	}
+	target, ok := p.findPlan(planID)
+	if !ok {
+		return nil, fmt.Errorf("unknown subscription plan %q", planID)
+	}
+	active, err := p.activePayments(userID)
+	if err != nil {
+		return nil, err
+	}
+	return model.NewUpgradeQuote(userID, active, p.plans, target, currency, time.Now().UTC())
+}
+
+// CreateUpgrade creates the checkout for the amount due on a quote and stores it as a
+// payment of the target plan. When the payment finishes, the target plan's period
+// starts and replaces the current expiry. Quotes are only honoured for
+// model.UpgradeQuoteValidity, and only as QuoteUpgrade priced them: the quote is
+// priced again from the user's active payments at the time it was made, and rejected
+// with model.ErrUpgradeQuoteChanged when it differs. While another upgrade of the same
+// payments is pending, model.ErrUpgradePending is returned.
+func (p *payment) CreateUpgrade(ctx context.Context, providers *Providers, quote *model.UpgradeQuote, opts ...UpgradeOption) (*model.Payment, error) {
+	if quote == nil {
+		return nil, fmt.Errorf("an upgrade quote is required")
+	}
+	now := time.Now()
+	if quote.Expired(now) {
+		return nil, model.ErrUpgradeQuoteExpired
+	}
+	if quote.QuotedAt().After(now) {
+		return nil, model.ErrUpgradeQuoteChanged
+	}
+	target, ok := p.findPlan(quote.ToPlanID)
+	if !ok {
+		return nil, fmt.Errorf("unknown subscription plan %q", quote.ToPlanID)
+	}
+	active, err := p.activePayments(quote.UserID)
+	if err != nil {
+		return nil, err
+	}
+	repriced, err := model.NewUpgradeQuote(quote.UserID, active, p.plans, target, quote.Currency, quote.QuotedAt().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if !repriced.Matches(quote) {
+		return nil, model.ErrUpgradeQuoteChanged
+	}
+	if err := p.checkNoPendingUpgrade(repriced); err != nil {
+		return nil, err
+	}
+	request := model.NewUpgradeCheckoutRequest(repriced, target)
+	for _, opt := range opts {
+		opt(request)
+	}
+	checkout, err := providers.CreateCheckout(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("could not create upgrade checkout: %w", err)
+	}
+	data, err := model.NewCheckoutPaymentData(repriced.UserID, target, checkout, time.Time{}, model.WithUpgrade(repriced))
+	if err != nil {
+		return nil, err
+	}
+	return p.Create(data)
+}
+
+// checkNoPendingUpgrade returns model.ErrUpgradePending when an unsettled upgrade
+// replaces any of the payments the quote replaces, so that their credit is not
+// claimed twice.
+func (p *payment) checkNoPendingUpgrade(quote *model.UpgradeQuote) error {
+	var replaced, statuses []interface{}
+	for _, orderID := range quote.ReplacesOrderIDs {
+		replaced = append(replaced, orderID)
+	}
+	for _, status := range model.NonTerminalStatuses() {
+		statuses = append(statuses, string(status))
+	}
+	response, err := p.database.ListDocuments(p.databaseID, p.collectionID, p.database.WithListDocumentsQueries([]string{
+		query.Equal("user_id", quote.UserID),
+		query.Contains("upgrade_of", replaced),
+		query.Equal("payment_status", statuses),
+		query.Limit(1),
+		query.Select([]string{"$id"}),
+	}))
+	if err != nil {
+		return fmt.Errorf("could not list pending upgrades for user %s: %w", quote.UserID, model.TranslateError(err))
+	}
+	if len(response.Documents) > 0 {
+		return fmt.Errorf("%w: order %s", model.ErrUpgradePending, response.Documents[0].Id)
+	}
+	return nil
+}
+
+// applyUpgrade is called once an upgrade payment has finished. The upgrade's expiry is
+// set from now, then the payments it replaces are marked as expired and superseded, so
+// the user is never left without an active payment. Replaced payments that are already
+// superseded by this upgrade are skipped, which makes redelivered updates a no-op. When
+// a replaced payment was superseded by another upgrade, its credit has been used
+// already: the upgrade is not applied but marked as expired and logged, to be settled
+// by hand.
+func (p *payment) applyUpgrade(upgrade *model.Payment) error {
+	defer p.invalidate(upgrade.UserID)
+	var pending, claimed []string
+	for _, orderID := range upgrade.UpgradeOf {
+		document, err := p.database.GetDocument(p.databaseID, p.collectionID, orderID)
+		if err != nil {
+			if isNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("could not get payment %s replaced by upgrade %s: %w", orderID, upgrade.Document.Id, model.TranslateError(err))
+		}
+		replaced, err := model.NewPayment(document)
+		if err != nil {
+			return fmt.Errorf("could not decode payment %s: %w", orderID, err)
+		}
+		switch replaced.SupersededBy {
+		case "":
+			pending = append(pending, orderID)
+		case upgrade.Document.Id:
+		default:
+			claimed = append(claimed, orderID)
+		}
+	}
+	if len(claimed) > 0 {
+		if !upgrade.Expired {
+			if _, err := p.database.UpdateDocument(p.databaseID, p.collectionID, upgrade.Document.Id,
+				p.database.WithUpdateDocumentData(map[string]interface{}{"expired": true})); err != nil {
+				return fmt.Errorf("could not reject upgrade %s: %w", upgrade.Document.Id, model.TranslateError(err))
+			}
+			upgrade.Expired = true
+		}
+		log.Printf("Upgrade %s of user %s rejected, payments %v were already upgraded; it needs to be refunded", upgrade.Document.Id, upgrade.UserID, claimed)
+		return nil
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	plan, ok := p.findPlan(upgrade.PlanID)
+	if !ok {
+		return fmt.Errorf("upgrade %s is for unknown plan %q", upgrade.Document.Id, upgrade.PlanID)
+	}
+	expiresAt := plan.GetPeriod().AddTo(time.Now().UTC()).Format(time.RFC3339)
+	if _, err := p.database.UpdateDocument(p.databaseID, p.collectionID, upgrade.Document.Id,
+		p.database.WithUpdateDocumentData(map[string]interface{}{"expires_at": expiresAt})); err != nil {
+		return fmt.Errorf("could not set expiry of upgrade %s: %w", upgrade.Document.Id, model.TranslateError(err))
+	}
+	upgrade.ExpiresAt = expiresAt
+	for _, orderID := range pending {
+		if _, err := p.database.UpdateDocument(p.databaseID, p.collectionID, orderID,
+			p.database.WithUpdateDocumentData(map[string]interface{}{
+				"expired":       true,
+				"superseded_by": upgrade.Document.Id,
+			})); err != nil {
+			return fmt.Errorf("could not supersede payment %s by upgrade %s: %w", orderID, upgrade.Document.Id, model.TranslateError(err))
+		}
+	}
+	log.Printf("Upgrade %s of user %s applied, subscribed until %s", upgrade.Document.Id, upgrade.UserID, expiresAt)
+	return nil
+}
+
+// activePayments lists the user's finished, unexpired payments, see GetActiveExpiry.
+func (p *payment) activePayments(userID string) ([]*model.PaymentData, error) {
+	response, err := p.database.ListDocuments(p.databaseID, p.collectionID, p.database.WithListDocumentsQueries([]string{
+		query.Equal("user_id", userID),
+		query.Equal("payment_status", string(model.StatusFinished)),
+		query.Equal("expired", false),
+		query.GreaterThan("expires_at", time.Now().UTC().Format(time.RFC3339)),
+		query.OrderAsc("expires_at"),
+		query.Limit(100),
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("could not list active payments for user %s: %w", userID, model.TranslateError(err))
+	}
+	activePayments, err := model.NewPayments(response)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode active payments for user %s: %w", userID, err)
+	}
+	active := make([]*model.PaymentData, 0, len(activePayments))
+	for _, activePayment := range activePayments {
+		if activePayment.OrderID == "" {
+			activePayment.OrderID = activePayment.Document.Id
+		}
+		active = append(active, activePayment.PaymentData)
+	}
+	return active, nil
+}
+
+type UpgradeOption func(*model.CheckoutRequest)
+
+// WithUpgradePayCurrency preselects the coin of the upgrade checkout.
+func WithUpgradePayCurrency(payCurrency string) UpgradeOption {
+	return func(r *model.CheckoutRequest) {
+		r.PayCurrency = payCurrency
+	}
+}
+
+// WithUpgradeNotificationURL sets where the provider posts status updates of the
+// upgrade payment.
+func WithUpgradeNotificationURL(notificationURL string) UpgradeOption {
+	return func(r *model.CheckoutRequest) {
+		r.NotificationURL = notificationURL
+	}
+}
+
+// WithUpgradeRedirectURL sets where the buyer is sent once the upgrade is paid.
+func WithUpgradeRedirectURL(redirectURL string) UpgradeOption {
+	return func(r *model.CheckoutRequest) {
+		r.RedirectURL = redirectURL
+	}
+}
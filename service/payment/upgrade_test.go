@@ -0,0 +1,163 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"github.com/antidote-recognize0663/comics-galore-library/internal/appwritetest"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"testing"
+	"time"
+)
+
+// checkoutRecorder is a provider that records the checkouts it creates; every other
+// method panics if used.
+type checkoutRecorder struct {
+	PaymentProvider
+	requests []*model.CheckoutRequest
+}
+
+func (c *checkoutRecorder) Name() string {
+	return "recorder"
+}
+
+func (c *checkoutRecorder) CreateCheckout(ctx context.Context, request *model.CheckoutRequest) (*model.Checkout, error) {
+	c.requests = append(c.requests, request)
+	return &model.Checkout{
+		Provider:      c.Name(),
+		OrderID:       request.OrderID,
+		PaymentID:     "payment-" + request.OrderID,
+		PriceAmount:   request.PriceAmount,
+		PriceCurrency: request.PriceCurrency,
+	}, nil
+}
+
+// putActivePayment stores a finished monthly payment of user-1 with half a month left.
+func putActivePayment(server *appwritetest.Server, orderID string) {
+	now := time.Now().UTC()
+	server.Put(testDatabaseID, testCollectionID, orderID, map[string]interface{}{
+		"order_id": orderID, "user_id": "user-1", "plan_id": "1", "payment_status": "finished", "expired": false,
+		"price_amount": 10.0, "price_currency": "usd", "expires_at": now.AddDate(0, 0, 15).Format(time.RFC3339),
+	})
+}
+
+func TestQuoteUpgrade(t *testing.T) {
+	server := appwritetest.NewServer()
+	defer server.Close()
+	putActivePayment(server, "monthly")
+	service := newTestPayment(server)
+
+	quote, err := service.QuoteUpgrade("user-1", "4", "usd")
+	if err != nil {
+		t.Fatalf("QuoteUpgrade failed: %v", err)
+	}
+	if quote.FromPlanID != "1" || len(quote.ReplacesOrderIDs) != 1 || quote.ReplacesOrderIDs[0] != "monthly" {
+		t.Errorf("Expected the monthly payment to be replaced, got %+v", quote)
+	}
+	if quote.Credit <= 0 || quote.AmountDue != quote.TargetPrice-quote.Credit {
+		t.Errorf("Expected the remaining value to be credited, got %+v", quote)
+	}
+	if _, err := service.QuoteUpgrade("user-2", "4", "usd"); !errors.Is(err, model.ErrNoActiveSubscription) {
+		t.Errorf("Expected %v for a user without subscription, got %v", model.ErrNoActiveSubscription, err)
+	}
+	if _, err := service.QuoteUpgrade("user-1", "unknown", "usd"); err == nil {
+		t.Errorf("Expected an unknown plan to be rejected")
+	}
+}
+
+func TestCreateUpgrade(t *testing.T) {
+	testCases := []struct {
+		name      string
+		tamper    func(quote *model.UpgradeQuote)
+		pending   bool
+		expectErr error
+	}{
+		{name: "Created"},
+		{name: "Tampered Amount", tamper: func(quote *model.UpgradeQuote) { quote.AmountDue = 1 }, expectErr: model.ErrUpgradeQuoteChanged},
+		{name: "Tampered Credit", tamper: func(quote *model.UpgradeQuote) { quote.Credit *= 2 }, expectErr: model.ErrUpgradeQuoteChanged},
+		{name: "Expired Quote", tamper: func(quote *model.UpgradeQuote) { quote.ValidUntil = time.Now().Add(-time.Minute) }, expectErr: model.ErrUpgradeQuoteExpired},
+		{name: "Another Upgrade Pending", pending: true, expectErr: model.ErrUpgradePending},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := appwritetest.NewServer()
+			defer server.Close()
+			putActivePayment(server, "monthly")
+			if tc.pending {
+				server.Put(testDatabaseID, testCollectionID, "other-upgrade", map[string]interface{}{
+					"user_id": "user-1", "plan_id": "3", "payment_status": "waiting", "upgrade_of": []string{"monthly"},
+				})
+			}
+			service := newTestPayment(server)
+			quote, err := service.QuoteUpgrade("user-1", "4", "usd")
+			if err != nil {
+				t.Fatalf("QuoteUpgrade failed: %v", err)
+			}
+			if tc.tamper != nil {
+				tc.tamper(quote)
+			}
+			provider := &checkoutRecorder{}
+
+			upgrade, err := service.CreateUpgrade(context.Background(), NewProviders(provider), quote)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("Expected error %v, got %v", tc.expectErr, err)
+			}
+			if err != nil {
+				if len(provider.requests) != 0 {
+					t.Errorf("Expected no checkout to be created, got %+v", provider.requests[0])
+				}
+				return
+			}
+			if len(provider.requests) != 1 || provider.requests[0].PriceAmount != quote.AmountDue {
+				t.Fatalf("Expected a checkout for %v, got %+v", quote.AmountDue, provider.requests)
+			}
+			if upgrade.PlanID != "4" || upgrade.ExpiresAt != quote.NewExpiresAt || len(upgrade.UpgradeOf) != 1 || upgrade.UpgradeOf[0] != "monthly" {
+				t.Errorf("Unexpected upgrade payment: %+v", upgrade.PaymentData)
+			}
+		})
+	}
+}
+
+func TestApplyUpgrade(t *testing.T) {
+	server := appwritetest.NewServer()
+	defer server.Close()
+	putActivePayment(server, "monthly")
+	for _, orderID := range []string{"upgrade-1", "upgrade-2"} {
+		server.Put(testDatabaseID, testCollectionID, orderID, map[string]interface{}{
+			"order_id": orderID, "user_id": "user-1", "plan_id": "4", "payment_status": "waiting", "expired": false,
+			"upgrade_of": []string{"monthly"},
+		})
+	}
+	invalidator := &recordingInvalidator{}
+	service := newTestPayment(server, WithInvalidator(invalidator))
+
+	first, err := service.ApplyUpdate(&model.PaymentUpdate{OrderID: "upgrade-1", Status: model.StatusFinished})
+	if err != nil {
+		t.Fatalf("ApplyUpdate failed: %v", err)
+	}
+	replaced, _ := server.Document(testDatabaseID, testCollectionID, "monthly")
+	if replaced["superseded_by"] != "upgrade-1" || replaced["expired"] != true {
+		t.Fatalf("Expected the monthly payment to be superseded by upgrade-1, got %v", replaced)
+	}
+
+	// A redelivered update of the applied upgrade changes nothing.
+	if err := service.applyUpgrade(first); err != nil {
+		t.Fatalf("applyUpgrade failed on redelivery: %v", err)
+	}
+	stored, _ := server.Document(testDatabaseID, testCollectionID, "upgrade-1")
+	if stored["expires_at"] != first.ExpiresAt || stored["expired"] != false {
+		t.Errorf("Expected the applied upgrade to be kept, got %v", stored)
+	}
+
+	// A second upgrade of the same payment cannot claim its credit again.
+	if _, err := service.ApplyUpdate(&model.PaymentUpdate{OrderID: "upgrade-2", Status: model.StatusFinished}); err != nil {
+		t.Fatalf("ApplyUpdate failed: %v", err)
+	}
+	replaced, _ = server.Document(testDatabaseID, testCollectionID, "monthly")
+	second, _ := server.Document(testDatabaseID, testCollectionID, "upgrade-2")
+	if replaced["superseded_by"] != "upgrade-1" || second["expired"] != true {
+		t.Errorf("Expected the second upgrade to be rejected, got %v and %v", replaced, second)
+	}
+	if len(invalidator.users) == 0 || invalidator.users[len(invalidator.users)-1] != "user-1" {
+		t.Errorf("Expected the entitlement of user-1 to be invalidated, got %v", invalidator.users)
+	}
+}
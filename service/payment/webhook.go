@@ -0,0 +1,119 @@
+package payment
+
+import (
+	"errors"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
+	"io"
+	"log"
+	"net/http"
+)
+
+// WebhookCallback is invoked after a delivery is stored; an error is answered with a 500.
+type WebhookCallback func(r *http.Request, update *model.PaymentUpdate, payment *model.Payment) error
+
+type webhookHandler struct {
+	provider       PaymentProvider
+	paymentService Payment
+	maxBodySize    int64
+	callback       WebhookCallback
+}
+
+// NewWebhookHandler serves a provider's webhook endpoint; redeliveries skip the callback.
+// Example of usage:
+//
+//	mux.Handle("POST "+btcPay.WebhookPath(), payment.NewWebhookHandler(btcPay, paymentService))
+func NewWebhookHandler(provider PaymentProvider, paymentService Payment, opts ...WebhookOption) http.Handler {
+	if provider == nil {
+		panic("payment provider is required")
+	}
+	if paymentService == nil {
+		panic("payment service is required")
+	}
+	cfg := &webhookOptions{
+		maxBodySize: 64 << 10,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return &webhookHandler{
+		provider:       provider,
+		paymentService: paymentService,
+		maxBodySize:    cfg.maxBodySize,
+		callback:       cfg.callback,
+	}
+}
+
+func (h *webhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodySize))
+	if err != nil {
+		http.Error(w, "could not read request body", http.StatusBadRequest)
+		return
+	}
+	name := h.provider.Name()
+	update, err := h.provider.VerifyWebhook(r, body)
+	if errors.Is(err, ErrWebhookSignature) {
+		log.Printf("Webhook %s: rejected delivery: %v", name, err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		log.Printf("Webhook %s: could not process delivery: %v", name, err)
+		http.Error(w, "could not process delivery", http.StatusInternalServerError)
+		return
+	}
+	if update == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	savedPayment, err := h.paymentService.ApplyUpdate(update)
+	if errors.Is(err, model.ErrDuplicateUpdate) {
+		log.Printf("Webhook %s: ignored redelivery for order %s", name, update.OrderID)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if errors.Is(err, model.ErrIllegalTransition) {
+		// A late or out-of-order delivery: acknowledge it so the provider stops retrying.
+		log.Printf("Webhook %s: ignored delivery for order %s: %v", name, update.OrderID, err)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if err != nil {
+		log.Printf("Webhook %s: could not save order %s: %v", name, update.OrderID, err)
+		http.Error(w, "could not save payment", http.StatusInternalServerError)
+		return
+	}
+	if h.callback != nil {
+		if err := h.callback(r, update, savedPayment); err != nil {
+			log.Printf("Webhook %s: callback failed for order %s: %v", name, update.OrderID, err)
+			http.Error(w, "could not process payment", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+type webhookOptions struct {
+	maxBodySize int64
+	callback    WebhookCallback
+}
+
+type WebhookOption func(*webhookOptions)
+
+func WithWebhookCallback(callback WebhookCallback) WebhookOption {
+	return func(o *webhookOptions) {
+		o.callback = callback
+	}
+}
+
+func WithWebhookMaxBodySize(size int64) WebhookOption {
+	return func(o *webhookOptions) {
+		if size > 0 {
+			o.maxBodySize = size
+		}
+	}
+}
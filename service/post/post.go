@@ -32,11 +32,11 @@ func (p post) GetByID(secret, documentId string) (*model.Post, error) {
 		documentId,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching document documentId %s: %v", documentId, err)
+		return nil, fmt.Errorf("error fetching document documentId %s: %w", documentId, model.TranslateError(err))
 	}
 	var postData model.PostData
 	if err := document.Decode(&postData); err != nil {
-		return nil, fmt.Errorf("failed decoding document documentId %s: %v", documentId, err)
+		return nil, fmt.Errorf("failed decoding document documentId %s: %w", documentId, err)
 	}
 	return &model.Post{
 		Document: document,
@@ -52,7 +52,7 @@ func (p post) FetchList(secret string, queries []string) (*model.PostList, error
 		p.collectionID,
 		databases.WithListDocumentsQueries(queries))
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch documents: %v", err)
+		return nil, fmt.Errorf("failed to fetch documents: %w", model.TranslateError(err))
 	}
 	if len(documents.Documents) == 0 {
 		return &model.PostList{
@@ -62,7 +62,7 @@ func (p post) FetchList(secret string, queries []string) (*model.PostList, error
 	}
 	var postList model.PostList
 	if err := documents.Decode(&postList); err != nil {
-		return nil, fmt.Errorf("failed to decode documents: %v", err)
+		return nil, fmt.Errorf("failed to decode documents: %w", err)
 	}
 	return &postList, nil
 }
@@ -97,12 +97,12 @@ func (p post) Create(secret string, data *model.CreatePost, opts ...DocumentOpti
 		data,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create document: %v", err)
+		return nil, fmt.Errorf("failed to create document: %w", model.TranslateError(err))
 	}
 
 	var postData model.PostData
 	if err := document.Decode(&postData); err != nil {
-		return nil, fmt.Errorf("failed to decode created document: %v", err)
+		return nil, fmt.Errorf("failed to decode created document: %w", err)
 	}
 
 	return &model.Post{
@@ -121,12 +121,12 @@ func (p post) Update(secret, documentId string, data model.PostData) (*model.Pos
 		databases.WithUpdateDocumentData(data),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update document documentId %s: %v", documentId, err)
+		return nil, fmt.Errorf("failed to update document documentId %s: %w", documentId, model.TranslateError(err))
 	}
 
 	var postData model.PostData
 	if err := document.Decode(&postData); err != nil {
-		return nil, fmt.Errorf("failed to decode updated document documentId %s: %v", documentId, err)
+		return nil, fmt.Errorf("failed to decode updated document documentId %s: %w", documentId, err)
 	}
 
 	return &model.Post{
@@ -143,7 +143,7 @@ func (p post) Delete(secret, documentId string) error {
 		documentId,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to delete document with documentId %s: %v", documentId, err)
+		return fmt.Errorf("failed to delete document with documentId %s: %w", documentId, model.TranslateError(err))
 	}
 	return nil
 }
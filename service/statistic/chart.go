@@ -43,7 +43,7 @@ func NewChart(client *client.Client, opts ...Option) Chart {
 
 func NewChartWithConfig(cfg *config.Config) Chart {
 	adminClient := utils.NewAdminClient(
-		cfg.Appwrite.CollectionIDCharts,
+		cfg.Appwrite.ApiKey,
 		utils.WithProject(cfg.Appwrite.ProjectID),
 		utils.WithEndpoint(cfg.Appwrite.Endpoint))
 	return &chart{
@@ -69,11 +69,11 @@ func (p *chart) GetList(limit int, offset int, opts ...func([]string) []string)
 		p.database.WithListDocumentsQueries(queries),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("GetList error: %v", err)
+		return nil, fmt.Errorf("GetList error: %w", model.TranslateError(err))
 	}
 	var chartList model.ChartList
 	if err := documentList.Decode(&chartList); err != nil {
-		return nil, fmt.Errorf("GetList decode error: %v", err)
+		return nil, fmt.Errorf("GetList decode error: %w", err)
 	}
 	return &chartList, nil
 }
@@ -84,6 +84,14 @@ type documentOptions struct {
 	documentID string
 }
 
+// WithChartDocumentID stores the entry under a fixed document ID instead of a unique
+// one, so that writing the same entry twice fails with a 409 conflict.
+func WithChartDocumentID(documentID string) DocumentOption {
+	return func(o *documentOptions) {
+		o.documentID = documentID
+	}
+}
+
 func (p *chart) AddData(data *model.ChartData, opts ...DocumentOption) (*model.Chart, error) {
 	options := &documentOptions{
 		documentID: id.Unique(),
@@ -100,12 +108,12 @@ func (p *chart) AddData(data *model.ChartData, opts ...DocumentOption) (*model.C
 		data,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create document: %v", err)
+		return nil, fmt.Errorf("failed to create document: %w", model.TranslateError(err))
 	}
 
 	var chartData model.ChartData
 	if err := document.Decode(&chartData); err != nil {
-		return nil, fmt.Errorf("failed to decode created document: %v", err)
+		return nil, fmt.Errorf("failed to decode created document: %w", err)
 	}
 
 	return &model.Chart{
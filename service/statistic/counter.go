@@ -3,6 +3,7 @@ package statistic
 import (
 	"fmt"
 	"github.com/antidote-recognize0663/comics-galore-library/config"
+	"github.com/antidote-recognize0663/comics-galore-library/model"
 	"github.com/antidote-recognize0663/comics-galore-library/utils"
 	"github.com/appwrite/sdk-for-go/appwrite"
 	"github.com/appwrite/sdk-for-go/client"
@@ -96,11 +97,11 @@ func (s *counter) Increment(attributeName string, value ...int64) (int64, error)
 		option,
 	)
 	if err != nil {
-		return 0, fmt.Errorf("appwrite API error while incrementing '%s': %v", attributeName, err)
+		return 0, fmt.Errorf("appwrite API error while incrementing '%s': %w", attributeName, model.TranslateError(err))
 	}
 	var data map[string]interface{}
 	if err := document.Decode(&data); err != nil {
-		return 0, fmt.Errorf("failed to decode Appwrite response: %v", err)
+		return 0, fmt.Errorf("failed to decode Appwrite response: %w", err)
 	}
 	newValue, ok := data[attributeName].(float64) // Appwrite returns numbers from JSON as float64
 	if !ok {
@@ -124,11 +125,11 @@ func (s *counter) Decrement(attributeName string, value ...int64) (int64, error)
 		option,
 	)
 	if err != nil {
-		return 0, fmt.Errorf("appwrite API error while decrementing '%s': %v", attributeName, err)
+		return 0, fmt.Errorf("appwrite API error while decrementing '%s': %w", attributeName, model.TranslateError(err))
 	}
 	var data map[string]interface{}
 	if err := document.Decode(&data); err != nil {
-		return 0, fmt.Errorf("failed to decode Appwrite response: %v", err)
+		return 0, fmt.Errorf("failed to decode Appwrite response: %w", err)
 	}
 	newValue, ok := data[attributeName].(float64) // Appwrite returns numbers from JSON as float64
 	if !ok {
@@ -145,11 +146,11 @@ func (s *counter) GetValue(attributeName string) (int64, error) {
 		s.database.WithGetDocumentQueries([]string{query.Select([]string{attributeName})}),
 	)
 	if err != nil {
-		return 0, fmt.Errorf("appwrite API error while fetching : %v", err)
+		return 0, fmt.Errorf("appwrite API error while fetching : %w", model.TranslateError(err))
 	}
 	var data map[string]interface{}
 	if err := document.Decode(&data); err != nil {
-		return 0, fmt.Errorf("failed to decode Appwrite response: %v", err)
+		return 0, fmt.Errorf("failed to decode Appwrite response: %w", err)
 	}
 	newValue, ok := data[attributeName].(float64)
 	if !ok {
@@ -27,7 +27,7 @@ func (a *appwriteStorage) GetFile(secret string, fileId string) (*model.File, er
 	storage := appwrite.NewStorage(*client)
 	getFile, err := storage.GetFile(a.bucketID, fileId)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get file: %w", err)
+		return nil, fmt.Errorf("failed to get file: %w", model.TranslateError(err))
 	}
 	var fileData model.FileData
 	if err := getFile.Decode(&fileData); err != nil {
@@ -44,7 +44,7 @@ func (a *appwriteStorage) GeFileDownload(secret string, fileId string) (*[]byte,
 	storage := appwrite.NewStorage(*client)
 	fileDownload, err := storage.GetFileDownload(a.bucketID, fileId)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get file data: %w", err)
+		return nil, fmt.Errorf("failed to get file data: %w", model.TranslateError(err))
 	}
 	return fileDownload, nil
 }
@@ -54,7 +54,7 @@ func (a *appwriteStorage) DeleteFile(secret string, fileId string) error {
 	storage := appwrite.NewStorage(*client)
 	_, err := storage.DeleteFile(a.bucketID, fileId)
 	if err != nil {
-		return fmt.Errorf("failed to delete file: %w", err)
+		return fmt.Errorf("failed to delete file: %w", model.TranslateError(err))
 	}
 	return nil
 }
@@ -64,7 +64,7 @@ func (a *appwriteStorage) CreateFile(secret string, fileId string, file file.Inp
 	storage := appwrite.NewStorage(*client)
 	createFile, err := storage.CreateFile(a.bucketID, fileId, file)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create file: %w", err)
+		return nil, fmt.Errorf("failed to create file: %w", model.TranslateError(err))
 	}
 	var fileData model.FileData
 	if err := createFile.Decode(&fileData); err != nil {
@@ -0,0 +1,35 @@
+package token
+
+import "errors"
+
+// ErrNoSigningKey is returned by NewToken without a signing key.
+var ErrNoSigningKey = errors.New("a signing key is required")
+
+// Errors returned by Verify. Every one of them wraps ErrInvalidToken.
+var (
+	ErrInvalidToken     = errors.New("invalid token")
+	ErrMalformedToken   = wrap("malformed token")
+	ErrUnsupportedAlg   = wrap("unsupported signing algorithm")
+	ErrUnknownKey       = wrap("unknown signing key")
+	ErrInvalidSignature = wrap("invalid token signature")
+	ErrTokenExpired     = wrap("token has expired")
+	ErrTokenNotYetValid = wrap("token is not valid yet")
+	ErrInvalidIssuer    = wrap("invalid token issuer")
+	ErrInvalidAudience  = wrap("invalid token audience")
+)
+
+type tokenError struct {
+	message string
+}
+
+func wrap(message string) error {
+	return &tokenError{message: message}
+}
+
+func (e *tokenError) Error() string {
+	return e.message
+}
+
+func (e *tokenError) Unwrap() error {
+	return ErrInvalidToken
+}
@@ -0,0 +1,315 @@
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/antidote-recognize0663/comics-galore-library/config"
+	"github.com/google/uuid"
+	"slices"
+	"strings"
+	"time"
+)
+
+const algorithm = "HS256"
+
+// Token issues and verifies stateless HS256 API tokens.
+type Token interface {
+	Issue(userID string, opts ...ClaimOption) (string, error)
+	// Verify checks the token, and its audience unless that is empty, and returns its claims.
+	Verify(raw string, audience string) (*Claims, error)
+}
+
+// Claims is the payload of a token; the subject is the user ID.
+type Claims struct {
+	ID            string   `json:"jti,omitempty"`
+	Issuer        string   `json:"iss,omitempty"`
+	Subject       string   `json:"sub"`
+	Audience      Audience `json:"aud,omitempty"`
+	IssuedAt      int64    `json:"iat"`
+	NotBefore     int64    `json:"nbf,omitempty"`
+	ExpiresAt     int64    `json:"exp"`
+	Labels        []string `json:"labels,omitempty"`
+	PlanExpiresAt int64    `json:"plan_exp,omitempty"`
+}
+
+// UserID returns the ID of the user the token was issued to.
+func (c *Claims) UserID() string {
+	return c.Subject
+}
+
+func (c *Claims) HasLabel(label string) bool {
+	return slices.Contains(c.Labels, label)
+}
+
+// PlanActive reports whether the subscription recorded in the token runs at now.
+func (c *Claims) PlanActive(now time.Time) bool {
+	return c.PlanExpiresAt > 0 && now.Before(time.Unix(c.PlanExpiresAt, 0))
+}
+
+func (c *Claims) Expiry() time.Time {
+	return time.Unix(c.ExpiresAt, 0).UTC()
+}
+
+// Audience is the aud claim, decoded from an array or a single string.
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+type ClaimOption func(*Claims)
+
+// WithAudience restricts the token to the given services, such as "api" or "download".
+func WithAudience(audience ...string) ClaimOption {
+	return func(c *Claims) {
+		c.Audience = append(c.Audience, audience...)
+	}
+}
+
+func WithLabels(labels ...string) ClaimOption {
+	return func(c *Claims) {
+		c.Labels = append(c.Labels, labels...)
+	}
+}
+
+// WithPlanExpiry records the end of the user's active subscription, if any.
+func WithPlanExpiry(activeUntil time.Time) ClaimOption {
+	return func(c *Claims) {
+		if !activeUntil.IsZero() {
+			c.PlanExpiresAt = activeUntil.Unix()
+		}
+	}
+}
+
+// WithLifetime overrides how long this token is valid, see WithTTL.
+func WithLifetime(lifetime time.Duration) ClaimOption {
+	return func(c *Claims) {
+		c.ExpiresAt = c.IssuedAt + int64(lifetime/time.Second)
+	}
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyID     string `json:"kid"`
+}
+
+type token struct {
+	signingKeyID string
+	keys         map[string][]byte
+	issuer       string
+	ttl          time.Duration
+	leeway       time.Duration
+	now          func() time.Time
+}
+
+// NewTokenWithConfig signs tokens with the JWT secret. To rotate it, keep accepting the old one:
+//
+//	token.NewTokenWithConfig(cfg, token.WithVerificationKey(token.KeyID(old), old))
+func NewTokenWithConfig(cfg *config.Config, opts ...Option) (Token, error) {
+	secret := cfg.Application.GetJwtSecret()
+	options := []Option{
+		WithSigningKey(KeyID(secret), secret),
+		WithIssuer(cfg.Application.GetAppName()),
+	}
+	return NewToken(append(options, opts...)...)
+}
+
+// NewToken creates a token service with a 15 minute TTL and one minute of leeway.
+func NewToken(opts ...Option) (Token, error) {
+	cfg := &Config{
+		keys:   map[string][]byte{},
+		ttl:    15 * time.Minute,
+		leeway: time.Minute,
+		now:    time.Now,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if len(cfg.keys[cfg.signingKeyID]) == 0 {
+		return nil, ErrNoSigningKey
+	}
+	return &token{
+		signingKeyID: cfg.signingKeyID,
+		keys:         cfg.keys,
+		issuer:       cfg.issuer,
+		ttl:          cfg.ttl,
+		leeway:       cfg.leeway,
+		now:          cfg.now,
+	}, nil
+}
+
+// KeyID derives a key ID from a secret.
+func KeyID(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (t *token) Issue(userID string, opts ...ClaimOption) (string, error) {
+	if userID == "" {
+		return "", fmt.Errorf("userID cannot be empty")
+	}
+	now := t.now()
+	claims := &Claims{
+		ID:        uuid.NewString(),
+		Issuer:    t.issuer,
+		Subject:   userID,
+		IssuedAt:  now.Unix(),
+		NotBefore: now.Unix(),
+		ExpiresAt: now.Add(t.ttl).Unix(),
+	}
+	for _, opt := range opts {
+		opt(claims)
+	}
+	encodedHeader, err := encode(header{Algorithm: algorithm, Type: "JWT", KeyID: t.signingKeyID})
+	if err != nil {
+		return "", fmt.Errorf("could not encode token header: %w", err)
+	}
+	encodedClaims, err := encode(claims)
+	if err != nil {
+		return "", fmt.Errorf("could not encode token claims: %w", err)
+	}
+	signingInput := encodedHeader + "." + encodedClaims
+	return signingInput + "." + sign(t.keys[t.signingKeyID], signingInput), nil
+}
+
+func (t *token) Verify(raw string, audience string) (*Claims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+	var h header
+	if err := decode(parts[0], &h); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	if h.Algorithm != algorithm {
+		return nil, fmt.Errorf("%w %q", ErrUnsupportedAlg, h.Algorithm)
+	}
+	key, ok := t.keys[h.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, h.KeyID)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	if !hmac.Equal(signature, mac(key, parts[0]+"."+parts[1])) {
+		return nil, ErrInvalidSignature
+	}
+	var claims Claims
+	if err := decode(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	if claims.Subject == "" || claims.ExpiresAt == 0 {
+		return nil, fmt.Errorf("%w: sub and exp are required", ErrMalformedToken)
+	}
+	now := t.now()
+	if !now.Add(-t.leeway).Before(time.Unix(claims.ExpiresAt, 0)) {
+		return nil, fmt.Errorf("%w at %s", ErrTokenExpired, claims.Expiry().Format(time.RFC3339))
+	}
+	notBefore := max(claims.NotBefore, claims.IssuedAt)
+	if now.Add(t.leeway).Before(time.Unix(notBefore, 0)) {
+		return nil, ErrTokenNotYetValid
+	}
+	if t.issuer != "" && claims.Issuer != t.issuer {
+		return nil, fmt.Errorf("%w %q", ErrInvalidIssuer, claims.Issuer)
+	}
+	if audience != "" && !slices.Contains(claims.Audience, audience) {
+		return nil, fmt.Errorf("%w: not issued for %q", ErrInvalidAudience, audience)
+	}
+	return &claims, nil
+}
+
+func encode(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decode(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func mac(key []byte, signingInput string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(signingInput))
+	return h.Sum(nil)
+}
+
+func sign(key []byte, signingInput string) string {
+	return base64.RawURLEncoding.EncodeToString(mac(key, signingInput))
+}
+
+// WithSigningKey sets the key new tokens are signed and verified with.
+func WithSigningKey(kid, secret string) Option {
+	return func(c *Config) {
+		c.signingKeyID = kid
+		c.keys[kid] = []byte(secret)
+	}
+}
+
+// WithVerificationKey accepts tokens signed with another key during a rotation.
+func WithVerificationKey(kid, secret string) Option {
+	return func(c *Config) {
+		c.keys[kid] = []byte(secret)
+	}
+}
+
+// WithIssuer sets the iss claim of new tokens. Verify rejects tokens of other issuers.
+func WithIssuer(issuer string) Option {
+	return func(c *Config) {
+		c.issuer = issuer
+	}
+}
+
+// WithTTL sets how long new tokens are valid.
+func WithTTL(ttl time.Duration) Option {
+	return func(c *Config) {
+		c.ttl = ttl
+	}
+}
+
+// WithLeeway sets the clock skew tolerated when checking exp, nbf and iat.
+func WithLeeway(leeway time.Duration) Option {
+	return func(c *Config) {
+		c.leeway = leeway
+	}
+}
+
+// WithClock replaces time.Now, for tests.
+func WithClock(now func() time.Time) Option {
+	return func(c *Config) {
+		c.now = now
+	}
+}
+
+type Config struct {
+	signingKeyID string
+	keys         map[string][]byte
+	issuer       string
+	ttl          time.Duration
+	leeway       time.Duration
+	now          func() time.Time
+}
+
+type Option func(*Config)
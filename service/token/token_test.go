@@ -0,0 +1,112 @@
+package token
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIssueAndVerify(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	issuer := mustNewToken(t, WithSigningKey("2025-06", "new-secret"), WithIssuer("comics-galore"), WithClock(clock))
+	planExpiry := now.AddDate(0, 1, 0)
+	raw, err := issuer.Issue("user-1", WithAudience("api", "download"), WithLabels("subscriber"), WithPlanExpiry(planExpiry))
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	old := mustNewToken(t, WithSigningKey("2025-01", "old-secret"), WithIssuer("comics-galore"), WithClock(clock))
+	oldRaw, err := old.Issue("user-2", WithAudience("api"))
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	forged := strings.Replace(raw, strings.Split(raw, ".")[1], base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin","exp":9999999999}`)), 1)
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT","kid":"2025-06"}`)) + "." + strings.Split(raw, ".")[1] + "."
+
+	testCases := []struct {
+		name      string
+		raw       string
+		audience  string
+		at        time.Time
+		opts      []Option
+		expectErr error
+	}{
+		{name: "Valid", raw: raw, audience: "download", at: now},
+		{name: "Any Audience", raw: raw, at: now},
+		{name: "Expiry Within Leeway", raw: raw, audience: "api", at: now.Add(15*time.Minute + 30*time.Second)},
+		{name: "Verifier Clock Behind", raw: raw, audience: "api", at: now.Add(-30 * time.Second)},
+		{name: "Rotated Key", raw: oldRaw, audience: "api", at: now, opts: []Option{WithVerificationKey("2025-01", "old-secret")}},
+		{name: "Expired", raw: raw, audience: "api", at: now.Add(17 * time.Minute), expectErr: ErrTokenExpired},
+		{name: "Not Yet Valid", raw: raw, audience: "api", at: now.Add(-2 * time.Minute), expectErr: ErrTokenNotYetValid},
+		{name: "Wrong Audience", raw: raw, audience: "admin", at: now, expectErr: ErrInvalidAudience},
+		{name: "Retired Key", raw: oldRaw, audience: "api", at: now, expectErr: ErrUnknownKey},
+		{name: "Wrong Issuer", raw: raw, audience: "api", at: now, opts: []Option{WithIssuer("other")}, expectErr: ErrInvalidIssuer},
+		{name: "Forged Claims", raw: forged, audience: "api", at: now, expectErr: ErrInvalidSignature},
+		{name: "Unsigned", raw: unsigned, audience: "api", at: now, expectErr: ErrUnsupportedAlg},
+		{name: "Malformed", raw: "not-a-token", audience: "api", at: now, expectErr: ErrMalformedToken},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			at := tc.at
+			opts := append([]Option{WithSigningKey("2025-06", "new-secret"), WithIssuer("comics-galore"), WithClock(func() time.Time { return at })}, tc.opts...)
+			claims, err := mustNewToken(t, opts...).Verify(tc.raw, tc.audience)
+			if tc.expectErr != nil {
+				if !errors.Is(err, tc.expectErr) || !errors.Is(err, ErrInvalidToken) {
+					t.Errorf("Expected %v, got %v", tc.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify failed: %v", err)
+			}
+			if claims.UserID() == "" || claims.Issuer != "comics-galore" || claims.ID == "" {
+				t.Errorf("Unexpected claims: %+v", claims)
+			}
+		})
+	}
+
+	claims, err := mustNewToken(t, WithSigningKey("2025-06", "new-secret"), WithClock(clock)).Verify(raw, "api")
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if claims.UserID() != "user-1" || !claims.HasLabel("subscriber") || !claims.PlanActive(now) || claims.PlanActive(planExpiry) ||
+		!claims.Expiry().Equal(now.Add(15*time.Minute)) {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+}
+
+func TestMissingSigningKey(t *testing.T) {
+	if _, err := NewToken(WithSigningKey("empty", "")); !errors.Is(err, ErrNoSigningKey) {
+		t.Errorf("Expected %v for an empty secret, got %v", ErrNoSigningKey, err)
+	}
+	if _, err := NewToken(WithVerificationKey("2025-01", "old-secret")); !errors.Is(err, ErrNoSigningKey) {
+		t.Errorf("Expected %v without a signing key, got %v", ErrNoSigningKey, err)
+	}
+}
+
+func TestAudience(t *testing.T) {
+	var audience Audience
+	if err := audience.UnmarshalJSON([]byte(`"api"`)); err != nil || len(audience) != 1 || audience[0] != "api" {
+		t.Errorf("Expected a single audience to be accepted, got %v, %v", audience, err)
+	}
+	if err := audience.UnmarshalJSON([]byte(`["api","download"]`)); err != nil || len(audience) != 2 {
+		t.Errorf("Expected an audience list to be accepted, got %v, %v", audience, err)
+	}
+}
+
+func TestKeyID(t *testing.T) {
+	if KeyID("secret") != KeyID("secret") || KeyID("secret") == KeyID("other") || len(KeyID("secret")) != 16 {
+		t.Errorf("Expected a stable 16 character key ID per secret, got %q", KeyID("secret"))
+	}
+}
+
+func mustNewToken(t *testing.T, opts ...Option) Token {
+	t.Helper()
+	service, err := NewToken(opts...)
+	if err != nil {
+		t.Fatalf("NewToken failed: %v", err)
+	}
+	return service
+}
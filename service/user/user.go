@@ -37,19 +37,19 @@ func (s *user) AddLabel(userId, label string) (*model.Account, error) {
 	userDB := appwrite.NewUsers(*s.client)
 	fetchedUser, err := userDB.Get(userId)
 	if err != nil {
-		return nil, fmt.Errorf("GetUser error for userId '%s': %v", userId, err)
+		return nil, fmt.Errorf("GetUser error for userId '%s': %w", userId, model.TranslateError(err))
 	}
-	containsSubscriber := false
+	containsLabel := false
 	for _, l := range fetchedUser.Labels {
 		if l == label {
-			containsSubscriber = true
+			containsLabel = true
 			break
 		}
 	}
-	if !containsSubscriber {
-		userAccount, err := userDB.UpdateLabels(userId, append(fetchedUser.Labels, "subscriber"))
+	if !containsLabel {
+		userAccount, err := userDB.UpdateLabels(userId, append(fetchedUser.Labels, label))
 		if err != nil {
-			return nil, fmt.Errorf("AddLabel error for userId '%s': %v", userId, err)
+			return nil, fmt.Errorf("AddLabel error for userId '%s': %w", userId, model.TranslateError(err))
 		}
 		return model.NewAccount(userAccount), nil
 	}
@@ -60,14 +60,14 @@ func (s *user) RemoveLabel(userId, label string) (*model.Account, error) {
 	database := appwrite.NewUsers(*s.client)
 	fetchedUser, err := database.Get(userId)
 	if err != nil {
-		return nil, fmt.Errorf("GetUser error for userId '%s': %v", userId, err)
+		return nil, fmt.Errorf("GetUser error for userId '%s': %w", userId, model.TranslateError(err))
 	}
 	fetchedUser.Labels = utils.Filter(fetchedUser.Labels, func(l string) bool {
 		return l != label
 	})
 	userAccount, err := database.UpdateLabels(userId, fetchedUser.Labels)
 	if err != nil {
-		return nil, fmt.Errorf("RemoveLabel error for userId '%s': %v", userId, err)
+		return nil, fmt.Errorf("RemoveLabel error for userId '%s': %w", userId, model.TranslateError(err))
 	}
 	return model.NewAccount(userAccount), nil
 }